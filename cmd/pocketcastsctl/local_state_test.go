@@ -0,0 +1,71 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pocketcastsctl/internal/history"
+	"pocketcastsctl/internal/player"
+	"pocketcastsctl/internal/state"
+)
+
+type fakeProcesses map[int]player.ProcessInfo
+
+func (f fakeProcesses) Lookup(pid int) (player.ProcessInfo, error) {
+	info, ok := f[pid]
+	if !ok {
+		return player.ProcessInfo{}, player.ErrNoProcess
+	}
+	return info, nil
+}
+
+func TestLoadLivePlaybackClearsReusedPID(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+	hist := history.New(filepath.Join(dir, "history.jsonl"))
+	ours := player.ProcessInfo{StartTime: "100", Exe: "/usr/bin/mpv"}
+	if err := state.Save(path, state.PlaybackState{PID: 42, Process: ours, Title: "Ep", StartedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	st, ok, err := loadLivePlayback(path, fakeProcesses{42: ours}, hist)
+	if err != nil || !ok || st.Title != "Ep" {
+		t.Fatalf("expected live state, got ok=%v st=%+v err=%v", ok, st, err)
+	}
+
+	// After a reboot PID 42 belongs to something else.
+	_, ok, err = loadLivePlayback(path, fakeProcesses{42: {StartTime: "7", Exe: "/usr/sbin/cupsd"}}, hist)
+	if err != nil || ok {
+		t.Fatalf("expected stale state, got ok=%v err=%v", ok, err)
+	}
+	if _, ok, _ := state.Load(path); ok {
+		t.Fatal("stale state was not cleared")
+	}
+	events, err := hist.Read(time.Time{})
+	if err != nil || len(events) != 1 || events[0].Type != history.EventLost || events[0].Title != "Ep" {
+		t.Fatalf("expected one lost event, got %+v (%v)", events, err)
+	}
+}
+
+func TestLoadLivePlaybackRecordsFinishOnlyPastTheEnd(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+	hist := history.New(filepath.Join(dir, "history.jsonl"))
+	now := time.Now()
+	// 60s left of the episode, last observed two minutes ago: it ended a minute ago.
+	if err := state.Save(path, state.PlaybackState{PID: 42, Title: "Ep", StartedAt: now.Add(-time.Hour),
+		Position: 1740, PositionAt: now.Add(-2 * time.Minute), Duration: 1800}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := loadLivePlayback(path, fakeProcesses{}, hist); err != nil || ok {
+		t.Fatalf("expected stale state, got ok=%v err=%v", ok, err)
+	}
+	events, err := hist.Read(time.Time{})
+	if err != nil || len(events) != 1 || events[0].Type != history.EventFinish || events[0].Position != 1800 {
+		t.Fatalf("expected a finish event at the end, got %+v (%v)", events, err)
+	}
+	if d := now.Add(-time.Minute).Sub(events[0].Time); d < -time.Second || d > time.Second {
+		t.Errorf("finish recorded at %v, want about a minute ago", events[0].Time)
+	}
+}
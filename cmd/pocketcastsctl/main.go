@@ -9,16 +9,26 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"pocketcastsctl/internal/browsercontrol"
+	"pocketcastsctl/internal/capture"
+	"pocketcastsctl/internal/chapters"
 	"pocketcastsctl/internal/config"
 	"pocketcastsctl/internal/har"
+	"pocketcastsctl/internal/history"
 	"pocketcastsctl/internal/player"
 	"pocketcastsctl/internal/pocketcasts"
 	"pocketcastsctl/internal/state"
@@ -59,8 +69,12 @@ func run(args []string) int {
 		return runWeb(args[1:], cfg)
 	case "queue":
 		return runQueue(args[1:], cfg)
+	case "handoff":
+		return runHandoff(args[1:], cfg)
 	case "har":
 		return runHAR(args[1:])
+	case "capture":
+		return runCapture(args[1:])
 	case "completion":
 		return runCompletion(args[1:])
 	default:
@@ -115,23 +129,40 @@ Usage:
   pocketcastsctl local pick
   pocketcastsctl local play <index|uuid>
   pocketcastsctl local pause|resume|stop|status
+  pocketcastsctl local chapters [--feed url] [--refresh] [--json]
+  pocketcastsctl local chapter <next|prev|n>
+  pocketcastsctl local history [--since 7d|2006-01-02] [--json] [--summary]
+  pocketcastsctl handoff <to-local|to-web> [--browser <name>] [--browser-app <app>] [--url-contains needle] [--web-base url]
   pocketcastsctl login
   pocketcastsctl auth login [--browser <name>] [--browser-app <app>] [--url https://play.pocketcasts.com]
-  pocketcastsctl auth sync [--browser <name>] [--browser-app <app>] [--url-contains needle]
-  pocketcastsctl auth tabs [--browser <name>] [--browser-app <app>]
+  pocketcastsctl auth sync [--browser <name>] [--browser-app <app>] [--cdp-url url] [--url-contains needle]
+  pocketcastsctl auth tabs [--browser <name>] [--browser-app <app>] [--cdp-url url]
   pocketcastsctl auth clear
-  pocketcastsctl web <play|pause|toggle|next|prev|status> [--browser <name>] [--browser-app <app>] [--url-contains needle]
-  pocketcastsctl queue ls [--json] [--browser <name>] [--browser-app <app>] [--url-contains needle]
+  pocketcastsctl web <play|pause|toggle|next|prev|status|mute> [--json] [--browser <name>] [--browser-app <app>] [--cdp-url url] [--url-contains needle] [--tab n]
+  pocketcastsctl web seek <+30s|-10s|mm:ss> | speed <rate> | volume <0-100> [--json] [browser flags]
+  pocketcastsctl web watch [--tick 10s] [--interval 1s] [--max-interval 10s] [browser flags]
+  pocketcastsctl web tabs [--json] [browser flags]
+  pocketcastsctl queue ls [--json] [--browser <name>] [--browser-app <app>] [--cdp-url url] [--url-contains needle]
   pocketcastsctl queue api ls [--limit N] [--search q] [--json|--raw] [--plain]
   pocketcastsctl queue api add (--uuid id --podcast id --title t --published rfc3339 --url audioUrl) | (--episode-json json)
   pocketcastsctl queue api rm <episode-uuid...>
   pocketcastsctl queue api play <index|uuid> [--browser <name>] [--browser-app <app>] [--url-contains needle]
   pocketcastsctl queue api pick [--search q] [--browser <name>] [--browser-app <app>] [--url-contains needle]
-  pocketcastsctl har summarize [--host host] [--json] <file.har>   (use --host= to disable filtering)
+  pocketcastsctl har summarize [--host host] [--sort path|count|wait|size|seen] [--min-count n] [--json] <file.har>   (use --host= to disable filtering)
   pocketcastsctl har graphql [--host host] [--json] <file.har>     (use --host= to disable filtering)
-  pocketcastsctl har redact <in.har> <out.har>
+  pocketcastsctl har redact [--uuids] [--rules rules.json] [--max-body-mb n] <in.har> <out.har>
+  pocketcastsctl har audit [--uuids] [--rules rules.json] [--json] <file.har>
+  pocketcastsctl har diff [--host host] [--breaking] [--json] <old.har> <new.har>
+  pocketcastsctl har export [--format curl|httpie|go-fixture] [--host host] [--path substr] [--uuids] [--rules rules.json] [--package name] <file.har>
+  pocketcastsctl har serve [--addr 127.0.0.1:8080] [--host host] <file.har>
+  pocketcastsctl har schema [--host host] [--path substr] [--format json|go] [--package name] <file.har>
+  pocketcastsctl capture [--listen 127.0.0.1:8888] [--out capture.har] [--host pocketcasts] [--uuids] [--rules rules.json] [--max-body-mb n]
   pocketcastsctl config init
   pocketcastsctl help
+
+Browser control uses AppleScript (macOS) by default. With --browser cdp or --cdp-url,
+it talks to Chrome/Chromium started with --remote-debugging-port=9222 (any OS); with
+--browser firefox or --bidi-url, to Firefox started with --remote-debugging-port (WebDriver BiDi).
 `) + "\n")
 }
 
@@ -171,13 +202,11 @@ func runAuth(args []string, cfg config.Config) int {
 	case "sync":
 		fs := flag.NewFlagSet("auth sync", flag.ContinueOnError)
 		fs.SetOutput(os.Stderr)
-		browser := fs.String("browser", cfg.Browser, `chrome or safari`)
-		browserApp := fs.String("browser-app", cfg.BrowserApp, `macOS application name (optional)`)
-		urlContains := fs.String("url-contains", cfg.URLContains, `substring to match the Pocket Casts tab URL`)
+		bf := addBrowserFlags(fs, cfg)
 		header := fs.String("header", "Authorization", "header name to store in config")
 		prefix := fs.String("prefix", "Bearer ", "prefix to add to token (set empty to store raw token)")
 		keyContains := fs.String("key-contains", "", "prefer tokens whose sourceKey contains this substring")
-		dryRun := fs.Bool("dry-run", false, "print token candidate keys only (no token values) and exit")
+		dryRun := fs.Bool("dry-run", false, "print token candidate store paths only (no token values) and exit")
 		if err := fs.Parse(args[1:]); err != nil {
 			if errors.Is(err, flag.ErrHelp) {
 				return 0
@@ -186,11 +215,7 @@ func runAuth(args []string, cfg config.Config) int {
 			return 2
 		}
 
-		controller, err := browsercontrol.New(browsercontrol.Options{
-			Browser:     *browser,
-			BrowserApp:  *browserApp,
-			URLContains: *urlContains,
-		})
+		controller, err := browsercontrol.New(bf.options())
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "invalid browser options: %v\n", err)
 			return 2
@@ -207,11 +232,13 @@ func runAuth(args []string, cfg config.Config) int {
 				fmt.Fprintln(os.Stderr, "tip: run `pocketcastsctl auth login` (or `pocketcastsctl login`) then try again")
 				fmt.Fprintln(os.Stderr, "tip: if your Pocket Casts URL is `pocketcasts.com/...`, use `--url-contains pocketcasts.com`")
 				fmt.Fprintln(os.Stderr, "tip: if this browser isn't scriptable, try `--browser chrome` or `--browser safari`")
+				fmt.Fprintln(os.Stderr, "tip: on Linux, start Chrome with --remote-debugging-port=9222 and use `--browser cdp`")
+				fmt.Fprintln(os.Stderr, "tip: for Firefox, start it with --remote-debugging-port=9222 and use `--browser firefox`")
 			}
 			return 1
 		}
 		if len(cands) == 0 {
-			fmt.Fprintln(os.Stderr, "no token candidates found in localStorage (try reloading play.pocketcasts.com while logged in)")
+			fmt.Fprintln(os.Stderr, "no token candidates found in web storage, cookies or IndexedDB (try reloading play.pocketcasts.com while logged in)")
 			return 1
 		}
 
@@ -233,12 +260,16 @@ func runAuth(args []string, cfg config.Config) int {
 			value = *prefix + value
 		}
 
-		if cfg.APIHeaders == nil {
-			cfg.APIHeaders = map[string]string{}
-		}
-		cfg.APIHeaders[*header] = value
-
-		if err := config.Save(cfg); err != nil {
+		// Re-read under the lock so a concurrent invocation's changes aren't overwritten.
+		// Browser preferences come from cfg because `auth login` passes them in unsaved.
+		_, err = config.Update(func(c *config.Config) error {
+			c.Browser = cfg.Browser
+			c.BrowserApp = cfg.BrowserApp
+			c.URLContains = cfg.URLContains
+			c.APIHeaders[*header] = value
+			return nil
+		})
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "failed to save config: %v\n", err)
 			return 1
 		}
@@ -246,8 +277,11 @@ func runAuth(args []string, cfg config.Config) int {
 		return 0
 
 	case "clear":
-		cfg.APIHeaders = map[string]string{}
-		if err := config.Save(cfg); err != nil {
+		_, err := config.Update(func(c *config.Config) error {
+			c.APIHeaders = map[string]string{}
+			return nil
+		})
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "failed to save config: %v\n", err)
 			return 1
 		}
@@ -262,10 +296,46 @@ func runAuth(args []string, cfg config.Config) int {
 	}
 }
 
+// browserFlags are the flags shared by every command that drives the Web Player tab.
+type browserFlags struct {
+	browser     *string
+	browserApp  *string
+	urlContains *string
+	cdpURL      *string
+	bidiURL     *string
+	tab         *int
+}
+
+func addBrowserFlags(fs *flag.FlagSet, cfg config.Config) browserFlags {
+	return browserFlags{
+		browser:     fs.String("browser", cfg.Browser, `browser name (chrome/safari/arc/dia/brave/edge/vivaldi/orion, firefox, cdp, or custom app name)`),
+		browserApp:  fs.String("browser-app", cfg.BrowserApp, `macOS application name (optional)`),
+		urlContains: fs.String("url-contains", cfg.URLContains, `substring to match the Pocket Casts tab URL`),
+		cdpURL:      fs.String("cdp-url", cfg.CDPURL, `Chrome DevTools endpoint, e.g. http://127.0.0.1:9222 (selects the CDP backend)`),
+		bidiURL:     fs.String("bidi-url", cfg.BiDiURL, `WebDriver BiDi endpoint, e.g. ws://127.0.0.1:9222/session (selects the Firefox backend)`),
+		tab:         fs.Int("tab", 0, "n-th matching tab as listed by `web tabs` (0 prefers the playing tab)"),
+	}
+}
+
+func (f browserFlags) options() browsercontrol.Options {
+	return browsercontrol.Options{
+		Browser:     *f.browser,
+		BrowserApp:  *f.browserApp,
+		URLContains: *f.urlContains,
+		CDPURL:      *f.cdpURL,
+		BiDiURL:     *f.bidiURL,
+		Tab:         *f.tab,
+	}
+}
+
 func isBrowserAutomationHintError(err error) bool {
 	if err == nil {
 		return false
 	}
+	var unsupported *browsercontrol.UnsupportedError
+	if errors.As(err, &unsupported) {
+		return true
+	}
 	s := strings.ToLower(err.Error())
 	switch {
 	case strings.Contains(s, "no tab found"):
@@ -329,6 +399,8 @@ func runAuthTabs(args []string, cfg config.Config) int {
 	fs.SetOutput(os.Stderr)
 	browser := fs.String("browser", cfg.Browser, `browser name`)
 	browserApp := fs.String("browser-app", cfg.BrowserApp, `macOS application name (optional)`)
+	cdpURL := fs.String("cdp-url", cfg.CDPURL, `Chrome DevTools endpoint, e.g. http://127.0.0.1:9222 (selects the CDP backend)`)
+	bidiURL := fs.String("bidi-url", cfg.BiDiURL, `WebDriver BiDi endpoint, e.g. ws://127.0.0.1:9222/session (selects the Firefox backend)`)
 	if err := fs.Parse(args); err != nil {
 		if errors.Is(err, flag.ErrHelp) {
 			return 0
@@ -340,6 +412,8 @@ func runAuthTabs(args []string, cfg config.Config) int {
 		Browser:     *browser,
 		BrowserApp:  *browserApp,
 		URLContains: "pocketcasts", // not used for TabURLs
+		CDPURL:      *cdpURL,
+		BiDiURL:     *bidiURL,
 	})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "invalid browser options: %v\n", err)
@@ -424,6 +498,8 @@ func defaultAppForBrowser(browser string) string {
 		return "Brave Browser"
 	case "edge", "microsoftedge":
 		return "Microsoft Edge"
+	case "firefox":
+		return "Firefox"
 	default:
 		// treat as a custom macOS app name
 		return browser
@@ -514,16 +590,28 @@ func decodeJWTPart(s string) ([]byte, error) {
 
 func runWeb(args []string, cfg config.Config) int {
 	if len(args) == 0 {
-		fmt.Fprintln(os.Stderr, "web requires a subcommand (play/pause/toggle/next/prev/status)")
+		fmt.Fprintln(os.Stderr, "web requires a subcommand (play/pause/toggle/next/prev/status/seek/speed/volume/mute/watch/tabs)")
 		return 2
 	}
+	if args[0] == "watch" {
+		return runWebWatch(args[1:], cfg)
+	}
 
 	fs := flag.NewFlagSet("web", flag.ContinueOnError)
 	fs.SetOutput(os.Stderr)
-	browser := fs.String("browser", cfg.Browser, `browser name`)
-	browserApp := fs.String("browser-app", cfg.BrowserApp, `macOS application name (optional)`)
-	urlContains := fs.String("url-contains", cfg.URLContains, `substring to match the Pocket Casts tab URL`)
-	if err := fs.Parse(args[1:]); err != nil {
+	jsonOut := fs.Bool("json", false, "output JSON (status, tabs and seek/speed/volume/mute)")
+	bf := addBrowserFlags(fs, cfg)
+	var (
+		value string
+		err   error
+	)
+	switch args[0] {
+	case "seek", "speed", "volume":
+		value, err = parseWebValueArgs(fs, args[1:])
+	default:
+		err = fs.Parse(args[1:])
+	}
+	if err != nil {
 		if errors.Is(err, flag.ErrHelp) {
 			return 0
 		}
@@ -531,11 +619,7 @@ func runWeb(args []string, cfg config.Config) int {
 		return 2
 	}
 
-	controller, err := browsercontrol.New(browsercontrol.Options{
-		Browser:     *browser,
-		BrowserApp:  *browserApp,
-		URLContains: *urlContains,
-	})
+	controller, err := browsercontrol.New(bf.options())
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "invalid browser options: %v\n", err)
 		return 2
@@ -561,7 +645,36 @@ func runWeb(args []string, cfg config.Config) int {
 			fmt.Fprintf(os.Stderr, "status failed: %v\n", err)
 			return 1
 		}
-		fmt.Println(st.State)
+		if *jsonOut {
+			b, _ := json.MarshalIndent(st, "", "  ")
+			fmt.Println(string(b))
+			return 0
+		}
+		fmt.Println(formatWebStatus(st))
+		return 0
+	case "seek", "speed", "volume", "mute":
+		if value == "" {
+			value = fs.Arg(0)
+		}
+		return runWebMediaAction(ctx, controller, args[0], value, *jsonOut)
+	case "tabs":
+		tabs, err := controller.TargetTabs(ctx)
+		if err != nil && len(tabs) == 0 {
+			fmt.Fprintf(os.Stderr, "tabs failed: %v\n", err)
+			return 1
+		}
+		if *jsonOut {
+			b, _ := json.MarshalIndent(tabs, "", "  ")
+			fmt.Println(string(b))
+		} else {
+			for _, t := range tabs {
+				fmt.Println(formatTargetTab(t))
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "tabs: %v\n", err)
+			return 1
+		}
 		return 0
 	default:
 		fmt.Fprintf(os.Stderr, "unknown web subcommand: %s\n", args[0])
@@ -569,6 +682,236 @@ func runWeb(args []string, cfg config.Config) int {
 	}
 }
 
+// parseWebValueArgs parses the flags of seek/speed/volume and returns their value,
+// which may come before, between or after the flags and may look like a flag
+// itself ("-10s").
+func parseWebValueArgs(fs *flag.FlagSet, args []string) (string, error) {
+	value := ""
+	flags := make([]string, 0, len(args))
+	for _, a := range args {
+		if value == "" && isNegativeValue(a) {
+			value = a
+			continue
+		}
+		flags = append(flags, a)
+	}
+	for {
+		if err := fs.Parse(flags); err != nil {
+			return "", err
+		}
+		// Parsing stops at the first non-flag argument; flags may follow it.
+		if value != "" || fs.NArg() == 0 {
+			return value, nil
+		}
+		value, flags = fs.Arg(0), fs.Args()[1:]
+	}
+}
+
+// isNegativeValue reports whether a is a negative number or offset ("-10s",
+// "-1:30", "-.5") rather than a flag; no flag name starts with a digit.
+func isNegativeValue(a string) bool {
+	return len(a) > 1 && a[0] == '-' && (a[1] >= '0' && a[1] <= '9' || a[1] == '.')
+}
+
+// formatTargetTab renders one `web tabs` line; the selected tab is marked with '*'.
+func formatTargetTab(t browsercontrol.TargetTab) string {
+	mark := " "
+	if t.Selected {
+		mark = "*"
+	}
+	where := fmt.Sprintf("t%d", t.Index)
+	if t.Window > 0 {
+		where = fmt.Sprintf("w%d %s", t.Window, where)
+	}
+	title := strings.TrimSpace(t.Title)
+	if title == "" {
+		title = "(untitled)"
+	}
+	s := fmt.Sprintf("%s %d. [%s] %s  %s", mark, t.N, where, title, t.URL)
+	if t.Playing {
+		s += "  (playing)"
+	}
+	return s
+}
+
+func runWebWatch(args []string, cfg config.Config) int {
+	fs := flag.NewFlagSet("web watch", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	tick := fs.Duration("tick", 10*time.Second, "interval of position events while playing (0 disables)")
+	interval := fs.Duration("interval", time.Second, "polling interval after a change")
+	maxInterval := fs.Duration("max-interval", 10*time.Second, "polling backs off to this interval while nothing changes")
+	bf := addBrowserFlags(fs, cfg)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "failed to parse flags: %v\n", err)
+		return 2
+	}
+
+	controller, err := browsercontrol.New(bf.options())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid browser options: %v\n", err)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	opts := browsercontrol.WatchOptions{Tick: *tick, MinInterval: *interval, MaxInterval: *maxInterval}
+	err = controller.Watch(ctx, opts, func(e browsercontrol.WatchEvent) error {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		// A write error means the reader went away (e.g. a closed pipe).
+		_, err = os.Stdout.Write(append(b, '\n'))
+		return err
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "watch failed: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func runWebMediaAction(ctx context.Context, controller *browsercontrol.Controller, action, value string, jsonOut bool) int {
+	if action != "mute" && strings.TrimSpace(value) == "" {
+		fmt.Fprintf(os.Stderr, "web %s requires a value\n", action)
+		return 2
+	}
+
+	var (
+		res browsercontrol.ActionResult
+		err error
+	)
+	switch action {
+	case "seek":
+		sec, relative, perr := parseSeekArg(value)
+		if perr != nil {
+			fmt.Fprintf(os.Stderr, "invalid seek position: %v\n", perr)
+			return 2
+		}
+		res, err = controller.Seek(ctx, sec, relative)
+	case "speed":
+		rate, perr := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "x"), 64)
+		if perr != nil {
+			fmt.Fprintf(os.Stderr, "invalid speed: %q\n", value)
+			return 2
+		}
+		res, err = controller.SetSpeed(ctx, rate)
+	case "volume":
+		pct, perr := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(value), "%"))
+		if perr != nil {
+			fmt.Fprintf(os.Stderr, "invalid volume (want 0-100): %q\n", value)
+			return 2
+		}
+		res, err = controller.SetVolume(ctx, pct)
+	case "mute":
+		res, err = controller.ToggleMute(ctx)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed: %v\n", action, err)
+		return 1
+	}
+
+	if jsonOut {
+		b, _ := json.MarshalIndent(res, "", "  ")
+		fmt.Println(string(b))
+		return 0
+	}
+	if res.Media == nil {
+		// Fell back to a player button; the resulting state is unknown.
+		fmt.Println(res.ClickedLabel)
+		return 0
+	}
+	m := res.Media
+	switch action {
+	case "seek":
+		fmt.Printf("%s/%s\n", chapters.FormatTime(m.CurrentTime), chapters.FormatTime(m.Duration))
+	case "speed":
+		fmt.Println(strconv.FormatFloat(m.PlaybackRate, 'f', -1, 64) + "x")
+	case "volume":
+		fmt.Printf("%d%%\n", int(math.Round(m.Volume*100)))
+	case "mute":
+		if m.Muted {
+			fmt.Println("muted")
+		} else {
+			fmt.Println("unmuted")
+		}
+	}
+	return 0
+}
+
+// parseSeekArg parses "+30s", "-10", "-1m30s", "1:02:03" or "90". A leading sign
+// makes the position relative to the current one.
+func parseSeekArg(s string) (float64, bool, error) {
+	s = strings.TrimSpace(s)
+	relative := false
+	sign := 1.0
+	switch {
+	case strings.HasPrefix(s, "+"):
+		relative, s = true, s[1:]
+	case strings.HasPrefix(s, "-"):
+		relative, sign, s = true, -1, s[1:]
+	}
+	if s == "" {
+		return 0, false, errors.New("empty position")
+	}
+
+	var sec float64
+	switch {
+	case strings.Contains(s, ":"):
+		parts := strings.Split(s, ":")
+		if len(parts) > 3 {
+			return 0, false, fmt.Errorf("%q: want [h:]mm:ss", s)
+		}
+		for _, p := range parts {
+			n, err := strconv.ParseFloat(p, 64)
+			if err != nil || n < 0 {
+				return 0, false, fmt.Errorf("%q: want [h:]mm:ss", s)
+			}
+			sec = sec*60 + n
+		}
+	default:
+		if n, err := strconv.ParseFloat(s, 64); err == nil {
+			sec = n
+		} else if d, err := time.ParseDuration(s); err == nil {
+			sec = d.Seconds()
+		} else {
+			return 0, false, fmt.Errorf("%q: want +30s, -10s, mm:ss or seconds", s)
+		}
+		if sec < 0 {
+			return 0, false, fmt.Errorf("%q: negative position", s)
+		}
+	}
+	return sign * sec, relative, nil
+}
+
+// formatWebStatus renders a one-line status such as
+// "playing: Episode — Podcast  12:34/45:00  1.5x". It is just the state when the
+// page exposes no episode details.
+func formatWebStatus(st browsercontrol.StatusResult) string {
+	title := strings.TrimSpace(st.Title)
+	if title == "" {
+		return st.State
+	}
+	line := st.State + ": " + title
+	if p := strings.TrimSpace(st.Podcast); p != "" {
+		line += " — " + p
+	}
+	if st.Duration > 0 {
+		line += fmt.Sprintf("  %s/%s", chapters.FormatTime(st.CurrentTime), chapters.FormatTime(st.Duration))
+	}
+	if st.PlaybackRate > 0 && st.PlaybackRate != 1 {
+		line += "  " + strconv.FormatFloat(st.PlaybackRate, 'f', -1, 64) + "x"
+	}
+	if st.Muted {
+		line += "  (muted)"
+	}
+	return line
+}
+
 func runWebAction(ctx context.Context, controller *browsercontrol.Controller, action browsercontrol.Action) int {
 	res, err := controller.Do(ctx, action)
 	if err != nil {
@@ -602,9 +945,7 @@ func runQueue(args []string, cfg config.Config) int {
 	plain := fs.Bool("plain", false, "plain tab-separated output (index, title, href)")
 	search := fs.String("search", "", "filter by substring in title")
 	limit := fs.Int("limit", 0, "limit output items (0 = no limit)")
-	browser := fs.String("browser", cfg.Browser, `browser name`)
-	browserApp := fs.String("browser-app", cfg.BrowserApp, `macOS application name (optional)`)
-	urlContains := fs.String("url-contains", cfg.URLContains, `substring to match the Pocket Casts tab URL`)
+	bf := addBrowserFlags(fs, cfg)
 	if err := fs.Parse(args[1:]); err != nil {
 		if errors.Is(err, flag.ErrHelp) {
 			return 0
@@ -613,11 +954,7 @@ func runQueue(args []string, cfg config.Config) int {
 		return 2
 	}
 
-	controller, err := browsercontrol.New(browsercontrol.Options{
-		Browser:     *browser,
-		BrowserApp:  *browserApp,
-		URLContains: *urlContains,
-	})
+	controller, err := browsercontrol.New(bf.options())
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "invalid browser options: %v\n", err)
 		return 2
@@ -655,18 +992,28 @@ func runQueue(args []string, cfg config.Config) int {
 			fmt.Printf("%d\t%s\t%s\n", i+1, strings.TrimSpace(title), strings.TrimSpace(it.Href))
 			continue
 		}
+		marker := " "
+		if it.NowPlaying {
+			marker = "*"
+		}
+		line := fmt.Sprintf("%s%2d. %s", marker, i+1, title)
+		if p := strings.TrimSpace(it.Podcast); p != "" {
+			line += " — " + p
+		}
+		if d := strings.TrimSpace(it.Duration); d != "" {
+			line += "  [" + d + "]"
+		}
 		if it.Href != "" {
-			fmt.Printf("%2d. %s  %s\n", i+1, title, it.Href)
-		} else {
-			fmt.Printf("%2d. %s\n", i+1, title)
+			line += "  " + it.Href
 		}
+		fmt.Println(line)
 	}
 	return 0
 }
 
 func runLocal(args []string, cfg config.Config) int {
 	if len(args) == 0 {
-		fmt.Fprintln(os.Stderr, "local requires a subcommand (pick/play/pause/resume/stop/status)")
+		fmt.Fprintln(os.Stderr, "local requires a subcommand (pick/play/pause/resume/stop/status/chapters/chapter/history)")
 		return 2
 	}
 	switch args[0] {
@@ -682,6 +1029,12 @@ func runLocal(args []string, cfg config.Config) int {
 		return runLocalStop(cfg)
 	case "status":
 		return runLocalStatus(cfg)
+	case "chapters":
+		return runLocalChapters(args[1:], cfg)
+	case "chapter":
+		return runLocalChapter(args[1:], cfg)
+	case "history":
+		return runLocalHistory(args[1:], cfg)
 	default:
 		fmt.Fprintf(os.Stderr, "unknown local subcommand: %s\n", args[0])
 		return 2
@@ -734,7 +1087,7 @@ func runLocalPick(args []string, cfg config.Config) int {
 		fmt.Fprintf(os.Stderr, "local pick: %v\n", err)
 		return 1
 	}
-	return startLocalPlayback(cfg, chosen)
+	return startLocalPlayback(cfg, chosen, "", 0)
 }
 
 func runLocalPlay(args []string, cfg config.Config) int {
@@ -767,10 +1120,12 @@ func runLocalPlay(args []string, cfg config.Config) int {
 		fmt.Fprintf(os.Stderr, "local play: %v\n", err)
 		return 2
 	}
-	return startLocalPlayback(cfg, target)
+	return startLocalPlayback(cfg, target, "", 0)
 }
 
-func startLocalPlayback(cfg config.Config, ep pocketcasts.UpNextEpisode) int {
+// startLocalPlayback plays ep locally from startAt seconds. ep.Podcast is the
+// podcast UUID; podcastTitle, if known, is its name for display.
+func startLocalPlayback(cfg config.Config, ep pocketcasts.UpNextEpisode, podcastTitle string, startAt float64) int {
 	audioURL := strings.TrimSpace(ep.URL)
 	if audioURL == "" {
 		fmt.Fprintln(os.Stderr, "local playback needs an audio URL but none was found in the Up Next response")
@@ -778,8 +1133,18 @@ func startLocalPlayback(cfg config.Config, ep pocketcasts.UpNextEpisode) int {
 		return 1
 	}
 
-	// Stop existing playback if any.
-	_ = runLocalStop(cfg)
+	// Stop the previous player under the state lock, but release it while the new
+	// one starts: the afplay fallback downloads first, and status, pause and stop
+	// must not wait for that.
+	unlock, err := state.Lock(config.StatePath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "local play failed: %v\n", err)
+		return 1
+	}
+	if err := stopLocalPlayback(); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: stopping previous playback: %v\n", err)
+	}
+	unlock()
 
 	cacheDir, _ := os.UserCacheDir()
 	cacheDir = filepath.Join(cacheDir, "pocketcastsctl")
@@ -792,81 +1157,195 @@ func startLocalPlayback(cfg config.Config, ep pocketcasts.UpNextEpisode) int {
 		Title:     ep.Title,
 		CacheDir:  cacheDir,
 		UserAgent: "pocketcastsctl",
+		Start:     startAt,
 	})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "local play failed: %v\n", err)
 		return 1
 	}
-
-	_ = state.Save(config.StatePath(), state.PlaybackState{
-		PID:         started.PID,
-		Command:     started.Command,
-		EpisodeUUID: ep.UUID,
-		Title:       ep.Title,
-		StartedAt:   time.Now(),
-		Paused:      false,
+	if startAt > 0 && started.IPCPath == "" {
+		fmt.Fprintf(os.Stderr, "warning: %s can't start mid-episode; playing from the beginning (install mpv)\n", filepath.Base(started.Command[0]))
+		startAt = 0
+	}
+
+	now := time.Now()
+	st := state.PlaybackState{
+		PID:          started.PID,
+		Command:      started.Command,
+		EpisodeUUID:  ep.UUID,
+		Title:        ep.Title,
+		PodcastUUID:  ep.Podcast,
+		PodcastTitle: strings.TrimSpace(podcastTitle),
+		StartedAt:    now,
+		Paused:       false,
+		Process:      started.Process,
+		AudioURL:     audioURL,
+		File:         started.File,
+		IPCPath:      started.IPCPath,
+		Position:     startAt,
+		PositionAt:   now,
+	}
+	// Chapters are best-effort: most episodes have none and playback has already started.
+	chCtx, chCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	st.Chapters, _ = loadLocalChapters(chCtx, st, "")
+	chCancel()
+
+	// Another play may have started a player while this one was starting; only
+	// one may be tracked, and the later command wins.
+	err = withStateLock(func() error {
+		if err := stopLocalPlayback(); err != nil {
+			return err
+		}
+		return state.Save(config.StatePath(), st)
 	})
+	if err != nil {
+		// Untracked, the player couldn't be paused or stopped later.
+		_ = player.Stop(started.PID)
+		fmt.Fprintf(os.Stderr, "local play failed: %v\n", err)
+		return 1
+	}
+	recordLocalEvent(history.EventStart, st, startAt)
 	fmt.Printf("playing (local): %s\n", strings.TrimSpace(ep.Title))
 	return 0
 }
 
 func runLocalPause(cfg config.Config) int {
-	st, ok, err := state.Load(config.StatePath())
+	unlock, err := state.Lock(config.StatePath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "local pause: %v\n", err)
+		return 1
+	}
+	defer unlock()
+
+	st, ok, err := loadLivePlayback(config.StatePath(), player.Processes, localHistory())
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "local pause: %v\n", err)
 		return 1
 	}
-	if !ok || !player.Alive(st.PID) {
-		_ = state.Clear(config.StatePath())
+	if !ok {
 		fmt.Fprintln(os.Stderr, "local pause: nothing playing")
 		return 1
 	}
-	if err := player.Pause(st.PID); err != nil {
+	if st.Paused {
+		fmt.Println("already paused (local)")
+		return 0
+	}
+	if err := pauseLocalPlayback(&st); err != nil {
 		fmt.Fprintf(os.Stderr, "local pause: %v\n", err)
 		return 1
 	}
-	st.Paused = true
-	_ = state.Save(config.StatePath(), st)
 	fmt.Println("paused (local)")
 	return 0
 }
 
+// pauseLocalPlayback pauses the live player st describes and saves the position
+// it stopped at. The caller holds the state lock.
+func pauseLocalPlayback(st *state.PlaybackState) error {
+	learnDuration(st)
+	pos := localPosition(*st)
+	if err := player.Pause(st.PID); err != nil {
+		return err
+	}
+	st.Paused = true
+	st.Position, st.PositionAt = pos, time.Now()
+	_ = state.Save(config.StatePath(), *st)
+	recordLocalEvent(history.EventPause, *st, pos)
+	return nil
+}
+
 func runLocalResume(cfg config.Config) int {
-	st, ok, err := state.Load(config.StatePath())
+	unlock, err := state.Lock(config.StatePath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "local resume: %v\n", err)
+		return 1
+	}
+	defer unlock()
+
+	st, ok, err := loadLivePlayback(config.StatePath(), player.Processes, localHistory())
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "local resume: %v\n", err)
 		return 1
 	}
-	if !ok || !player.Alive(st.PID) {
-		_ = state.Clear(config.StatePath())
+	if !ok {
 		fmt.Fprintln(os.Stderr, "local resume: nothing playing")
 		return 1
 	}
+	// Resuming a playing player would reset PositionAt without updating Position.
+	if !st.Paused {
+		fmt.Println("already playing (local)")
+		return 0
+	}
 	if err := player.Resume(st.PID); err != nil {
 		fmt.Fprintf(os.Stderr, "local resume: %v\n", err)
 		return 1
 	}
 	st.Paused = false
+	st.PositionAt = time.Now()
 	_ = state.Save(config.StatePath(), st)
+	recordLocalEvent(history.EventResume, st, st.Position)
 	fmt.Println("resumed (local)")
 	return 0
 }
 
 func runLocalStop(cfg config.Config) int {
-	st, ok, err := state.Load(config.StatePath())
+	unlock, err := state.Lock(config.StatePath())
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "local stop: %v\n", err)
 		return 1
 	}
-	if ok && player.Alive(st.PID) {
-		_ = player.Stop(st.PID)
+	defer unlock()
+
+	if err := stopLocalPlayback(); err != nil {
+		fmt.Fprintf(os.Stderr, "local stop: %v\n", err)
+		return 1
 	}
-	_ = state.Clear(config.StatePath())
 	return 0
 }
 
+// withStateLock runs fn holding the state lock.
+func withStateLock(fn func() error) error {
+	unlock, err := state.Lock(config.StatePath())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return fn()
+}
+
+// stopLocalPlayback stops the local player, if one is running, and clears the
+// state. The caller holds the state lock.
+func stopLocalPlayback() error {
+	st, ok, err := loadLivePlayback(config.StatePath(), player.Processes, localHistory())
+	if err != nil {
+		return err
+	}
+	if ok {
+		pos := localPosition(st)
+		_ = player.Stop(st.PID)
+		recordLocalEvent(history.EventStop, st, pos)
+	}
+	return state.Clear(config.StatePath())
+}
+
 func runLocalStatus(cfg config.Config) int {
-	st, ok, err := state.Load(config.StatePath())
+	// Status only reads, so it doesn't wait for a play that is still downloading;
+	// it shows the saved state and leaves any cleanup to the next command.
+	unlock, locked, err := state.TryLock(config.StatePath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "local status: %v\n", err)
+		return 1
+	}
+	var (
+		st state.PlaybackState
+		ok bool
+	)
+	if locked {
+		defer unlock()
+		st, ok, err = loadLivePlayback(config.StatePath(), player.Processes, localHistory())
+	} else {
+		st, ok, err = state.Load(config.StatePath())
+		ok = ok && player.Owned(player.Processes, st.PID, st.Process, st.Command)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "local status: %v\n", err)
 		return 1
@@ -875,42 +1354,28 @@ func runLocalStatus(cfg config.Config) int {
 		fmt.Println("stopped")
 		return 0
 	}
-	if !player.Alive(st.PID) {
-		_ = state.Clear(config.StatePath())
-		fmt.Println("stopped")
-		return 0
+	if learnDuration(&st) && locked {
+		_ = state.Save(config.StatePath(), st)
+	}
+	chapter := ""
+	if len(st.Chapters) > 0 {
+		if i := chapters.Index(st.Chapters, localPosition(st)); i >= 0 {
+			chapter = fmt.Sprintf("  [chapter %d/%d: %s]", i+1, len(st.Chapters), strings.TrimSpace(st.Chapters[i].Title))
+		}
 	}
 	if st.Paused {
-		fmt.Printf("paused: %s\n", strings.TrimSpace(st.Title))
+		fmt.Printf("paused: %s%s\n", strings.TrimSpace(st.Title), chapter)
 		return 0
 	}
-	fmt.Printf("playing: %s\n", strings.TrimSpace(st.Title))
+	fmt.Printf("playing: %s%s\n", strings.TrimSpace(st.Title), chapter)
 	return 0
 }
 
-func runHAR(args []string) int {
-	if len(args) == 0 {
-		fmt.Fprintln(os.Stderr, "har requires a subcommand (summarize/redact)")
-		return 2
-	}
-
-	switch args[0] {
-	case "summarize":
-		return runHARSummarize(args[1:])
-	case "graphql":
-		return runHARGraphQL(args[1:])
-	case "redact":
-		return runHARRedact(args[1:])
-	default:
-		fmt.Fprintf(os.Stderr, "unknown har subcommand: %s\n", args[0])
-		return 2
-	}
-}
-
-func runHARSummarize(args []string) int {
-	fs := flag.NewFlagSet("har summarize", flag.ContinueOnError)
+func runLocalChapters(args []string, cfg config.Config) int {
+	fs := flag.NewFlagSet("local chapters", flag.ContinueOnError)
 	fs.SetOutput(os.Stderr)
-	host := fs.String("host", "api.pocketcasts.com", "filter requests by host (empty = no filter)")
+	feedURL := fs.String("feed", "", "podcast RSS feed URL to read <podcast:chapters> from")
+	refresh := fs.Bool("refresh", false, "reload chapters instead of using the cached list")
 	jsonOut := fs.Bool("json", false, "output JSON")
 	if err := fs.Parse(args); err != nil {
 		if errors.Is(err, flag.ErrHelp) {
@@ -919,37 +1384,910 @@ func runHARSummarize(args []string) int {
 		fmt.Fprintf(os.Stderr, "failed to parse flags: %v\n", err)
 		return 2
 	}
-	if fs.NArg() != 1 {
-		fmt.Fprintln(os.Stderr, "usage: pocketcastsctl har summarize [--host host] [--json] <file.har>")
-		return 2
+
+	unlock, err := state.Lock(config.StatePath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "local chapters: %v\n", err)
+		return 1
 	}
+	defer unlock()
 
-	f := fs.Arg(0)
-	sum, err := har.SummarizeFile(f, har.SummarizeOptions{Host: strings.TrimSpace(*host)})
+	st, ok, err := loadLivePlayback(config.StatePath(), player.Processes, localHistory())
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "summarize failed: %v\n", err)
+		fmt.Fprintf(os.Stderr, "local chapters: %v\n", err)
+		return 1
+	}
+	if !ok {
+		fmt.Fprintln(os.Stderr, "local chapters: nothing playing")
+		return 1
+	}
+
+	if len(st.Chapters) == 0 || *refresh || strings.TrimSpace(*feedURL) != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+		chs, err := loadLocalChapters(ctx, st, *feedURL)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "local chapters: %v\n", err)
+			return 1
+		}
+		st.Chapters = chs
+		_ = state.Save(config.StatePath(), st)
+	}
+	if len(st.Chapters) == 0 {
+		fmt.Fprintln(os.Stderr, "local chapters: episode has no chapters (try --feed <rss-url>)")
 		return 1
 	}
 
 	if *jsonOut {
-		b, _ := json.MarshalIndent(sum, "", "  ")
+		b, _ := json.MarshalIndent(st.Chapters, "", "  ")
 		fmt.Println(string(b))
 		return 0
 	}
-	fmt.Print(har.FormatSummaryText(sum))
+	cur := chapters.Index(st.Chapters, localPosition(st))
+	for i, ch := range st.Chapters {
+		marker := " "
+		if i == cur {
+			marker = "*"
+		}
+		fmt.Printf("%s%2d. %8s  %s\n", marker, i+1, chapters.FormatTime(ch.Start), strings.TrimSpace(ch.Title))
+	}
 	return 0
 }
 
-func runHARRedact(args []string) int {
-	if len(args) != 2 {
-		fmt.Fprintln(os.Stderr, "usage: pocketcastsctl har redact <in.har> <out.har>")
+func runLocalChapter(args []string, cfg config.Config) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: pocketcastsctl local chapter <next|prev|n>")
 		return 2
 	}
-	if err := har.RedactFile(args[0], args[1], har.DefaultRedactOptions()); err != nil {
-		fmt.Fprintf(os.Stderr, "redact failed: %v\n", err)
+	unlock, err := state.Lock(config.StatePath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "local chapter: %v\n", err)
 		return 1
 	}
-	fmt.Println("wrote:", args[1])
+	defer unlock()
+
+	st, ok, err := loadLivePlayback(config.StatePath(), player.Processes, localHistory())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "local chapter: %v\n", err)
+		return 1
+	}
+	if !ok {
+		fmt.Fprintln(os.Stderr, "local chapter: nothing playing")
+		return 1
+	}
+	if len(st.Chapters) == 0 {
+		fmt.Fprintln(os.Stderr, "local chapter: no chapters loaded (run `pocketcastsctl local chapters` first)")
+		return 1
+	}
+	if st.IPCPath == "" {
+		fmt.Fprintf(os.Stderr, "local chapter: %v\n", player.ErrNoIPC)
+		return 1
+	}
+
+	from := localPosition(st)
+	idx, err := chapters.Resolve(st.Chapters, from, args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "local chapter: %v\n", err)
+		return 2
+	}
+	target := st.Chapters[idx]
+
+	// A paused (SIGSTOP) mpv can't answer IPC, so wake it just long enough to seek.
+	if st.Paused {
+		_ = player.Resume(st.PID)
+	}
+	err = player.Seek(st.IPCPath, target.Start)
+	if st.Paused {
+		_ = player.Pause(st.PID)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "local chapter: %v\n", err)
+		return 1
+	}
+	st.Position, st.PositionAt = target.Start, time.Now()
+	_ = state.Save(config.StatePath(), st)
+	ev := localEvent(history.EventSeek, st, target.Start)
+	ev.From = from
+	_ = localHistory().Append(ev)
+	fmt.Printf("chapter %d/%d: %s\n", idx+1, len(st.Chapters), strings.TrimSpace(target.Title))
+	return 0
+}
+
+func runLocalHistory(args []string, cfg config.Config) int {
+	fs := flag.NewFlagSet("local history", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	since := fs.String("since", "", "only events since a duration ago (7d, 36h) or a date (2006-01-02)")
+	jsonOut := fs.Bool("json", false, "output JSON (events and weekly summary)")
+	summaryOnly := fs.Bool("summary", false, "only print minutes listened per podcast per week")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "failed to parse flags: %v\n", err)
+		return 2
+	}
+	sinceT, err := history.ParseSince(*since, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "local history: %v\n", err)
+		return 2
+	}
+
+	events, err := localHistory().Read(sinceT)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "local history: %v\n", err)
+		return 1
+	}
+	summary := history.Summarize(events)
+
+	if *jsonOut {
+		out := struct {
+			Events  []history.Event       `json:"events,omitempty"`
+			Summary []history.WeekSummary `json:"summary"`
+		}{Summary: summary}
+		if !*summaryOnly {
+			out.Events = events
+		}
+		b, _ := json.MarshalIndent(out, "", "  ")
+		fmt.Println(string(b))
+		return 0
+	}
+
+	if len(events) == 0 {
+		fmt.Println("(no local playback history)")
+		return 0
+	}
+	if !*summaryOnly {
+		for _, ev := range events {
+			pos := chapters.FormatTime(ev.Position)
+			if ev.Type == history.EventSeek {
+				pos = chapters.FormatTime(ev.From) + " -> " + pos
+			}
+			fmt.Printf("%s  %-6s  %8s  %s\n", ev.Time.Local().Format("2006-01-02 15:04"), ev.Type, pos, strings.TrimSpace(ev.Title))
+		}
+		fmt.Println()
+	}
+	fmt.Println("Minutes listened:")
+	for _, w := range summary {
+		fmt.Printf("  %s  %6.1f  %s\n", w.Week, w.Minutes, w.Podcast)
+	}
+	return 0
+}
+
+// loadLivePlayback loads the local playback state and verifies that its PID still
+// belongs to the player we started. Stale state (player exited, PID reused after a
+// reboot) is cleared and reported as nothing playing.
+// The first command to notice a player that exited on its own records an event in
+// hist (if non-nil): finish if the episode would have ended by now, otherwise
+// lost, since the player was killed or crashed or the machine rebooted.
+func loadLivePlayback(path string, procs player.ProcessTable, hist *history.Log) (state.PlaybackState, bool, error) {
+	st, ok, err := state.Load(path)
+	if err != nil || !ok {
+		return st, ok, err
+	}
+	if !player.Owned(procs, st.PID, st.Process, st.Command) {
+		_ = state.Clear(path)
+		if hist != nil {
+			now := time.Now()
+			ev := localEvent(history.EventLost, st, st.Position)
+			ev.Time = now
+			if end, ok := st.EstimatedEnd(); ok && end.Before(now) {
+				ev = localEvent(history.EventFinish, st, st.EstimatedPosition(now))
+				ev.Time = end
+			}
+			_ = hist.Append(ev)
+		}
+		return state.PlaybackState{}, false, nil
+	}
+	return st, true, nil
+}
+
+func localHistory() *history.Log {
+	return history.New(config.HistoryPath())
+}
+
+func localEvent(typ history.EventType, st state.PlaybackState, pos float64) history.Event {
+	return history.Event{
+		Time:         time.Now(),
+		Type:         typ,
+		EpisodeUUID:  st.EpisodeUUID,
+		Title:        st.Title,
+		PodcastUUID:  st.PodcastUUID,
+		PodcastTitle: st.PodcastTitle,
+		Position:     pos,
+		Backend:      st.Backend(),
+	}
+}
+
+// recordLocalEvent appends to the history log; history is best-effort and never fails a command.
+func recordLocalEvent(typ history.EventType, st state.PlaybackState, pos float64) {
+	_ = localHistory().Append(localEvent(typ, st, pos))
+}
+
+// learnDuration asks mpv for the episode length once, so finish events can be dated.
+func learnDuration(st *state.PlaybackState) bool {
+	if st.Duration > 0 || st.Paused || st.IPCPath == "" {
+		return false
+	}
+	d, err := player.Duration(st.IPCPath)
+	if err != nil || d <= 0 {
+		return false
+	}
+	st.Duration = d
+	return true
+}
+
+// localPosition asks mpv for the position when it can and otherwise extrapolates from state.
+func localPosition(st state.PlaybackState) float64 {
+	if !st.Paused && st.IPCPath != "" {
+		if pos, err := player.Position(st.IPCPath); err == nil {
+			return pos
+		}
+	}
+	return st.EstimatedPosition(time.Now())
+}
+
+func loadLocalChapters(ctx context.Context, st state.PlaybackState, feedURL string) ([]chapters.Chapter, error) {
+	var (
+		chs []chapters.Chapter
+		err error
+	)
+	switch {
+	case strings.TrimSpace(feedURL) != "":
+		return chapters.FetchFeed(ctx, feedURL, st.AudioURL, "pocketcastsctl")
+	case st.File != "":
+		chs, err = chapters.ReadFile(st.File)
+	case st.AudioURL != "":
+		chs, err = chapters.FetchID3(ctx, st.AudioURL, "pocketcastsctl")
+	default:
+		return nil, errors.New("no audio source recorded for the current playback")
+	}
+	// Not every episode is an MP3 with an ID3 tag; that just means no chapters.
+	if errors.Is(err, chapters.ErrNoID3) {
+		return nil, nil
+	}
+	return chs, err
+}
+
+func runHandoff(args []string, cfg config.Config) int {
+	if len(args) == 0 || (args[0] != "to-local" && args[0] != "to-web") {
+		fmt.Fprintln(os.Stderr, "usage: pocketcastsctl handoff <to-local|to-web>")
+		return 2
+	}
+	fs := flag.NewFlagSet("handoff "+args[0], flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	bf := addBrowserFlags(fs, cfg)
+	webBase := fs.String("web-base", "https://play.pocketcasts.com", "web player base URL")
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "failed to parse flags: %v\n", err)
+		return 2
+	}
+
+	controller, err := browsercontrol.New(bf.options())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid browser options: %v\n", err)
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if args[0] == "to-local" {
+		return handoffToLocal(ctx, cfg, controller)
+	}
+	return handoffToWeb(ctx, cfg, controller, *webBase)
+}
+
+func handoffToLocal(ctx context.Context, cfg config.Config, controller *browsercontrol.Controller) int {
+	m, err := controller.Media(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "handoff: failed to read web player: %v\n", err)
+		return 1
+	}
+	if strings.TrimSpace(m.Src) == "" {
+		fmt.Fprintln(os.Stderr, "handoff: web player has no episode loaded")
+		return 1
+	}
+	// Identify the episode before pausing, so a refused handoff leaves the web
+	// player as it was.
+	ep, err := handoffEpisode(ctx, cfg, m)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "handoff: %v\n", err)
+		return 1
+	}
+	if !m.Paused {
+		if _, err := controller.Do(ctx, browsercontrol.ActionPause); err != nil {
+			fmt.Fprintf(os.Stderr, "handoff: failed to pause web player: %v\n", err)
+			return 1
+		}
+		// Re-read so the local player starts exactly where the web one stopped.
+		if after, err := controller.Media(ctx); err == nil {
+			m.CurrentTime = after.CurrentTime
+		}
+	}
+
+	if rc := startLocalPlayback(cfg, ep, m.Podcast, m.CurrentTime); rc != 0 {
+		return rc
+	}
+	fmt.Printf("handed off to local at %s\n", chapters.FormatTime(m.CurrentTime))
+	return 0
+}
+
+// handoffEpisode finds the playing episode in Up Next (the Web Player keeps it
+// there), so `handoff to-web` can reopen it later. Without a match the handoff is
+// refused rather than stored under a UUID guessed from the page.
+func handoffEpisode(ctx context.Context, cfg config.Config, m browsercontrol.MediaState) (pocketcasts.UpNextEpisode, error) {
+	client := pocketcasts.New(pocketcasts.Options{BaseURL: cfg.APIBaseURL, Headers: cfg.APIHeaders})
+	body, err := client.UpNextList(ctx, pocketcasts.UpNextListRequest{
+		Model:          "webplayer",
+		ServerModified: "0",
+		ShowPlayStatus: true,
+		Version:        2,
+	})
+	if err != nil {
+		return pocketcasts.UpNextEpisode{}, fmt.Errorf("can't identify the playing episode: failed to fetch Up Next: %w", err)
+	}
+	eps, err := pocketcasts.ExtractUpNextEpisodes(body)
+	if err != nil {
+		return pocketcasts.UpNextEpisode{}, fmt.Errorf("can't identify the playing episode: failed to parse Up Next: %w", err)
+	}
+	ep, ok := matchPlayingEpisode(eps, m)
+	if !ok {
+		return pocketcasts.UpNextEpisode{}, errors.New("can't identify the playing episode: its audio URL is not in Up Next")
+	}
+	return ep, nil
+}
+
+// matchPlayingEpisode picks the Up Next episode whose audio URL the media element
+// is playing, or failing that the one the page itself names. The result plays
+// m.Src under the Media Session title; Podcast stays the Up Next podcast UUID.
+func matchPlayingEpisode(eps []pocketcasts.UpNextEpisode, m browsercontrol.MediaState) (pocketcasts.UpNextEpisode, bool) {
+	found := -1
+	for i, ep := range eps {
+		if sameAudioURL(ep.URL, m.Src) {
+			found = i
+			break
+		}
+	}
+	if found < 0 && m.EpisodeUUID != "" {
+		for i, ep := range eps {
+			if strings.EqualFold(ep.UUID, m.EpisodeUUID) {
+				found = i
+				break
+			}
+		}
+	}
+	if found < 0 {
+		return pocketcasts.UpNextEpisode{}, false
+	}
+	ep := eps[found]
+	ep.URL = m.Src
+	if t := strings.TrimSpace(m.Title); t != "" {
+		ep.Title = t
+	}
+	return ep, true
+}
+
+// sameAudioURL compares host and path; players add or drop tracking query
+// parameters and may switch to https.
+func sameAudioURL(a, b string) bool {
+	ua, err := url.Parse(strings.TrimSpace(a))
+	if err != nil || ua.Host == "" {
+		return false
+	}
+	ub, err := url.Parse(strings.TrimSpace(b))
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(ua.Host, ub.Host) && ua.EscapedPath() == ub.EscapedPath()
+}
+
+func handoffToWeb(ctx context.Context, cfg config.Config, controller *browsercontrol.Controller, webBase string) int {
+	// Hold the state lock throughout, so a local play or stop meanwhile can't
+	// swap the episode whose position the web player is sent to.
+	unlock, err := state.Lock(config.StatePath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "handoff: %v\n", err)
+		return 1
+	}
+	defer unlock()
+
+	st, ok, err := loadLivePlayback(config.StatePath(), player.Processes, localHistory())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "handoff: %v\n", err)
+		return 1
+	}
+	if !ok {
+		fmt.Fprintln(os.Stderr, "handoff: nothing playing locally")
+		return 1
+	}
+	if strings.TrimSpace(st.EpisodeUUID) == "" {
+		fmt.Fprintln(os.Stderr, "handoff: local playback has no episode UUID to open in the web player")
+		return 1
+	}
+	if !st.Paused {
+		if err := pauseLocalPlayback(&st); err != nil {
+			fmt.Fprintf(os.Stderr, "handoff: failed to pause local playback: %v\n", err)
+			return 1
+		}
+		fmt.Println("paused (local)")
+	}
+	pos := st.Position
+
+	episodeURL := strings.TrimRight(strings.TrimSpace(webBase), "/") + "/episode/" + st.EpisodeUUID
+	if err := controller.SetTabURL(ctx, episodeURL); err != nil {
+		fmt.Fprintf(os.Stderr, "handoff: failed to navigate web player: %v\n", err)
+		return 1
+	}
+
+	// The page needs time to render its controls and load the audio metadata.
+	var lastErr error
+	played := false
+	for ctx.Err() == nil {
+		if !played {
+			if _, err := controller.Do(ctx, browsercontrol.ActionPlay); err != nil {
+				lastErr = err
+				time.Sleep(300 * time.Millisecond)
+				continue
+			}
+			played = true
+		}
+		m, err := controller.SeekTo(ctx, pos)
+		if err == nil && m.Ready {
+			fmt.Printf("handed off to web at %s: %s\n", chapters.FormatTime(pos), strings.TrimSpace(st.Title))
+			return 0
+		}
+		if err != nil {
+			lastErr = err
+		} else {
+			lastErr = errors.New("audio not ready")
+		}
+		time.Sleep(300 * time.Millisecond)
+	}
+	fmt.Fprintf(os.Stderr, "handoff: failed to resume in web player: %v\n", lastErr)
+	return 1
+}
+
+func runHAR(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "har requires a subcommand (summarize/graphql/redact/audit/schema/diff/export/serve)")
+		return 2
+	}
+
+	switch args[0] {
+	case "summarize":
+		return runHARSummarize(args[1:])
+	case "graphql":
+		return runHARGraphQL(args[1:])
+	case "redact":
+		return runHARRedact(args[1:])
+	case "audit":
+		return runHARAudit(args[1:])
+	case "schema":
+		return runHARSchema(args[1:])
+	case "diff":
+		return runHARDiff(args[1:])
+	case "export":
+		return runHARExport(args[1:])
+	case "serve":
+		return runHARServe(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown har subcommand: %s\n", args[0])
+		return 2
+	}
+}
+
+func runHARSummarize(args []string) int {
+	fs := flag.NewFlagSet("har summarize", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	host := fs.String("host", "api.pocketcasts.com", "filter requests by host (empty = no filter)")
+	jsonOut := fs.Bool("json", false, "output JSON")
+	sortBy := fs.String("sort", "path", "order endpoints by "+strings.Join(har.SummarySorts, "|"))
+	minCount := fs.Int("min-count", 0, "leave out endpoints seen fewer times")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "failed to parse flags: %v\n", err)
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: pocketcastsctl har summarize [--host host] [--sort path|count|wait|size|seen] [--min-count n] [--json] <file.har>")
+		return 2
+	}
+	opts := har.SummarizeOptions{Host: strings.TrimSpace(*host), Sort: *sortBy, MinCount: *minCount}
+	if !slices.Contains(har.SummarySorts, opts.Sort) {
+		fmt.Fprintf(os.Stderr, "invalid --sort %q (want %s)\n", opts.Sort, strings.Join(har.SummarySorts, ", "))
+		return 2
+	}
+
+	f := fs.Arg(0)
+	sum, err := har.SummarizeFile(f, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "summarize failed: %v\n", err)
+		return 1
+	}
+
+	if *jsonOut {
+		b, _ := json.MarshalIndent(sum, "", "  ")
+		fmt.Println(string(b))
+		return 0
+	}
+	fmt.Print(har.FormatSummaryText(sum))
+	return 0
+}
+
+func runHARRedact(args []string) int {
+	fs := flag.NewFlagSet("har redact", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	uuids := fs.Bool("uuids", false, "also redact UUID-shaped values (account and episode ids)")
+	rules := fs.String("rules", "", "JSON rules file extending the default redaction")
+	maxBodyMB := fs.Int64("max-body-mb", har.DefaultMaxBodySize>>20, "drop response bodies larger than this many MB (0 = keep all)")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "failed to parse flags: %v\n", err)
+		return 2
+	}
+	if fs.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "usage: pocketcastsctl har redact [--uuids] [--rules rules.json] [--max-body-mb n] <in.har> <out.har>")
+		return 2
+	}
+	opts, err := harRedactOptions(*rules, *uuids)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "redact failed: %v\n", err)
+		return 2
+	}
+	opts.MaxBodySize = *maxBodyMB << 20
+	if err := har.RedactFile(fs.Arg(0), fs.Arg(1), opts); err != nil {
+		fmt.Fprintf(os.Stderr, "redact failed: %v\n", err)
+		return 1
+	}
+	fmt.Println("wrote:", fs.Arg(1))
+	return 0
+}
+
+// harRedactOptions layers a rules file (if any) over the default redaction.
+func harRedactOptions(rulesPath string, uuids bool) (har.RedactOptions, error) {
+	opts := har.DefaultRedactOptions()
+	opts.RedactUUIDs = uuids
+	if strings.TrimSpace(rulesPath) == "" {
+		return opts, nil
+	}
+	rules, err := har.LoadRules(rulesPath)
+	if err != nil {
+		return har.RedactOptions{}, err
+	}
+	return rules.Apply(opts)
+}
+
+// runHARAudit lists what `har redact` would still change. It exits 1 when anything
+// is found, so it can guard a commit or upload.
+func runHARAudit(args []string) int {
+	fs := flag.NewFlagSet("har audit", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	uuids := fs.Bool("uuids", false, "also report UUID-shaped values (account and episode ids)")
+	rules := fs.String("rules", "", "JSON rules file extending the default redaction")
+	jsonOut := fs.Bool("json", false, "output JSON")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "failed to parse flags: %v\n", err)
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: pocketcastsctl har audit [--uuids] [--rules rules.json] [--json] <file.har>")
+		return 2
+	}
+	opts, err := harRedactOptions(*rules, *uuids)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "audit failed: %v\n", err)
+		return 2
+	}
+	findings, err := har.AuditFile(fs.Arg(0), opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "audit failed: %v\n", err)
+		return 1
+	}
+	if *jsonOut {
+		if findings == nil {
+			findings = []har.Finding{}
+		}
+		b, _ := json.MarshalIndent(findings, "", "  ")
+		fmt.Println(string(b))
+	} else {
+		for _, f := range findings {
+			fmt.Printf("entry %d: %s (%s)\n", f.Entry, f.Location, f.Kind)
+		}
+	}
+	if len(findings) > 0 {
+		fmt.Fprintf(os.Stderr, "%d sensitive location(s) found; run `pocketcastsctl har redact` first\n", len(findings))
+		return 1
+	}
+	if !*jsonOut {
+		fmt.Println("no sensitive values found")
+	}
+	return 0
+}
+
+// runHARSchema infers the JSON shape of each endpoint's request and response
+// bodies, as a starting point for new Client methods.
+func runHARSchema(args []string) int {
+	fs := flag.NewFlagSet("har schema", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	host := fs.String("host", "api.pocketcasts.com", "filter requests by host (empty = no filter)")
+	path := fs.String("path", "", "only endpoints whose path contains this")
+	format := fs.String("format", "json", "output format: json (JSON Schema) or go (struct definitions)")
+	pkg := fs.String("package", "pocketcasts", "package clause for --format go")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "failed to parse flags: %v\n", err)
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: pocketcastsctl har schema [--host host] [--path substr] [--format json|go] [--package name] <file.har>")
+		return 2
+	}
+
+	eps, err := har.InferSchemasFile(fs.Arg(0), har.SchemaOptions{Host: strings.TrimSpace(*host), Path: strings.TrimSpace(*path)})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "schema failed: %v\n", err)
+		return 1
+	}
+	if len(eps) == 0 {
+		fmt.Fprintln(os.Stderr, "no matching requests")
+		return 1
+	}
+
+	var out []byte
+	switch strings.ToLower(strings.TrimSpace(*format)) {
+	case "json":
+		out, err = har.FormatSchemasJSON(eps)
+		out = append(out, '\n')
+	case "go":
+		out, err = har.FormatSchemasGo(eps, *pkg)
+	default:
+		fmt.Fprintf(os.Stderr, "invalid --format %q (want json or go)\n", *format)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "schema failed: %v\n", err)
+		return 1
+	}
+	os.Stdout.Write(out)
+	return 0
+}
+
+// runHARDiff compares two captures for API drift. Like diff(1) it exits 0 when they
+// match, 1 when they differ (with --breaking, only on breaking changes) and 2 on errors.
+func runHARDiff(args []string) int {
+	fs := flag.NewFlagSet("har diff", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	host := fs.String("host", "pocketcasts.com", "filter requests by host (empty = no filter)")
+	breaking := fs.Bool("breaking", false, "exit 1 only for changes that can break existing commands")
+	jsonOut := fs.Bool("json", false, "output JSON")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "failed to parse flags: %v\n", err)
+		return 2
+	}
+	if fs.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "usage: pocketcastsctl har diff [--host host] [--breaking] [--json] <old.har> <new.har>")
+		return 2
+	}
+
+	d, err := har.DiffFiles(fs.Arg(0), fs.Arg(1), har.DiffOptions{Host: strings.TrimSpace(*host)})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "diff failed: %v\n", err)
+		return 2
+	}
+	if *jsonOut {
+		b, _ := json.MarshalIndent(d, "", "  ")
+		fmt.Println(string(b))
+	} else {
+		fmt.Print(har.FormatDiffText(d))
+	}
+	if d.Empty() || (*breaking && !d.Breaking()) {
+		return 0
+	}
+	return 1
+}
+
+// runHARExport turns captured requests into curl/HTTPie commands or Go fixtures.
+// Everything is redacted first; the token comes from $POCKETCASTS_TOKEN.
+func runHARExport(args []string) int {
+	fs := flag.NewFlagSet("har export", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	format := fs.String("format", "curl", "output format: curl, httpie or go-fixture")
+	host := fs.String("host", "pocketcasts.com", "filter requests by host (empty = no filter)")
+	path := fs.String("path", "", "only requests whose path contains this")
+	uuids := fs.Bool("uuids", false, "also redact UUID-shaped values (account and episode ids)")
+	rules := fs.String("rules", "", "JSON rules file extending the default redaction")
+	pkg := fs.String("package", "pocketcasts", "package clause for --format go-fixture")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "failed to parse flags: %v\n", err)
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: pocketcastsctl har export [--format curl|httpie|go-fixture] [--host host] [--path substr] [--uuids] [--rules rules.json] [--package name] <file.har>")
+		return 2
+	}
+	exportFormat := har.ExportFormat(strings.ToLower(strings.TrimSpace(*format)))
+	if !slices.Contains(har.ExportFormats, exportFormat) {
+		fmt.Fprintf(os.Stderr, "invalid --format %q (want curl, httpie or go-fixture)\n", *format)
+		return 2
+	}
+	opts, err := harRedactOptions(*rules, *uuids)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export failed: %v\n", err)
+		return 2
+	}
+	out, err := har.ExportFile(fs.Arg(0), har.ExportOptions{
+		Host:    strings.TrimSpace(*host),
+		Path:    strings.TrimSpace(*path),
+		Format:  exportFormat,
+		Redact:  opts,
+		Package: *pkg,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export failed: %v\n", err)
+		return 1
+	}
+	os.Stdout.Write(out)
+	return 0
+}
+
+// runHARServe replays a capture as a local fake API until interrupted. Point
+// api_base_url at it to run queue api and the client offline.
+func runHARServe(args []string) int {
+	fs := flag.NewFlagSet("har serve", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	addr := fs.String("addr", "127.0.0.1:8080", "listen address")
+	host := fs.String("host", "", "only replay requests to hosts containing this (empty = all)")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "failed to parse flags: %v\n", err)
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: pocketcastsctl har serve [--addr 127.0.0.1:8080] [--host host] <file.har>")
+		return 2
+	}
+
+	rp, err := har.ReplayFile(fs.Arg(0), har.ReplayOptions{
+		Host: strings.TrimSpace(*host),
+		Logf: func(format string, args ...any) {
+			fmt.Fprintf(os.Stderr, format+"\n", args...)
+		},
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "serve failed: %v\n", err)
+		return 1
+	}
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "serve failed: %v\n", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{Handler: rp, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	fmt.Fprintf(os.Stderr, "replaying %s on http://%s (set api_base_url to this; Ctrl-C to stop)\n", fs.Arg(0), ln.Addr())
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "serve failed: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+const defaultCaptureHost = "pocketcasts"
+
+// runCapture records Pocket Casts traffic through a local proxy, redacting each
+// entry before it is written.
+func runCapture(args []string) int {
+	fs := flag.NewFlagSet("capture", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	listen := fs.String("listen", "127.0.0.1:8888", "proxy listen address")
+	out := fs.String("out", "capture.har", "HAR file to write")
+	host := fs.String("host", defaultCaptureHost, "record hosts containing this; others pass through unrecorded (empty = all)")
+	uuids := fs.Bool("uuids", false, "also redact UUID-shaped values (account and episode ids)")
+	rules := fs.String("rules", "", "JSON rules file extending the default redaction")
+	maxBodyMB := fs.Int64("max-body-mb", har.DefaultMaxBodySize>>20, "don't record response bodies larger than this many MB (0 = record all)")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "failed to parse flags: %v\n", err)
+		return 2
+	}
+	if fs.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "usage: pocketcastsctl capture [--listen 127.0.0.1:8888] [--out capture.har] [--host pocketcasts] [--uuids] [--rules rules.json] [--max-body-mb n]")
+		return 2
+	}
+	opts, err := harRedactOptions(*rules, *uuids)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "capture failed: %v\n", err)
+		return 2
+	}
+
+	// The default host gets a CA that can only sign for Pocket Casts domains;
+	// anything else needs one that can sign for any host, kept apart.
+	caPath, caKeyPath, domains := config.CaptureCAPath(), config.CaptureCAKeyPath(), capture.DefaultDomains
+	if strings.TrimSpace(*host) != defaultCaptureHost {
+		caPath, caKeyPath, domains = config.CaptureAnyCAPath(), config.CaptureAnyCAKeyPath(), nil
+	}
+	ca, created, err := capture.LoadOrCreateCA(caPath, caKeyPath, domains)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "capture failed: %v\n", err)
+		return 1
+	}
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "capture failed: %v\n", err)
+		return 1
+	}
+	w, err := har.CreateWriter(*out, "pocketcastsctl", version, opts)
+	if err != nil {
+		ln.Close()
+		fmt.Fprintf(os.Stderr, "capture failed: %v\n", err)
+		return 1
+	}
+
+	p := capture.New(ca, w, capture.Options{
+		Host:        strings.TrimSpace(*host),
+		MaxBodySize: *maxBodyMB << 20,
+		Logf: func(format string, args ...any) {
+			fmt.Fprintf(os.Stderr, format+"\n", args...)
+		},
+	})
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{Handler: p, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	if created {
+		fmt.Fprintf(os.Stderr, "created capture CA %s; trust it in your browser or OS before capturing HTTPS\n", ca.CertPath)
+	}
+	if domains == nil {
+		fmt.Fprintf(os.Stderr, "warning: %s can sign for any site; remove it from the trust store when you're done\n", ca.CertPath)
+	}
+	fmt.Fprintf(os.Stderr, "recording %q to %s via proxy http://%s (Ctrl-C to stop)\n", *host, *out, ln.Addr())
+	serveErr := srv.Serve(ln)
+	if err := w.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "capture failed: %v\n", err)
+		return 1
+	}
+	if serveErr != nil && !errors.Is(serveErr, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "capture failed: %v\n", serveErr)
+		return 1
+	}
+	fmt.Printf("wrote: %s (%d entries)\n", *out, w.Len())
 	return 0
 }
 
@@ -1006,10 +2344,14 @@ func completionScripts() map[string]string {
 		"config init",
 		"auth login", "auth sync", "auth tabs", "auth clear",
 		"web play", "web pause", "web toggle", "web next", "web prev", "web status",
+		"web seek", "web speed", "web volume", "web mute", "web watch", "web tabs",
 		"queue ls",
 		"queue api ls", "queue api add", "queue api rm", "queue api play", "queue api pick",
 		"local pick", "local play", "local pause", "local resume", "local stop", "local status",
-		"har summarize", "har graphql", "har redact",
+		"local chapters", "local chapter", "local history",
+		"handoff to-local", "handoff to-web",
+		"har summarize", "har graphql", "har redact", "har audit", "har schema", "har diff", "har export", "har serve",
+		"capture",
 	}
 	join := strings.Join(cmds, " ")
 	return map[string]string{
@@ -1265,9 +2607,7 @@ func runQueueAPIRemove(args []string, client *pocketcasts.Client, ctx context.Co
 func runQueueAPIPlay(args []string, cfg config.Config, client *pocketcasts.Client, ctx context.Context) int {
 	fs := flag.NewFlagSet("queue api play", flag.ContinueOnError)
 	fs.SetOutput(os.Stderr)
-	browser := fs.String("browser", cfg.Browser, `browser name`)
-	browserApp := fs.String("browser-app", cfg.BrowserApp, `macOS application name (optional)`)
-	urlContains := fs.String("url-contains", cfg.URLContains, `substring to match the Pocket Casts tab URL`)
+	bf := addBrowserFlags(fs, cfg)
 	webBase := fs.String("web-base", "https://play.pocketcasts.com", "web player base URL")
 	search := fs.String("search", "", "filter by substring in title before choosing")
 	if err := fs.Parse(args); err != nil {
@@ -1309,15 +2649,13 @@ func runQueueAPIPlay(args []string, cfg config.Config, client *pocketcasts.Clien
 		return 2
 	}
 
-	return playEpisodeInWebPlayer(ctx, *browser, *browserApp, *urlContains, *webBase, target)
+	return playEpisodeInWebPlayer(ctx, bf.options(), *webBase, target)
 }
 
 func runQueueAPIPick(args []string, cfg config.Config, client *pocketcasts.Client, ctx context.Context) int {
 	fs := flag.NewFlagSet("queue api pick", flag.ContinueOnError)
 	fs.SetOutput(os.Stderr)
-	browser := fs.String("browser", cfg.Browser, `browser name`)
-	browserApp := fs.String("browser-app", cfg.BrowserApp, `macOS application name (optional)`)
-	urlContains := fs.String("url-contains", cfg.URLContains, `substring to match the Pocket Casts tab URL`)
+	bf := addBrowserFlags(fs, cfg)
 	webBase := fs.String("web-base", "https://play.pocketcasts.com", "web player base URL")
 	search := fs.String("search", "", "filter by substring in title before showing picker")
 	limit := fs.Int("limit", 0, "limit items in picker (0 = no limit)")
@@ -1367,7 +2705,7 @@ func runQueueAPIPick(args []string, cfg config.Config, client *pocketcasts.Clien
 		fmt.Println(chosen.UUID)
 		return 0
 	}
-	return playEpisodeInWebPlayer(ctx, *browser, *browserApp, *urlContains, *webBase, chosen)
+	return playEpisodeInWebPlayer(ctx, bf.options(), *webBase, chosen)
 }
 
 func selectEpisode(eps []pocketcasts.UpNextEpisode, sel string) (pocketcasts.UpNextEpisode, error) {
@@ -1427,12 +2765,8 @@ func filterQueueItems(items []browsercontrol.QueueItem, search string) []browser
 	return out
 }
 
-func playEpisodeInWebPlayer(ctx context.Context, browser, browserApp, urlContains, webBase string, ep pocketcasts.UpNextEpisode) int {
-	controller, err := browsercontrol.New(browsercontrol.Options{
-		Browser:     browser,
-		BrowserApp:  browserApp,
-		URLContains: urlContains,
-	})
+func playEpisodeInWebPlayer(ctx context.Context, opts browsercontrol.Options, webBase string, ep pocketcasts.UpNextEpisode) int {
+	controller, err := browsercontrol.New(opts)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "invalid browser options: %v\n", err)
 		return 2
@@ -1,8 +1,13 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"reflect"
 	"testing"
+
+	"pocketcastsctl/internal/browsercontrol"
+	"pocketcastsctl/internal/pocketcasts"
 )
 
 func TestFormatVersion(t *testing.T) {
@@ -58,3 +63,148 @@ func TestRewriteAliases(t *testing.T) {
 		})
 	}
 }
+
+func TestIsBrowserAutomationHintError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: nil, want: false},
+		{err: errors.New("No tab found in Google Chrome with URL containing: pocketcasts.com"), want: true},
+		{err: errors.New("No tab found via CDP (http://127.0.0.1:9222) with URL containing: pocketcasts.com"), want: true},
+		{err: errors.New("0:12: syntax error: Expected end of line but found identifier. (-2741)"), want: true},
+		{err: errors.New("Not authorized to send Apple events to Safari. (-1743)"), want: true},
+		{err: errors.New("osascript is not allowed assistive access. (-1719)"), want: true},
+		{err: errors.New("Arc got an error: Application isn’t running. (-600)"), want: true},
+		{err: errors.New("Application isn't running"), want: true},
+		{err: &browsercontrol.UnsupportedError{App: "Firefox", Capability: "list tabs"}, want: true},
+		{err: errors.New(`unexpected JS result: "missing value"`), want: false},
+		{err: errors.New("no matching control found in page (action=play)"), want: false},
+	}
+	for _, tt := range tests {
+		if got := isBrowserAutomationHintError(tt.err); got != tt.want {
+			t.Errorf("isBrowserAutomationHintError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestFormatWebStatus(t *testing.T) {
+	tests := []struct {
+		st   browsercontrol.StatusResult
+		want string
+	}{
+		{st: browsercontrol.StatusResult{State: "unknown"}, want: "unknown"},
+		{st: browsercontrol.StatusResult{State: "paused", Title: "Ep 1"}, want: "paused: Ep 1"},
+		{
+			st:   browsercontrol.StatusResult{State: "playing", Title: "Ep 1", Podcast: "Show", CurrentTime: 754, Duration: 3600, PlaybackRate: 1.5},
+			want: "playing: Ep 1 — Show  12:34/1:00:00  1.5x",
+		},
+		{
+			st:   browsercontrol.StatusResult{State: "playing", Title: "Ep 1", PlaybackRate: 1, Muted: true},
+			want: "playing: Ep 1  (muted)",
+		},
+	}
+	for _, tt := range tests {
+		if got := formatWebStatus(tt.st); got != tt.want {
+			t.Errorf("formatWebStatus(%+v) = %q, want %q", tt.st, got, tt.want)
+		}
+	}
+}
+
+func TestParseSeekArg(t *testing.T) {
+	tests := []struct {
+		in       string
+		sec      float64
+		relative bool
+	}{
+		{in: "+30s", sec: 30, relative: true},
+		{in: "-10s", sec: -10, relative: true},
+		{in: "-1m30s", sec: -90, relative: true},
+		{in: "+15", sec: 15, relative: true},
+		{in: "12:34", sec: 754},
+		{in: "1:02:03", sec: 3723},
+		{in: "90", sec: 90},
+	}
+	for _, tt := range tests {
+		sec, relative, err := parseSeekArg(tt.in)
+		if err != nil || sec != tt.sec || relative != tt.relative {
+			t.Errorf("parseSeekArg(%q) = %v, %v, %v, want %v, %v", tt.in, sec, relative, err, tt.sec, tt.relative)
+		}
+	}
+	for _, in := range []string{"", "+", "1:2:3:4", "ab:cd", "soon"} {
+		if _, _, err := parseSeekArg(in); err == nil {
+			t.Errorf("parseSeekArg(%q): expected error", in)
+		}
+	}
+}
+
+func TestFormatTargetTab(t *testing.T) {
+	tab := browsercontrol.TargetTab{
+		Tab:      browsercontrol.Tab{Window: 2, Index: 3, Title: "Pocket Casts", URL: "https://play.pocketcasts.com/podcasts"},
+		N:        1,
+		Playing:  true,
+		Selected: true,
+	}
+	if got, want := formatTargetTab(tab), "* 1. [w2 t3] Pocket Casts  https://play.pocketcasts.com/podcasts  (playing)"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	tab = browsercontrol.TargetTab{Tab: browsercontrol.Tab{Index: 1, URL: "https://play.pocketcasts.com/"}, N: 2}
+	if got, want := formatTargetTab(tab), "  2. [t1] (untitled)  https://play.pocketcasts.com/"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestMatchPlayingEpisode(t *testing.T) {
+	eps := []pocketcasts.UpNextEpisode{
+		{UUID: "e1", Podcast: "p1", Title: "First", URL: "https://cdn.example.com/a/first.mp3"},
+		{UUID: "e2", Podcast: "p2", Title: "Second", URL: "https://cdn.example.com/a/second.mp3"},
+	}
+
+	// The page is showing another podcast; the audio URL decides.
+	m := browsercontrol.MediaState{
+		Src:         "http://CDN.example.com/a/second.mp3?source=web",
+		Title:       "Second (web)",
+		Podcast:     "Show Two",
+		EpisodeUUID: "e1",
+	}
+	ep, ok := matchPlayingEpisode(eps, m)
+	if !ok || ep.UUID != "e2" || ep.Podcast != "p2" || ep.Title != "Second (web)" || ep.URL != m.Src {
+		t.Fatalf("by URL: got %+v ok=%v", ep, ok)
+	}
+
+	m = browsercontrol.MediaState{Src: "https://redirected.example.net/x.mp3", EpisodeUUID: "e1"}
+	ep, ok = matchPlayingEpisode(eps, m)
+	if !ok || ep.UUID != "e1" || ep.Podcast != "p1" {
+		t.Fatalf("by page UUID: got %+v ok=%v", ep, ok)
+	}
+
+	m = browsercontrol.MediaState{Src: "https://redirected.example.net/x.mp3", EpisodeUUID: "e9"}
+	if ep, ok := matchPlayingEpisode(eps, m); ok {
+		t.Fatalf("unknown episode matched %+v", ep)
+	}
+}
+
+func TestParseWebValueArgs(t *testing.T) {
+	tests := []struct {
+		args  []string
+		value string
+		tab   int
+	}{
+		{args: []string{"-10s"}, value: "-10s"},
+		{args: []string{"-10s", "--tab", "2"}, value: "-10s", tab: 2},
+		{args: []string{"--json", "-10s"}, value: "-10s"},
+		{args: []string{"--tab", "2", "-10s"}, value: "-10s", tab: 2},
+		{args: []string{"--json", "90", "--tab", "2"}, value: "90", tab: 2},
+		{args: []string{"1.5", "--json"}, value: "1.5"},
+		{args: []string{"--json"}},
+	}
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("web", flag.ContinueOnError)
+		fs.Bool("json", false, "")
+		tab := fs.Int("tab", 0, "")
+		value, err := parseWebValueArgs(fs, tt.args)
+		if err != nil || value != tt.value || *tab != tt.tab {
+			t.Errorf("%q: value=%q tab=%d err=%v, want %q tab=%d", tt.args, value, *tab, err, tt.value, tt.tab)
+		}
+	}
+}
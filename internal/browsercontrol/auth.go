@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 )
 
 type TokenCandidate struct {
@@ -15,19 +16,74 @@ type TokenCandidate struct {
 
 var jwtLike = regexp.MustCompile(`^[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+$`)
 
+// idbPollAttempts and idbPollDelay bound how long TokenCandidates waits for the
+// page's asynchronous IndexedDB scan.
+const (
+	idbPollAttempts = 15
+	idbPollDelay    = 200 * time.Millisecond
+)
+
+// TokenCandidates collects tokenish values from Web Storage, cookies and IndexedDB.
+// SourceKey records where each was found, e.g. "localStorage:user/session.accessToken"
+// or "indexedDB:db/store/key/accessToken". IndexedDB results are best-effort: if the
+// scan has not finished in time, the other candidates are returned alone.
 func (c *Controller) TokenCandidates(ctx context.Context) ([]TokenCandidate, error) {
+	defer c.hold(ctx)()
 	out, err := c.runJS(ctx, jsExtractTokenCandidates())
 	if err != nil {
 		return nil, err
 	}
 
-	var cands []TokenCandidate
-	if err := json.Unmarshal([]byte(out), &cands); err != nil {
+	var res struct {
+		Candidates []TokenCandidate `json:"candidates"`
+		IndexedDB  bool             `json:"indexedDB"` // scan started
+	}
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
 		return nil, fmt.Errorf("unexpected JS result: %q", out)
 	}
+	cands := res.Candidates
+	if !res.IndexedDB {
+		return cands, nil
+	}
+
+	for i := 0; i < idbPollAttempts; i++ {
+		select {
+		case <-ctx.Done():
+			return cands, nil
+		case <-time.After(idbPollDelay):
+		}
+		out, err := c.runJS(ctx, jsIndexedDBTokenCandidates())
+		if err != nil {
+			return nil, err
+		}
+		var poll struct {
+			Done       bool             `json:"done"`
+			Candidates []TokenCandidate `json:"candidates"`
+		}
+		if err := json.Unmarshal([]byte(out), &poll); err != nil {
+			return nil, fmt.Errorf("unexpected JS result: %q", out)
+		}
+		if poll.Done {
+			return appendNewCandidates(cands, poll.Candidates), nil
+		}
+	}
 	return cands, nil
 }
 
+func appendNewCandidates(cands, more []TokenCandidate) []TokenCandidate {
+	seen := make(map[TokenCandidate]bool, len(cands))
+	for _, c := range cands {
+		seen[c] = true
+	}
+	for _, c := range more {
+		if !seen[c] {
+			seen[c] = true
+			cands = append(cands, c)
+		}
+	}
+	return cands
+}
+
 func scoreTokenCandidate(c TokenCandidate) int {
 	score := 0
 	k := strings.ToLower(c.SourceKey)
@@ -48,8 +104,15 @@ func scoreTokenCandidate(c TokenCandidate) int {
 	return score
 }
 
+// idbScanKey is the window property holding the IndexedDB scan started by
+// jsExtractTokenCandidates until jsIndexedDBTokenCandidates collects it.
+const idbScanKey = "__pocketcastsctlIDBTokens"
+
 func jsExtractTokenCandidates() string {
 	// Only return values that look like tokens to avoid leaking unrelated storage data.
+	// Web Storage and cookies are read synchronously. IndexedDB is asynchronous and
+	// AppleScript can't await a promise, so its scan is started here and collected by
+	// jsIndexedDBTokenCandidates.
 	return `(function(){
   function isJwtLike(s){
     return typeof s === 'string' && /^[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+$/.test(s);
@@ -64,20 +127,28 @@ func jsExtractTokenCandidates() string {
     return /^[A-Za-z0-9._=-]{20,4096}$/.test(t);
   }
 
-  function findInObject(obj, out){
-    if (!obj || typeof obj !== 'object') return;
+  function isAuthKey(k){
+    k = String(k).toLowerCase();
+    return k.includes('token') || k.includes('auth') || k.includes('session');
+  }
+
+  // prefix is the store path so far; nested keys are joined with '.'.
+  function findInObject(obj, prefix, out, depth, seen){
+    if (!obj || typeof obj !== 'object' || depth > 8 || seen.has(obj)) return;
+    seen.add(obj);
     if (Array.isArray(obj)){
-      for (const v of obj) findInObject(v, out);
+      obj.forEach(function(v, i){ findInObject(v, prefix + '[' + i + ']', out, depth+1, seen); });
       return;
     }
     for (const k of Object.keys(obj)){
       const v = obj[k];
+      const path = /[\/:]$/.test(prefix) || prefix === '' ? prefix + k : prefix + '.' + k;
       if (typeof v === 'string'){
-        if (isTokenish(v) && (k.toLowerCase().includes('token') || k.toLowerCase().includes('auth') || k.toLowerCase().includes('session'))) {
-          out.push({sourceKey: k, token: v});
+        if (isTokenish(v) && isAuthKey(k)) {
+          out.push({sourceKey: path, token: v});
         }
       } else if (v && typeof v === 'object'){
-        findInObject(v, out);
+        findInObject(v, path, out, depth+1, seen);
       }
     }
   }
@@ -89,18 +160,107 @@ func jsExtractTokenCandidates() string {
       const key = store.key(i);
       const val = store.getItem(key);
       if (!val) continue;
-      if (isTokenish(val) && (key.toLowerCase().includes('token') || key.toLowerCase().includes('auth') || key.toLowerCase().includes('session'))) {
+      if (isTokenish(val) && isAuthKey(key)) {
         out.push({sourceKey: label + ":" + key, token: val});
       }
       try {
         const parsed = JSON.parse(val);
-        findInObject(parsed, out);
+        findInObject(parsed, label + ":" + key + "/", out, 0, new Set());
       } catch (e) {}
     }
   }
 
+  function extractFromCookies(){
+    for (const part of (document.cookie || '').split(';')){
+      const i = part.indexOf('=');
+      if (i < 0) continue;
+      const name = part.slice(0, i).trim();
+      let val = part.slice(i+1).trim();
+      try { val = decodeURIComponent(val); } catch (e) {}
+      if (isTokenish(val) && isAuthKey(name)) {
+        out.push({sourceKey: "cookie:" + name, token: val});
+      }
+    }
+  }
+
+  function openDB(name){
+    return new Promise(function(resolve, reject){
+      const req = indexedDB.open(name);
+      // The database vanished since databases() listed it; don't create it.
+      req.onupgradeneeded = function(){ req.transaction.abort(); };
+      req.onsuccess = function(){ resolve(req.result); };
+      req.onerror = function(){ reject(req.error); };
+      req.onblocked = function(){ reject(new Error('blocked')); };
+    });
+  }
+
+  function scanStore(db, dbName, storeName, found){
+    return new Promise(function(resolve){
+      let tx;
+      try { tx = db.transaction(storeName, 'readonly'); } catch (e) { resolve(); return; }
+      const req = tx.objectStore(storeName).openCursor();
+      let n = 0;
+      req.onsuccess = function(){
+        const cur = req.result;
+        if (!cur || n++ >= 500) { resolve(); return; }
+        const base = 'indexedDB:' + dbName + '/' + storeName + '/' + String(cur.primaryKey);
+        const v = cur.value;
+        if (typeof v === 'string'){
+          if (isTokenish(v) && (isAuthKey(cur.primaryKey) || isAuthKey(storeName))) {
+            found.push({sourceKey: base, token: v});
+          }
+        } else {
+          findInObject(v, base + '/', found, 0, new Set());
+        }
+        cur.continue();
+      };
+      req.onerror = function(){ resolve(); };
+    });
+  }
+
   try { extractFromStorage(localStorage, 'localStorage'); } catch(e){}
   try { extractFromStorage(sessionStorage, 'sessionStorage'); } catch(e){}
-  return JSON.stringify(out);
+  try { extractFromCookies(); } catch(e){}
+
+  let idb = false;
+  try {
+    if (window.indexedDB && typeof indexedDB.databases === 'function'){
+      const state = {done:false, out:[]};
+      window["` + idbScanKey + `"] = state;
+      idb = true;
+      (async function(){
+        const found = [];
+        try {
+          for (const info of await indexedDB.databases()){
+            if (!info.name) continue;
+            let db;
+            try { db = await openDB(info.name); } catch (e) { continue; }
+            try {
+              for (const storeName of Array.from(db.objectStoreNames)){
+                await scanStore(db, info.name, storeName, found);
+              }
+            } finally {
+              db.close();
+            }
+          }
+        } catch (e) {}
+        state.out = found;
+        state.done = true;
+      })();
+    }
+  } catch(e){}
+
+  return JSON.stringify({candidates: out, indexedDB: idb});
+})()`
+}
+
+func jsIndexedDBTokenCandidates() string {
+	return `(function(){
+  const key = "` + idbScanKey + `";
+  const state = window[key];
+  if (!state) return JSON.stringify({done:true, candidates:[]});
+  if (!state.done) return JSON.stringify({done:false});
+  delete window[key];
+  return JSON.stringify({done:true, candidates: state.out});
 })()`
 }
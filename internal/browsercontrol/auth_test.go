@@ -0,0 +1,48 @@
+package browsercontrol
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestTokenCandidatesCollectsIndexedDBScan(t *testing.T) {
+	c, r := newFakeController(t, "chrome",
+		`{"candidates":[{"sourceKey":"localStorage:authToken","token":"a.b.c"},{"sourceKey":"cookie:session","token":"c0ffee"}],"indexedDB":true}`,
+		`{"done":false}`,
+		`{"done":true,"candidates":[{"sourceKey":"indexedDB:pc/keyval/auth/accessToken","token":"x.y.z"},{"sourceKey":"cookie:session","token":"c0ffee"}]}`,
+	)
+	cands, err := c.TokenCandidates(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, c := range cands {
+		keys = append(keys, c.SourceKey)
+	}
+	if got := strings.Join(keys, ","); got != "localStorage:authToken,cookie:session,indexedDB:pc/keyval/auth/accessToken" {
+		t.Fatalf("keys=%s", got)
+	}
+	if len(r.calls) != 3 || r.calls[1].args[4] != jsIndexedDBTokenCandidates() {
+		t.Fatalf("expected two polls of the IndexedDB scan, calls=%d", len(r.calls))
+	}
+}
+
+func TestTokenCandidatesWithoutIndexedDB(t *testing.T) {
+	c, r := newFakeController(t, "safari", `{"candidates":[],"indexedDB":false}`)
+	cands, err := c.TokenCandidates(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cands) != 0 || len(r.calls) != 1 {
+		t.Fatalf("cands=%v calls=%d", cands, len(r.calls))
+	}
+}
+
+func TestScoreTokenCandidatePrefersJWTAccessTokens(t *testing.T) {
+	jwt := TokenCandidate{SourceKey: "indexedDB:pc/keyval/auth/accessToken", Token: "aaa.bbb.ccc"}
+	opaque := TokenCandidate{SourceKey: "cookie:session", Token: "0123456789abcdef0123456789abcdef0123456789"}
+	if scoreTokenCandidate(jwt) <= scoreTokenCandidate(opaque) {
+		t.Fatalf("jwt=%d opaque=%d", scoreTokenCandidate(jwt), scoreTokenCandidate(opaque))
+	}
+}
@@ -0,0 +1,374 @@
+package browsercontrol
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"pocketcastsctl/internal/websocket"
+)
+
+// bidiEndTimeout bounds session.end when a session is closed.
+const bidiEndTimeout = 2 * time.Second
+
+// bidiBackend talks WebDriver BiDi to Firefox started with --remote-debugging-port.
+// Firefox allows one session at a time, so a session is only kept open while a
+// Controller call holds it; calls outside a hold open a session and end it.
+type bidiBackend struct {
+	wsURL string
+
+	mu    sync.Mutex
+	sess  *bidiSession // shared while holds > 0
+	holds int
+}
+
+func newBiDiBackend(raw string) (*bidiBackend, error) {
+	raw = strings.TrimRight(strings.TrimSpace(raw), "/")
+	if !strings.Contains(raw, "://") {
+		raw = "ws://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bidi-url: %w", err)
+	}
+	switch u.Scheme {
+	case "ws", "wss":
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	default:
+		return nil, fmt.Errorf("bidi-url must be a ws:// URL (e.g. %s)", DefaultBiDiURL)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("bidi-url must be a ws:// URL (e.g. %s)", DefaultBiDiURL)
+	}
+	if u.Path == "" {
+		u.Path = "/session"
+	}
+	return &bidiBackend{wsURL: u.String()}, nil
+}
+
+// hold opens a session (or reuses the held one) for the calls made until release,
+// so one command pays for the session handshake once.
+func (b *bidiBackend) hold(ctx context.Context) (release func(), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.holds == 0 {
+		sess, err := dialBiDi(ctx, b.wsURL)
+		if err != nil {
+			return nil, err
+		}
+		b.sess = sess
+	}
+	b.holds++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			b.holds--
+			var sess *bidiSession
+			if b.holds == 0 {
+				sess, b.sess = b.sess, nil
+			}
+			b.mu.Unlock()
+			if sess != nil {
+				_ = sess.Close()
+			}
+		})
+	}, nil
+}
+
+// session returns the held session, or opens one for a single call; done ends it.
+func (b *bidiBackend) session(ctx context.Context) (sess *bidiSession, done func(), err error) {
+	b.mu.Lock()
+	sess = b.sess
+	b.mu.Unlock()
+	if sess != nil {
+		return sess, func() {}, nil
+	}
+	sess, err = dialBiDi(ctx, b.wsURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sess, func() { _ = sess.Close() }, nil
+}
+
+func (b *bidiBackend) describe() string {
+	return "via WebDriver BiDi (" + b.wsURL + ")"
+}
+
+type bidiContext struct {
+	Context      string `json:"context"`
+	URL          string `json:"url"`
+	ClientWindow string `json:"clientWindow"`
+}
+
+// tabs lists top-level browsing contexts in tree order (tab order within a window).
+// Windows are numbered by first appearance; Firefox versions that don't report
+// clientWindow leave Window at 0, like CDP.
+func (b *bidiBackend) tabs(ctx context.Context) ([]Tab, error) {
+	sess, done, err := b.session(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	var tree struct {
+		Contexts []bidiContext `json:"contexts"`
+	}
+	if err := sess.call(ctx, "browsingContext.getTree", map[string]any{"maxDepth": 0}, &tree); err != nil {
+		return nil, err
+	}
+	// The title isn't part of the tree. Fetching it is best-effort, and the
+	// requests go out together rather than one round trip per tab.
+	titles := make([]string, len(tree.Contexts))
+	var wg sync.WaitGroup
+	for i, c := range tree.Contexts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			titles[i], _ = sess.evaluate(ctx, c.Context, "document.title")
+		}()
+	}
+	wg.Wait()
+
+	windows := map[string]int{}
+	perWindow := map[int]int{}
+	tabs := make([]Tab, 0, len(tree.Contexts))
+	for i, c := range tree.Contexts {
+		w := 0
+		if c.ClientWindow != "" {
+			if _, ok := windows[c.ClientWindow]; !ok {
+				windows[c.ClientWindow] = len(windows) + 1
+			}
+			w = windows[c.ClientWindow]
+		}
+		perWindow[w]++
+		tabs = append(tabs, Tab{Window: w, Index: perWindow[w], Title: titles[i], URL: c.URL, ref: c.Context})
+	}
+	return tabs, nil
+}
+
+func (b *bidiBackend) runJS(ctx context.Context, tab Tab, js string, focus bool) (string, error) {
+	sess, done, err := b.session(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer done()
+	if focus {
+		// Background tabs throttle timers, which the queue and token scripts poll with.
+		_ = sess.call(ctx, "browsingContext.activate", map[string]any{"context": tab.ref}, nil)
+	}
+	return sess.evaluate(ctx, tab.ref, js)
+}
+
+func (b *bidiBackend) setTabURL(ctx context.Context, tab Tab, newURL string) error {
+	sess, done, err := b.session(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	return sess.call(ctx, "browsingContext.navigate", map[string]any{
+		"context": tab.ref,
+		"url":     newURL,
+		"wait":    "none",
+	}, nil)
+}
+
+func (b *bidiBackend) watcher(target func(context.Context) (Tab, error)) statusWatcher {
+	return &bidiWatcher{b: b, target: target}
+}
+
+// bidiWatcher keeps one session open across polls, as cdpWatcher does. Page
+// events aren't used, so it never wakes early.
+type bidiWatcher struct {
+	b       *bidiBackend
+	target  func(context.Context) (Tab, error)
+	release func()
+}
+
+func (w *bidiWatcher) status(ctx context.Context) (string, error) {
+	if w.release == nil {
+		release, err := w.b.hold(ctx)
+		if err != nil {
+			return "", err
+		}
+		w.release = release
+	}
+	t, err := w.target(ctx)
+	if err != nil {
+		return "", err
+	}
+	return w.b.runJS(ctx, t, jsStatus(), false)
+}
+
+func (w *bidiWatcher) wake() <-chan struct{} { return nil }
+
+func (w *bidiWatcher) close() {
+	if w.release != nil {
+		w.release()
+		w.release = nil
+	}
+}
+
+// bidiSession is one WebDriver BiDi session. Like cdpSession, a reader goroutine
+// routes replies to callers by id; events are not used.
+type bidiSession struct {
+	conn *websocket.Conn
+
+	mu      sync.Mutex
+	nextID  int
+	pending map[int]chan bidiMessage
+
+	done chan struct{}
+	err  error // why the reader stopped; set before done is closed
+}
+
+type bidiMessage struct {
+	Type    string          `json:"type"` // success|error|event
+	ID      int             `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   string          `json:"error"`
+	Message string          `json:"message"`
+}
+
+// dialBiDi connects and starts a session.
+func dialBiDi(ctx context.Context, wsURL string) (*bidiSession, error) {
+	conn, err := websocket.Dial(ctx, wsURL)
+	if err != nil {
+		return nil, fmt.Errorf("bidi: %w (is Firefox running with --remote-debugging-port?)", err)
+	}
+	s := &bidiSession{
+		conn:    conn,
+		pending: map[int]chan bidiMessage{},
+		done:    make(chan struct{}),
+	}
+	go s.readLoop()
+	if err := s.call(ctx, "session.new", map[string]any{"capabilities": map[string]any{}}, nil); err != nil {
+		_ = s.conn.Close()
+		<-s.done
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *bidiSession) readLoop() {
+	defer close(s.done)
+	for {
+		b, err := s.conn.ReadMessage()
+		if err != nil {
+			s.err = err
+			return
+		}
+		var m bidiMessage
+		if err := json.Unmarshal(b, &m); err != nil || m.Type == "event" || m.ID == 0 {
+			continue
+		}
+		s.mu.Lock()
+		ch := s.pending[m.ID]
+		delete(s.pending, m.ID)
+		s.mu.Unlock()
+		if ch != nil {
+			ch <- m
+		}
+	}
+}
+
+// call sends one command and decodes its result into result (if non-nil).
+func (s *bidiSession) call(ctx context.Context, method string, params, result any) error {
+	s.mu.Lock()
+	s.nextID++
+	id := s.nextID
+	ch := make(chan bidiMessage, 1)
+	s.pending[id] = ch
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, id)
+		s.mu.Unlock()
+	}()
+
+	req, err := json.Marshal(map[string]any{"id": id, "method": method, "params": params})
+	if err != nil {
+		return err
+	}
+	if err := s.conn.WriteMessage(req); err != nil {
+		return fmt.Errorf("bidi: %w", err)
+	}
+
+	var resp bidiMessage
+	select {
+	case resp = <-ch:
+	case <-s.done:
+		return fmt.Errorf("bidi: %w", s.err)
+	case <-ctx.Done():
+		return fmt.Errorf("bidi: %s: %w", method, ctx.Err())
+	}
+	if resp.Type == "error" {
+		if resp.Error == "session not created" {
+			return fmt.Errorf("bidi: %s: %s (is another WebDriver client connected?)", method, resp.Message)
+		}
+		return fmt.Errorf("bidi: %s: %s: %s", method, resp.Error, resp.Message)
+	}
+	if result == nil || len(resp.Result) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return errors.New("bidi: unexpected result for " + method)
+	}
+	return nil
+}
+
+// evaluate runs js in the browsing context and returns its (string) value.
+func (s *bidiSession) evaluate(ctx context.Context, browsingContext, js string) (string, error) {
+	var res struct {
+		Type   string `json:"type"` // success|exception
+		Result struct {
+			Type  string          `json:"type"`
+			Value json.RawMessage `json:"value"`
+		} `json:"result"`
+		ExceptionDetails struct {
+			Text string `json:"text"`
+		} `json:"exceptionDetails"`
+	}
+	err := s.call(ctx, "script.evaluate", map[string]any{
+		"expression":      js,
+		"target":          map[string]any{"context": browsingContext},
+		"awaitPromise":    true,
+		"resultOwnership": "none",
+	}, &res)
+	if err != nil {
+		return "", err
+	}
+	if res.Type == "exception" {
+		return "", fmt.Errorf("JavaScript error: %s", res.ExceptionDetails.Text)
+	}
+	switch res.Result.Type {
+	case "string":
+		var str string
+		if err := json.Unmarshal(res.Result.Value, &str); err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(str), nil
+	case "undefined", "null":
+		return "", nil
+	}
+	// Our scripts return JSON strings; other primitives are passed through as JSON.
+	return strings.TrimSpace(string(res.Result.Value)), nil
+}
+
+// Close ends the session so the next command can start one, then disconnects.
+func (s *bidiSession) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), bidiEndTimeout)
+	defer cancel()
+	_ = s.call(ctx, "session.end", map[string]any{}, nil)
+	err := s.conn.Close()
+	<-s.done
+	return err
+}
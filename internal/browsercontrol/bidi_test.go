@@ -0,0 +1,269 @@
+package browsercontrol
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pocketcastsctl/internal/websocket"
+)
+
+// fakeBiDi serves a WebDriver BiDi endpoint at /session that, like Firefox, allows
+// one session at a time.
+type fakeBiDi struct {
+	srv *httptest.Server
+
+	mu       sync.Mutex
+	contexts []map[string]any // context, url, clientWindow
+	calls    []string
+	active   bool // a session is open
+	evalExpr []string
+	// evaluate returns the script.evaluate result for an expression.
+	evaluate func(expr string) map[string]any
+}
+
+func newFakeBiDi(t *testing.T) *fakeBiDi {
+	t.Helper()
+	f := &fakeBiDi{
+		contexts: []map[string]any{
+			{"context": "c1", "url": "https://example.com/", "clientWindow": "w2"},
+			{"context": "c2", "url": "https://play.pocketcasts.com/podcasts", "clientWindow": "w2"},
+			{"context": "c3", "url": "https://play.pocketcasts.com/up-next", "clientWindow": "w1"},
+		},
+	}
+	f.evaluate = func(expr string) map[string]any {
+		if expr == "document.title" {
+			return map[string]any{"type": "success", "result": map[string]any{"type": "string", "value": "Pocket Casts"}}
+		}
+		return map[string]any{"type": "success", "result": map[string]any{"type": "string", "value": `{"clicked":true,"clickedLabel":"Play"}`}}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/session", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var req struct {
+				ID     int            `json:"id"`
+				Method string         `json:"method"`
+				Params map[string]any `json:"params"`
+			}
+			if err := json.Unmarshal(msg, &req); err != nil {
+				return
+			}
+			resp := f.handle(req.Method, req.Params)
+			resp["id"] = req.ID
+			b, _ := json.Marshal(resp)
+			// An unrelated event first, as real browsers interleave them.
+			_ = conn.WriteMessage([]byte(`{"type":"event","method":"log.entryAdded","params":{}}`))
+			_ = conn.WriteMessage(b)
+		}
+	})
+	f.srv = httptest.NewServer(mux)
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+func (f *fakeBiDi) handle(method string, params map[string]any) map[string]any {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, method)
+	ok := func(result any) map[string]any { return map[string]any{"type": "success", "result": result} }
+	switch method {
+	case "session.new":
+		if f.active {
+			return map[string]any{"type": "error", "error": "session not created", "message": "Maximum number of active sessions"}
+		}
+		f.active = true
+		return ok(map[string]any{"sessionId": "s1", "capabilities": map[string]any{}})
+	case "session.end":
+		f.active = false
+		return ok(map[string]any{})
+	}
+	if !f.active {
+		return map[string]any{"type": "error", "error": "invalid session id", "message": "no session"}
+	}
+	switch method {
+	case "browsingContext.getTree":
+		return ok(map[string]any{"contexts": f.contexts})
+	case "script.evaluate":
+		expr, _ := params["expression"].(string)
+		f.evalExpr = append(f.evalExpr, expr)
+		return ok(f.evaluate(expr))
+	case "browsingContext.navigate":
+		target, _ := params["context"].(string)
+		for _, c := range f.contexts {
+			if c["context"] == target {
+				c["url"] = params["url"]
+			}
+		}
+		return ok(map[string]any{"navigation": "n1", "url": params["url"]})
+	case "browsingContext.activate":
+		return ok(map[string]any{})
+	}
+	return map[string]any{"type": "error", "error": "unknown command", "message": method}
+}
+
+func newBiDiController(t *testing.T, f *fakeBiDi) *Controller {
+	t.Helper()
+	c, err := New(Options{Browser: "firefox", BiDiURL: strings.TrimPrefix(f.srv.URL, "http://"), URLContains: "pocketcasts.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestBiDiDoEvaluatesActionJS(t *testing.T) {
+	f := newFakeBiDi(t)
+	c := newBiDiController(t, f)
+
+	res, err := c.Do(testContext(t), ActionPlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ClickedLabel != "Play" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if last := f.evalExpr[len(f.evalExpr)-1]; last != jsForAction(ActionPlay) {
+		t.Fatalf("expected the shared action JS to be evaluated, got %q", last)
+	}
+	if f.active {
+		t.Fatal("session was not ended")
+	}
+	if !strings.Contains(strings.Join(f.calls, ","), "browsingContext.activate,script.evaluate,session.end") {
+		t.Fatalf("calls=%v", f.calls)
+	}
+	// Listing tabs, fetching titles, probing for playback and the action share one session.
+	if n := countCalls(f.calls, "session.new"); n != 1 {
+		t.Fatalf("opened %d sessions: %v", n, f.calls)
+	}
+}
+
+func countCalls(calls []string, method string) int {
+	n := 0
+	for _, c := range calls {
+		if c == method {
+			n++
+		}
+	}
+	return n
+}
+
+func TestBiDiTabsAndNavigate(t *testing.T) {
+	f := newFakeBiDi(t)
+	c := newBiDiController(t, f)
+	ctx := testContext(t)
+
+	tabs, err := c.Tabs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tabs) != 3 || tabs[1].Window != 1 || tabs[1].Index != 2 || tabs[2].Window != 2 || tabs[2].Index != 1 || tabs[0].Title != "Pocket Casts" {
+		t.Fatalf("tabs=%+v", tabs)
+	}
+
+	if err := c.SetTabURL(ctx, "https://play.pocketcasts.com/episode/abc"); err != nil {
+		t.Fatal(err)
+	}
+	urls, err := c.TabURLs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if urls[1] != "https://play.pocketcasts.com/episode/abc" {
+		t.Fatalf("navigate not applied: %v", urls)
+	}
+}
+
+func TestBiDiErrors(t *testing.T) {
+	f := newFakeBiDi(t)
+	f.evaluate = func(string) map[string]any {
+		return map[string]any{"type": "exception", "exceptionDetails": map[string]any{"text": "ReferenceError: x is not defined"}}
+	}
+	_, err := newBiDiController(t, f).Status(testContext(t))
+	if err == nil || !strings.Contains(err.Error(), "JavaScript error: ReferenceError") {
+		t.Fatalf("expected exception to surface, got %v", err)
+	}
+
+	f.mu.Lock()
+	f.active = true // another WebDriver client holds the session
+	f.mu.Unlock()
+	_, err = newBiDiController(t, f).Status(testContext(t))
+	if err == nil || !strings.Contains(err.Error(), "another WebDriver client") {
+		t.Fatalf("err=%v", err)
+	}
+}
+
+func TestBiDiWatchKeepsOneSession(t *testing.T) {
+	f := newFakeBiDi(t)
+	polls := 0
+	f.evaluate = func(expr string) map[string]any {
+		if expr != jsStatus() {
+			return map[string]any{"type": "success", "result": map[string]any{"type": "string", "value": `{}`}}
+		}
+		polls++
+		state := "playing"
+		if polls%2 == 0 {
+			state = "paused"
+		}
+		return map[string]any{"type": "success", "result": map[string]any{"type": "string", "value": `{"state":"` + state + `","title":"Ep"}`}}
+	}
+	c := newBiDiController(t, f)
+
+	n := 0
+	errStop := errors.New("stop")
+	err := c.Watch(testContext(t), WatchOptions{MinInterval: time.Millisecond, MaxInterval: time.Millisecond}, func(WatchEvent) error {
+		if n++; n == 3 {
+			return errStop
+		}
+		return nil
+	})
+	if err != errStop {
+		t.Fatalf("Watch: %v", err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if n := countCalls(f.calls, "session.new"); n != 1 || f.active {
+		t.Fatalf("sessions opened=%d, still active=%v: %v", n, f.active, f.calls)
+	}
+	if countCalls(f.calls, "browsingContext.activate") != 0 {
+		t.Fatalf("watch focused the tab: %v", f.calls)
+	}
+}
+
+func TestNewBiDiBackendURL(t *testing.T) {
+	tests := map[string]string{
+		"localhost:9222":             "ws://localhost:9222/session",
+		"http://127.0.0.1:9222/":     "ws://127.0.0.1:9222/session",
+		"ws://127.0.0.1:9222/custom": "ws://127.0.0.1:9222/custom",
+	}
+	for in, want := range tests {
+		b, err := newBiDiBackend(in)
+		if err != nil || b.wsURL != want {
+			t.Errorf("newBiDiBackend(%q) = %v, %v; want %s", in, b, err, want)
+		}
+	}
+	if _, err := newBiDiBackend("ftp://localhost"); err == nil {
+		t.Fatal("expected error for ftp URL")
+	}
+
+	c, err := New(Options{Browser: "Firefox", URLContains: "pocketcasts.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.backend.describe(); got != "via WebDriver BiDi ("+DefaultBiDiURL+")" {
+		t.Fatalf("describe=%q", got)
+	}
+}
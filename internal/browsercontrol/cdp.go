@@ -0,0 +1,355 @@
+package browsercontrol
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"pocketcastsctl/internal/websocket"
+)
+
+// cdpBackend talks the Chrome DevTools Protocol to a browser started with
+// --remote-debugging-port. Targets are discovered through the HTTP /json endpoint.
+type cdpBackend struct {
+	baseURL string
+	http    *http.Client
+}
+
+type cdpTarget struct {
+	ID                   string `json:"id"`
+	Type                 string `json:"type"`
+	Title                string `json:"title"`
+	URL                  string `json:"url"`
+	WebSocketDebuggerURL string `json:"webSocketDebuggerUrl"`
+}
+
+func newCDPBackend(raw string) (*cdpBackend, error) {
+	raw = strings.TrimRight(strings.TrimSpace(raw), "/")
+	switch {
+	case strings.HasPrefix(raw, "http://"), strings.HasPrefix(raw, "https://"):
+	case strings.HasPrefix(raw, "ws://"), strings.HasPrefix(raw, "wss://"):
+		return nil, fmt.Errorf("cdp-url must be the HTTP endpoint (e.g. %s), not a WebSocket URL", DefaultCDPURL)
+	default:
+		raw = "http://" + raw
+	}
+	return &cdpBackend{baseURL: raw, http: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+func (b *cdpBackend) targets(ctx context.Context) ([]cdpTarget, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.baseURL+"/json/list", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cdp: %w (is the browser running with --remote-debugging-port?)", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("cdp: http %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var targets []cdpTarget
+	if err := json.Unmarshal(body, &targets); err != nil {
+		return nil, fmt.Errorf("cdp: unexpected /json/list result: %w", err)
+	}
+	pages := targets[:0]
+	for _, t := range targets {
+		if t.Type == "page" {
+			pages = append(pages, t)
+		}
+	}
+	return pages, nil
+}
+
+func (b *cdpBackend) describe() string {
+	return "via CDP (" + b.baseURL + ")"
+}
+
+// tabs orders pages by target id. That order is arbitrary (ids are random and
+// unrelated to the tab strip), but it is stable while the tabs are open, whereas
+// /json/list follows tab activation and would renumber tabs whenever one is
+// brought forward.
+func (b *cdpBackend) tabs(ctx context.Context) ([]Tab, error) {
+	targets, err := b.targets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(targets, func(i, j int) bool { return targets[i].ID < targets[j].ID })
+	tabs := make([]Tab, 0, len(targets))
+	for i, t := range targets {
+		tabs = append(tabs, Tab{Index: i + 1, Title: t.Title, URL: t.URL, ref: t.WebSocketDebuggerURL})
+	}
+	return tabs, nil
+}
+
+func (b *cdpBackend) dial(ctx context.Context, tab Tab) (*cdpSession, error) {
+	if tab.ref == "" {
+		return nil, fmt.Errorf("tab %s is already attached to another DevTools client", tab.URL)
+	}
+	return dialCDP(ctx, tab.ref)
+}
+
+func (b *cdpBackend) runJS(ctx context.Context, tab Tab, js string, focus bool) (string, error) {
+	sess, err := b.dial(ctx, tab)
+	if err != nil {
+		return "", err
+	}
+	defer sess.Close()
+	if focus {
+		// Best effort, as with the other backends: the script runs either way.
+		_ = sess.call(ctx, "Page.bringToFront", map[string]any{}, nil)
+	}
+	return sess.evaluate(ctx, js)
+}
+
+func (b *cdpBackend) setTabURL(ctx context.Context, tab Tab, newURL string) error {
+	sess, err := b.dial(ctx, tab)
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+	var res struct {
+		ErrorText string `json:"errorText"`
+	}
+	if err := sess.call(ctx, "Page.navigate", map[string]any{"url": newURL}, &res); err != nil {
+		return err
+	}
+	if res.ErrorText != "" {
+		return fmt.Errorf("navigate failed: %s", res.ErrorText)
+	}
+	return nil
+}
+
+// cdpWatchBinding is exposed to the page with Runtime.addBinding; the hook installed
+// by jsWatchHook calls it on media events so Watch can poll immediately.
+const cdpWatchBinding = "__pocketcastsctlWatch"
+
+func (b *cdpBackend) watcher(target func(context.Context) (Tab, error)) statusWatcher {
+	return &cdpWatcher{b: b, target: target, wakeCh: make(chan struct{}, 1)}
+}
+
+// cdpWatcher keeps one session to the Web Player tab open across polls.
+type cdpWatcher struct {
+	b      *cdpBackend
+	target func(context.Context) (Tab, error)
+	sess   *cdpSession
+	wakeCh chan struct{}
+}
+
+func (w *cdpWatcher) status(ctx context.Context) (string, error) {
+	if w.sess == nil {
+		t, err := w.target(ctx)
+		if err != nil {
+			return "", err
+		}
+		sess, err := w.b.dial(ctx, t)
+		if err != nil {
+			return "", err
+		}
+		// Events are an optimisation; polling still works if these fail.
+		_ = sess.call(ctx, "Runtime.enable", map[string]any{}, nil)
+		_ = sess.call(ctx, "Runtime.addBinding", map[string]any{"name": cdpWatchBinding}, nil)
+		go w.forwardEvents(sess)
+		w.sess = sess
+	}
+	return w.sess.evaluate(ctx, jsWatchHook(cdpWatchBinding)+";\n"+jsStatus())
+}
+
+func (w *cdpWatcher) forwardEvents(sess *cdpSession) {
+	for {
+		select {
+		case m := <-sess.events:
+			if m.Method != "Runtime.bindingCalled" {
+				continue
+			}
+			select {
+			case w.wakeCh <- struct{}{}:
+			default:
+			}
+		case <-sess.closed():
+			return
+		}
+	}
+}
+
+func (w *cdpWatcher) wake() <-chan struct{} { return w.wakeCh }
+
+func (w *cdpWatcher) close() {
+	if w.sess != nil {
+		_ = w.sess.Close()
+		w.sess = nil
+	}
+}
+
+// cdpSession is a connection to one target. A reader goroutine routes replies to
+// callers by id and forwards events, so a session can serve many calls.
+type cdpSession struct {
+	conn   *websocket.Conn
+	events chan cdpMessage // best-effort: dropped when nobody is reading
+
+	mu      sync.Mutex
+	nextID  int
+	pending map[int]chan cdpMessage
+
+	done chan struct{}
+	err  error // why the reader stopped; set before done is closed
+}
+
+type cdpMessage struct {
+	ID     int             `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func dialCDP(ctx context.Context, wsURL string) (*cdpSession, error) {
+	conn, err := websocket.Dial(ctx, wsURL)
+	if err != nil {
+		return nil, fmt.Errorf("cdp: %w", err)
+	}
+	s := &cdpSession{
+		conn:    conn,
+		events:  make(chan cdpMessage, 16),
+		pending: map[int]chan cdpMessage{},
+		done:    make(chan struct{}),
+	}
+	go s.readLoop()
+	return s, nil
+}
+
+func (s *cdpSession) readLoop() {
+	defer close(s.done)
+	for {
+		b, err := s.conn.ReadMessage()
+		if err != nil {
+			s.err = err
+			return
+		}
+		var m cdpMessage
+		if err := json.Unmarshal(b, &m); err != nil {
+			continue
+		}
+		if m.ID == 0 {
+			select {
+			case s.events <- m:
+			default:
+			}
+			continue
+		}
+		s.mu.Lock()
+		ch := s.pending[m.ID]
+		delete(s.pending, m.ID)
+		s.mu.Unlock()
+		if ch != nil {
+			ch <- m
+		}
+	}
+}
+
+// call sends one command and decodes its result into result (if non-nil).
+func (s *cdpSession) call(ctx context.Context, method string, params, result any) error {
+	s.mu.Lock()
+	s.nextID++
+	id := s.nextID
+	ch := make(chan cdpMessage, 1)
+	s.pending[id] = ch
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, id)
+		s.mu.Unlock()
+	}()
+
+	req, err := json.Marshal(map[string]any{"id": id, "method": method, "params": params})
+	if err != nil {
+		return err
+	}
+	if err := s.conn.WriteMessage(req); err != nil {
+		return fmt.Errorf("cdp: %w", err)
+	}
+
+	var resp cdpMessage
+	select {
+	case resp = <-ch:
+	case <-s.done:
+		return fmt.Errorf("cdp: %w", s.err)
+	case <-ctx.Done():
+		return fmt.Errorf("cdp: %s: %w", method, ctx.Err())
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("cdp: %s: %s (%d)", method, resp.Error.Message, resp.Error.Code)
+	}
+	if result == nil || len(resp.Result) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return errors.New("cdp: unexpected result for " + method)
+	}
+	return nil
+}
+
+// evaluate runs js in the page and returns its (string) value.
+func (s *cdpSession) evaluate(ctx context.Context, js string) (string, error) {
+	var res struct {
+		Result struct {
+			Type  string          `json:"type"`
+			Value json.RawMessage `json:"value"`
+		} `json:"result"`
+		ExceptionDetails *struct {
+			Text      string `json:"text"`
+			Exception struct {
+				Description string `json:"description"`
+			} `json:"exception"`
+		} `json:"exceptionDetails"`
+	}
+	err := s.call(ctx, "Runtime.evaluate", map[string]any{
+		"expression":    js,
+		"returnByValue": true,
+		"awaitPromise":  true,
+	}, &res)
+	if err != nil {
+		return "", err
+	}
+	if ex := res.ExceptionDetails; ex != nil {
+		msg := ex.Exception.Description
+		if msg == "" {
+			msg = ex.Text
+		}
+		return "", fmt.Errorf("JavaScript error: %s", msg)
+	}
+	if res.Result.Type == "string" {
+		var str string
+		if err := json.Unmarshal(res.Result.Value, &str); err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(str), nil
+	}
+	// Our scripts return JSON strings; anything else is passed through as JSON.
+	return strings.TrimSpace(string(res.Result.Value)), nil
+}
+
+// closed is closed once the connection has gone away (e.g. the tab was closed).
+func (s *cdpSession) closed() <-chan struct{} {
+	return s.done
+}
+
+func (s *cdpSession) Close() error {
+	err := s.conn.Close()
+	<-s.done
+	return err
+}
@@ -0,0 +1,232 @@
+package browsercontrol
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pocketcastsctl/internal/websocket"
+)
+
+// fakeCDP serves /json/list and a DevTools WebSocket per page target.
+type fakeCDP struct {
+	srv *httptest.Server
+
+	mu       sync.Mutex
+	pages    []cdpTarget
+	calls    []string // method names, in order
+	conns    int      // DevTools WebSocket connections accepted
+	evalExpr []string
+	// evaluate returns the Runtime.evaluate result object for an expression.
+	evaluate func(expr string) map[string]any
+}
+
+func newFakeCDP(t *testing.T, urls ...string) *fakeCDP {
+	t.Helper()
+	f := &fakeCDP{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/json/list", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		_ = json.NewEncoder(w).Encode(append([]cdpTarget{{ID: "sw", Type: "service_worker", URL: "https://pocketcasts.com/sw.js"}}, f.pages...))
+	})
+	mux.HandleFunc("/devtools/page/", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		f.mu.Lock()
+		f.conns++
+		f.mu.Unlock()
+		for {
+			msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var req struct {
+				ID     int            `json:"id"`
+				Method string         `json:"method"`
+				Params map[string]any `json:"params"`
+			}
+			if err := json.Unmarshal(msg, &req); err != nil {
+				return
+			}
+			f.mu.Lock()
+			f.calls = append(f.calls, req.Method)
+			var result any = map[string]any{}
+			switch req.Method {
+			case "Runtime.evaluate":
+				expr, _ := req.Params["expression"].(string)
+				f.evalExpr = append(f.evalExpr, expr)
+				result = f.evaluate(expr)
+			case "Page.navigate":
+				id := strings.TrimPrefix(r.URL.Path, "/devtools/page/")
+				for i := range f.pages {
+					if f.pages[i].ID == id {
+						f.pages[i].URL, _ = req.Params["url"].(string)
+					}
+				}
+				result = map[string]any{"frameId": "f1"}
+			}
+			f.mu.Unlock()
+			// An unrelated event first, as real browsers interleave them.
+			_ = conn.WriteMessage([]byte(`{"method":"Runtime.consoleAPICalled","params":{}}`))
+			if expr, _ := req.Params["expression"].(string); strings.Contains(expr, cdpWatchBinding) {
+				// Pretend the watch hook saw a media event.
+				_ = conn.WriteMessage([]byte(`{"method":"Runtime.bindingCalled","params":{"name":"` + cdpWatchBinding + `","payload":"pause"}}`))
+			}
+			resp, _ := json.Marshal(map[string]any{"id": req.ID, "result": result})
+			_ = conn.WriteMessage(resp)
+		}
+	})
+	f.srv = httptest.NewServer(mux)
+	t.Cleanup(f.srv.Close)
+
+	ws := "ws" + strings.TrimPrefix(f.srv.URL, "http")
+	for i, u := range urls {
+		id := fmt.Sprintf("p%d", i)
+		f.pages = append(f.pages, cdpTarget{ID: id, Type: "page", Title: "tab", URL: u, WebSocketDebuggerURL: ws + "/devtools/page/" + id})
+	}
+	f.evaluate = func(string) map[string]any {
+		return map[string]any{"result": map[string]any{"type": "string", "value": `{"clicked":true,"clickedLabel":"Play"}`}}
+	}
+	return f
+}
+
+func newCDPController(t *testing.T, f *fakeCDP) *Controller {
+	t.Helper()
+	c, err := New(Options{Browser: "cdp", CDPURL: f.srv.URL, URLContains: "pocketcasts.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestCDPDoEvaluatesActionJS(t *testing.T) {
+	f := newFakeCDP(t, "https://example.com/", "https://play.pocketcasts.com/podcasts")
+	c := newCDPController(t, f)
+
+	res, err := c.Do(testContext(t), ActionPlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ClickedLabel != "Play" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if len(f.evalExpr) != 1 || f.evalExpr[0] != jsForAction(ActionPlay) {
+		t.Fatalf("expected the shared action JS to be evaluated, got %q", f.evalExpr)
+	}
+	if got := strings.Join(f.calls, ","); got != "Page.bringToFront,Runtime.evaluate" {
+		t.Fatalf("expected the tab to be brought to the front first, calls=%s", got)
+	}
+}
+
+func TestCDPJavaScriptException(t *testing.T) {
+	f := newFakeCDP(t, "https://play.pocketcasts.com/")
+	f.evaluate = func(string) map[string]any {
+		return map[string]any{
+			"result":           map[string]any{"type": "object"},
+			"exceptionDetails": map[string]any{"text": "Uncaught", "exception": map[string]any{"description": "ReferenceError: x is not defined"}},
+		}
+	}
+	_, err := newCDPController(t, f).Status(testContext(t))
+	if err == nil || !strings.Contains(err.Error(), "ReferenceError") {
+		t.Fatalf("expected exception to surface, got %v", err)
+	}
+}
+
+func TestCDPNoMatchingTab(t *testing.T) {
+	f := newFakeCDP(t, "https://example.com/")
+	_, err := newCDPController(t, f).Do(testContext(t), ActionPause)
+	if err == nil || !strings.Contains(strings.ToLower(err.Error()), "no tab found") {
+		t.Fatalf("expected no tab found error, got %v", err)
+	}
+}
+
+func TestCDPTabURLsAndNavigate(t *testing.T) {
+	f := newFakeCDP(t, "https://example.com/", "https://play.pocketcasts.com/podcasts")
+	c := newCDPController(t, f)
+	ctx := testContext(t)
+
+	urls, err := c.TabURLs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(urls, ",") != "https://example.com/,https://play.pocketcasts.com/podcasts" {
+		t.Fatalf("unexpected urls (service workers must be skipped): %v", urls)
+	}
+
+	if err := c.SetTabURL(ctx, "https://play.pocketcasts.com/episode/abc"); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.pages[1].URL; got != "https://play.pocketcasts.com/episode/abc" {
+		t.Fatalf("navigate not applied: %s", got)
+	}
+}
+
+func TestNewCDPBackendURL(t *testing.T) {
+	b, err := newCDPBackend("localhost:9222/")
+	if err != nil || b.baseURL != "http://localhost:9222" {
+		t.Fatalf("baseURL=%v err=%v", b, err)
+	}
+	if _, err := newCDPBackend("ws://localhost:9222/devtools/browser/x"); err == nil {
+		t.Fatal("expected error for WebSocket URL")
+	}
+}
+
+func TestCDPWatchReusesSessionAndWakesOnEvents(t *testing.T) {
+	f := newFakeCDP(t, "https://play.pocketcasts.com/podcasts")
+	polls := 0
+	f.evaluate = func(string) map[string]any {
+		polls++
+		state := "playing"
+		if polls%2 == 0 {
+			state = "paused"
+		}
+		return map[string]any{"result": map[string]any{"type": "string", "value": `{"state":"` + state + `","title":"Ep"}`}}
+	}
+	c := newCDPController(t, f)
+
+	var got []WatchEventType
+	errStop := fmt.Errorf("stop")
+	// Polling intervals are far longer than the test timeout, so every poll after the
+	// first must have been triggered by a Runtime.bindingCalled event.
+	err := c.Watch(testContext(t), WatchOptions{MinInterval: time.Hour, MaxInterval: time.Hour}, func(e WatchEvent) error {
+		got = append(got, e.Type)
+		if len(got) == 3 {
+			return errStop
+		}
+		return nil
+	})
+	if err != errStop {
+		t.Fatalf("Watch: %v", err)
+	}
+	want := []WatchEventType{WatchOpened, WatchPause, WatchPlay}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("events=%v want %v", got, want)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.conns != 1 {
+		t.Fatalf("expected one DevTools connection, got %d", f.conns)
+	}
+	if !strings.Contains(strings.Join(f.calls, ","), "Runtime.addBinding") {
+		t.Fatalf("binding not added: %v", f.calls)
+	}
+	if strings.Contains(strings.Join(f.calls, ","), "Page.bringToFront") {
+		t.Fatalf("watch focused the tab: %v", f.calls)
+	}
+}
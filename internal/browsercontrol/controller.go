@@ -6,7 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"os/exec"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 type Action string
@@ -17,46 +20,194 @@ const (
 	ActionToggle Action = "toggle"
 	ActionNext   Action = "next"
 	ActionPrev   Action = "prev"
+
+	// Media actions change the page's media element directly and fall back to
+	// clicking player buttons when it is not reachable.
+	ActionSeek   Action = "seek"
+	ActionSpeed  Action = "speed"
+	ActionVolume Action = "volume"
+	ActionMute   Action = "mute"
 )
 
 type Options struct {
 	Browser     string
 	BrowserApp  string
 	URLContains string
+	// CDPURL is the Chrome DevTools endpoint (e.g. http://127.0.0.1:9222) of a browser
+	// started with --remote-debugging-port. Setting it (or Browser "cdp") selects the
+	// CDP backend, which works on any OS.
+	CDPURL string
+	// BiDiURL is the WebDriver BiDi endpoint (e.g. ws://127.0.0.1:9222/session) of
+	// Firefox started with --remote-debugging-port. Setting it (or Browser "firefox")
+	// selects the BiDi backend, which works on any OS.
+	BiDiURL string
+	// Runner executes AppleScript for the osascript backend. Nil means OSAScript.
+	Runner Runner
+	// Tab picks the n-th tab (1-based) whose URL contains URLContains, as numbered by
+	// TargetTabs. Zero prefers a tab that is playing, then the first match.
+	Tab int
+}
+
+// Runner executes an AppleScript with arguments and returns its trimmed output.
+// Scripts starting with a "#!/usr/bin/osascript -l JavaScript" line are JXA.
+type Runner interface {
+	Run(ctx context.Context, script string, args ...string) (string, error)
+}
+
+// OSAScript runs scripts with osascript(1).
+type OSAScript struct{}
+
+// DefaultCDPURL is used when Browser is "cdp" and no CDPURL is given.
+const DefaultCDPURL = "http://127.0.0.1:9222"
+
+// DefaultBiDiURL is used when Browser is "firefox" (or "bidi") and no BiDiURL is given.
+const DefaultBiDiURL = "ws://127.0.0.1:9222/session"
+
+// backend lists tabs, executes page JavaScript and navigates tabs in one kind of browser.
+type backend interface {
+	// describe completes "No tab found ..." (e.g. "in Safari").
+	describe() string
+	// tabs returns every tab in a stable order.
+	tabs(ctx context.Context) ([]Tab, error)
+	// runJS evaluates js in tab. focus brings the tab to the front where the browser
+	// needs that; probes pass false so they don't shuffle windows.
+	runJS(ctx context.Context, tab Tab, js string, focus bool) (string, error)
+	setTabURL(ctx context.Context, tab Tab, newURL string) error
+}
+
+// sessionBackend is a backend whose connection setup is costly enough to share
+// across the backend calls of one Controller method (BiDi's session handshake).
+type sessionBackend interface {
+	// hold keeps a session open for every call until release.
+	hold(ctx context.Context) (release func(), err error)
 }
 
 type Controller struct {
-	browser     browser
+	backend     backend
 	urlContains string
+	tabN        int
+
+	mu     sync.Mutex
+	target *Tab // resolved on first use so every call hits the same tab
 }
 
 func New(opts Options) (*Controller, error) {
+	urlContains := strings.TrimSpace(opts.URLContains)
+	if urlContains == "" {
+		return nil, errors.New("url-contains cannot be empty")
+	}
+	if opts.Tab < 0 {
+		return nil, fmt.Errorf("invalid tab number: %d", opts.Tab)
+	}
+
+	bidiURL := strings.TrimSpace(opts.BiDiURL)
+	switch normalize(opts.Browser) {
+	case "firefox", "zen", "bidi":
+		if bidiURL == "" && strings.TrimSpace(opts.CDPURL) == "" {
+			bidiURL = DefaultBiDiURL
+		}
+	}
+	if bidiURL != "" {
+		be, err := newBiDiBackend(bidiURL)
+		if err != nil {
+			return nil, err
+		}
+		return &Controller{backend: be, urlContains: urlContains, tabN: opts.Tab}, nil
+	}
+
+	cdpURL := strings.TrimSpace(opts.CDPURL)
+	if cdpURL == "" && normalize(opts.Browser) == "cdp" {
+		cdpURL = DefaultCDPURL
+	}
+	if cdpURL != "" {
+		be, err := newCDPBackend(cdpURL)
+		if err != nil {
+			return nil, err
+		}
+		return &Controller{backend: be, urlContains: urlContains, tabN: opts.Tab}, nil
+	}
+
 	b, err := parseBrowser(opts.Browser, opts.BrowserApp)
 	if err != nil {
 		return nil, err
 	}
-	urlContains := strings.TrimSpace(opts.URLContains)
-	if urlContains == "" {
-		return nil, errors.New("url-contains cannot be empty")
+	runner := opts.Runner
+	if runner == nil {
+		runner = OSAScript{}
 	}
-	return &Controller{browser: b, urlContains: urlContains}, nil
+	return &Controller{backend: osascriptBackend{browser: b, runner: runner}, urlContains: urlContains, tabN: opts.Tab}, nil
 }
 
 type ActionResult struct {
 	Clicked      bool   `json:"clicked"`
 	ClickedLabel string `json:"clickedLabel"`
+	// Applied is set when a media action changed the media element directly;
+	// Media then describes the element afterwards (including the new position).
+	Applied bool        `json:"applied,omitempty"`
+	Media   *MediaState `json:"media,omitempty"`
 }
 
+// StatusResult describes what the Web Player is doing. Everything except State is
+// best-effort: fields are zero when the page has no media element or metadata.
 type StatusResult struct {
-	State string `json:"state"` // playing|paused|unknown
+	State        string  `json:"state"` // playing|paused|unknown
+	Title        string  `json:"title"`
+	Podcast      string  `json:"podcast"`
+	Artwork      string  `json:"artwork,omitempty"`
+	CurrentTime  float64 `json:"currentTime"`
+	Duration     float64 `json:"duration"`
+	PlaybackRate float64 `json:"playbackRate"`
+	Volume       float64 `json:"volume"`
+	Muted        bool    `json:"muted"`
+	EpisodeUUID  string  `json:"episodeUuid,omitempty"`
+	PodcastUUID  string  `json:"podcastUuid,omitempty"`
+	URL          string  `json:"url,omitempty"`
+}
+
+// MediaState describes the Web Player's media element. Times are in seconds.
+type MediaState struct {
+	Found       bool    `json:"found"`
+	Ready       bool    `json:"ready"`
+	Paused      bool    `json:"paused"`
+	CurrentTime float64 `json:"currentTime"`
+	Duration    float64 `json:"duration"`
+	Src         string  `json:"src"`
+	Title       string  `json:"title"`
+	Podcast     string  `json:"podcast"`
+	Artwork     string  `json:"artwork"`
+	// PlaybackRate is 1 at normal speed; Volume is 0..1.
+	PlaybackRate float64 `json:"playbackRate"`
+	Volume       float64 `json:"volume"`
+	Muted        bool    `json:"muted"`
+	EpisodeUUID  string  `json:"episodeUuid"`
+	PodcastUUID  string  `json:"podcastUuid"`
+	URL          string  `json:"url"`
 }
 
 type QueueItem struct {
-	Title string `json:"title"`
-	Href  string `json:"href"`
+	Title       string `json:"title"`
+	Href        string `json:"href"`
+	EpisodeUUID string `json:"episodeUuid,omitempty"`
+	PodcastUUID string `json:"podcastUuid,omitempty"`
+	Podcast     string `json:"podcast,omitempty"`
+	// Duration is the length (or time left) as displayed, e.g. "45m" or "1h 2m left".
+	Duration   string `json:"duration,omitempty"`
+	NowPlaying bool   `json:"nowPlaying,omitempty"`
+}
+
+// hold shares one backend session across the calls of a Controller method. If it
+// can't be opened, each call opens its own and reports the error.
+func (c *Controller) hold(ctx context.Context) (release func()) {
+	if sb, ok := c.backend.(sessionBackend); ok {
+		if release, err := sb.hold(ctx); err == nil {
+			return release
+		}
+	}
+	return func() {}
 }
 
 func (c *Controller) Do(ctx context.Context, action Action) (ActionResult, error) {
+	defer c.hold(ctx)()
 	js := jsForAction(action)
 	out, err := c.runJS(ctx, js)
 	if err != nil {
@@ -73,11 +224,63 @@ func (c *Controller) Do(ctx context.Context, action Action) (ActionResult, error
 	return res, nil
 }
 
+// Seek moves playback to an absolute position in seconds, or by a delta when relative
+// is true. A relative seek falls back to the player's skip buttons, whose step is set
+// in Pocket Casts and may differ from the requested delta.
+func (c *Controller) Seek(ctx context.Context, seconds float64, relative bool) (ActionResult, error) {
+	if relative {
+		return c.mediaAction(ctx, ActionSeek, jsMediaAction("seekBy", seconds))
+	}
+	return c.mediaAction(ctx, ActionSeek, jsMediaAction("seekTo", seconds))
+}
+
+// SetSpeed sets the playback rate (1 is normal speed).
+func (c *Controller) SetSpeed(ctx context.Context, rate float64) (ActionResult, error) {
+	if rate <= 0 || rate > 16 {
+		return ActionResult{}, fmt.Errorf("speed out of range: %g", rate)
+	}
+	return c.mediaAction(ctx, ActionSpeed, jsMediaAction("speed", rate))
+}
+
+// SetVolume sets the volume in percent (0-100) and unmutes when it is above zero.
+func (c *Controller) SetVolume(ctx context.Context, percent int) (ActionResult, error) {
+	if percent < 0 || percent > 100 {
+		return ActionResult{}, fmt.Errorf("volume out of range: %d", percent)
+	}
+	return c.mediaAction(ctx, ActionVolume, jsMediaAction("volume", float64(percent)/100))
+}
+
+// ToggleMute mutes or unmutes the player.
+func (c *Controller) ToggleMute(ctx context.Context) (ActionResult, error) {
+	return c.mediaAction(ctx, ActionMute, jsMediaAction("mute", 0))
+}
+
+func (c *Controller) mediaAction(ctx context.Context, action Action, js string) (ActionResult, error) {
+	defer c.hold(ctx)()
+	out, err := c.runJS(ctx, js)
+	if err != nil {
+		return ActionResult{}, err
+	}
+	var res ActionResult
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		return ActionResult{}, fmt.Errorf("unexpected JS result: %q", out)
+	}
+	if !res.Applied && !res.Clicked {
+		return res, fmt.Errorf("no audio element or matching control found in page (action=%s)", action)
+	}
+	return res, nil
+}
+
 func (c *Controller) Status(ctx context.Context) (StatusResult, error) {
+	defer c.hold(ctx)()
 	out, err := c.runJS(ctx, jsStatus())
 	if err != nil {
 		return StatusResult{}, err
 	}
+	return decodeStatus(out)
+}
+
+func decodeStatus(out string) (StatusResult, error) {
 	var st StatusResult
 	if err := json.Unmarshal([]byte(out), &st); err != nil {
 		return StatusResult{}, fmt.Errorf("unexpected JS result: %q", out)
@@ -88,30 +291,91 @@ func (c *Controller) Status(ctx context.Context) (StatusResult, error) {
 	return st, nil
 }
 
-func (c *Controller) QueueList(ctx context.Context) ([]QueueItem, error) {
-	out, err := c.runJS(ctx, jsQueueList())
+func (c *Controller) Media(ctx context.Context) (MediaState, error) {
+	return c.media(ctx, jsMediaState())
+}
+
+// SeekTo sets the media element's position. The element must have loaded metadata
+// (Ready); callers that just navigated should retry until it has.
+func (c *Controller) SeekTo(ctx context.Context, seconds float64) (MediaState, error) {
+	return c.media(ctx, jsSeekTo(seconds))
+}
+
+func (c *Controller) media(ctx context.Context, js string) (MediaState, error) {
+	defer c.hold(ctx)()
+	out, err := c.runJS(ctx, js)
 	if err != nil {
-		return nil, err
+		return MediaState{}, err
+	}
+	var m MediaState
+	if err := json.Unmarshal([]byte(out), &m); err != nil {
+		return MediaState{}, fmt.Errorf("unexpected JS result: %q", out)
 	}
-	var items []QueueItem
-	if err := json.Unmarshal([]byte(out), &items); err != nil {
-		return nil, fmt.Errorf("unexpected JS result: %q", out)
+	if !m.Found {
+		return m, errors.New("no audio element found in page")
+	}
+	return m, nil
+}
+
+// queueOpenAttempts and queueOpenDelay bound how long QueueList waits for the Up Next
+// panel to render after opening it.
+const (
+	queueOpenAttempts = 8
+	queueOpenDelay    = 250 * time.Millisecond
+)
+
+// QueueList returns the Up Next panel in order, opening (and closing again) the panel
+// if needed. Pages without the panel fall back to every episode link on the page.
+func (c *Controller) QueueList(ctx context.Context) ([]QueueItem, error) {
+	defer c.hold(ctx)()
+	for attempt := 0; ; attempt++ {
+		out, err := c.runJS(ctx, jsQueueList())
+		if err != nil {
+			return nil, err
+		}
+		var res struct {
+			Found bool        `json:"found"`
+			Items []QueueItem `json:"items"`
+		}
+		if err := json.Unmarshal([]byte(out), &res); err != nil {
+			return nil, fmt.Errorf("unexpected JS result: %q", out)
+		}
+		if res.Found {
+			if res.Items == nil {
+				res.Items = []QueueItem{}
+			}
+			return res.Items, nil
+		}
+		if attempt+1 >= queueOpenAttempts {
+			// Don't leave behind a drawer we opened.
+			_, _ = c.runJS(ctx, jsQueueClose())
+			return nil, errors.New("Up Next panel did not open in page")
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(queueOpenDelay):
+		}
 	}
-	return items, nil
 }
 
 func (c *Controller) runJS(ctx context.Context, js string) (string, error) {
-	script := c.browser.appleScript()
-	cmd := exec.CommandContext(ctx, "osascript", "-e", script, c.browser.appName, c.urlContains, js)
-	b, err := cmd.CombinedOutput()
+	return c.runJSFocus(ctx, js, true)
+}
+
+// runJSFocus runs js in the target tab, bringing it to the front only if focus is
+// set. Commands focus the tab; background polling must not.
+func (c *Controller) runJSFocus(ctx context.Context, js string, focus bool) (string, error) {
+	t, err := c.resolveTarget(ctx)
 	if err != nil {
-		msg := strings.TrimSpace(string(b))
-		if msg == "" {
-			msg = err.Error()
-		}
-		return "", errors.New(msg)
+		return "", err
 	}
-	return strings.TrimSpace(string(b)), nil
+	out, err := c.backend.runJS(ctx, t, js, focus)
+	if err != nil {
+		// The tab may have been closed or moved; pick again next time.
+		c.forgetTarget()
+	}
+	return out, err
 }
 
 func (c *Controller) SetTabURL(ctx context.Context, newURL string) error {
@@ -119,34 +383,90 @@ func (c *Controller) SetTabURL(ctx context.Context, newURL string) error {
 	if newURL == "" {
 		return errors.New("new URL cannot be empty")
 	}
-
-	script := c.browser.appleScriptSetURL()
-	cmd := exec.CommandContext(ctx, "osascript", "-e", script, c.browser.appName, c.urlContains, newURL)
-	b, err := cmd.CombinedOutput()
+	defer c.hold(ctx)()
+	t, err := c.resolveTarget(ctx)
 	if err != nil {
-		msg := strings.TrimSpace(string(b))
-		if msg == "" {
-			msg = err.Error()
-		}
-		return errors.New(msg)
+		return err
+	}
+	if err := c.backend.setTabURL(ctx, t, newURL); err != nil {
+		c.forgetTarget()
+		return err
 	}
 	return nil
 }
 
+// TabURLs returns the URL of every open tab.
 func (c *Controller) TabURLs(ctx context.Context) ([]string, error) {
-	script := c.browser.appleScriptListURLs()
-	cmd := exec.CommandContext(ctx, "osascript", "-e", script, c.browser.appName)
+	defer c.hold(ctx)()
+	tabs, err := c.backend.tabs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	urls := make([]string, 0, len(tabs))
+	for _, t := range tabs {
+		urls = append(urls, t.URL)
+	}
+	return urls, nil
+}
+
+// osascriptBackend drives macOS browsers through AppleScript. Tabs are addressed by
+// window id (stable while the window is open) and tab index.
+type osascriptBackend struct {
+	browser browser
+	runner  Runner
+}
+
+func (o osascriptBackend) describe() string {
+	return "in " + o.browser.appName
+}
+
+func (o osascriptBackend) tabs(ctx context.Context) ([]Tab, error) {
+	out, err := o.run(ctx, opListTabs)
+	if err != nil {
+		return nil, err
+	}
+	return parseOSATabs(out)
+}
+
+func (o osascriptBackend) runJS(ctx context.Context, tab Tab, js string, focus bool) (string, error) {
+	focusArg := "0"
+	if focus {
+		focusArg = "1"
+	}
+	return o.run(ctx, opRunJS, tab.ref, strconv.Itoa(tab.Index), focusArg, js)
+}
+
+func (o osascriptBackend) setTabURL(ctx context.Context, tab Tab, newURL string) error {
+	_, err := o.run(ctx, opSetURL, tab.ref, strconv.Itoa(tab.Index), newURL)
+	return err
+}
+
+// run executes the browser's script for op with the app name as the first argument.
+func (o osascriptBackend) run(ctx context.Context, op scriptOp, args ...string) (string, error) {
+	script, err := o.browser.script(op)
+	if err != nil {
+		return "", err
+	}
+	out, err := o.runner.Run(ctx, script, append([]string{o.browser.appName}, args...)...)
+	if err != nil {
+		return "", unsupportedFromOutput(o.browser.appName, err)
+	}
+	return out, nil
+}
+
+func (OSAScript) Run(ctx context.Context, script string, args ...string) (string, error) {
+	osaArgs := []string{"-e", script}
+	if body, ok := strings.CutPrefix(script, jxaShebang); ok {
+		osaArgs = []string{"-l", "JavaScript", "-e", body}
+	}
+	cmd := exec.CommandContext(ctx, "osascript", append(osaArgs, args...)...)
 	b, err := cmd.CombinedOutput()
 	if err != nil {
 		msg := strings.TrimSpace(string(b))
 		if msg == "" {
 			msg = err.Error()
 		}
-		return nil, errors.New(msg)
-	}
-	var urls []string
-	if err := json.Unmarshal([]byte(strings.TrimSpace(string(b))), &urls); err != nil {
-		return nil, fmt.Errorf("unexpected JS result: %q", strings.TrimSpace(string(b)))
+		return "", errors.New(msg)
 	}
-	return urls, nil
+	return strings.TrimSpace(string(b)), nil
 }
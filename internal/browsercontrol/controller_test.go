@@ -0,0 +1,274 @@
+package browsercontrol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+type runCall struct {
+	script string
+	args   []string
+}
+
+// fakeRunner records scripts and returns canned output, one entry per call.
+// The last entry is repeated once the list is exhausted. Tab listing and playback
+// probes are answered from tabList and playing and are not recorded in calls.
+type fakeRunner struct {
+	calls   []runCall
+	outputs []string
+	err     error
+
+	tabList string
+	lists   int
+	playing map[string]bool // "windowID/tabIndex"
+	probed  []string
+}
+
+const defaultTabList = "7\t1\thttps://example.com/\tExample\n7\t2\thttps://play.pocketcasts.com/podcasts\tPocket Casts\n"
+
+func (f *fakeRunner) Run(ctx context.Context, script string, args ...string) (string, error) {
+	if script == appleScriptChromiumListTabs || script == appleScriptSafariListTabs {
+		f.lists++
+		if f.err != nil {
+			return "", f.err
+		}
+		return f.tabList, nil
+	}
+	if len(args) == 5 && args[4] == jsIsPlaying() {
+		key := args[1] + "/" + args[2]
+		f.probed = append(f.probed, key)
+		return fmt.Sprintf(`{"playing":%t}`, f.playing[key]), nil
+	}
+	f.calls = append(f.calls, runCall{script: script, args: args})
+	if f.err != nil {
+		return "", f.err
+	}
+	if len(f.outputs) == 0 {
+		return "", nil
+	}
+	out := f.outputs[0]
+	if len(f.outputs) > 1 {
+		f.outputs = f.outputs[1:]
+	}
+	return out, nil
+}
+
+func newFakeController(t *testing.T, browser string, outputs ...string) (*Controller, *fakeRunner) {
+	t.Helper()
+	r := &fakeRunner{outputs: outputs, tabList: defaultTabList}
+	c, err := New(Options{Browser: browser, URLContains: "pocketcasts.com", Runner: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, r
+}
+
+func TestDoPassesScriptArgs(t *testing.T) {
+	c, r := newFakeController(t, "brave", `{"clicked":true,"clickedLabel":"Play"}`)
+	res, err := c.Do(context.Background(), ActionPlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ClickedLabel != "Play" {
+		t.Fatalf("res=%+v", res)
+	}
+	if len(r.calls) != 1 {
+		t.Fatalf("calls=%d", len(r.calls))
+	}
+	call := r.calls[0]
+	if call.script != appleScriptChromium {
+		t.Fatal("expected the Chromium script")
+	}
+	if strings.Join(call.args[:4], "|") != "Brave Browser|7|2|1" || call.args[4] != jsForAction(ActionPlay) {
+		t.Fatalf("args=%q", call.args)
+	}
+}
+
+func TestDoNoMatchingControl(t *testing.T) {
+	c, _ := newFakeController(t, "chrome", `{"clicked":false}`)
+	_, err := c.Do(context.Background(), ActionNext)
+	if err == nil || !strings.Contains(err.Error(), "no matching control found in page (action=next)") {
+		t.Fatalf("err=%v", err)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newFakeController(t, "safari", "missing value")
+	calls := map[string]func() error{
+		"Do":              func() error { _, err := c.Do(ctx, ActionPause); return err },
+		"Status":          func() error { _, err := c.Status(ctx); return err },
+		"Media":           func() error { _, err := c.Media(ctx); return err },
+		"QueueList":       func() error { _, err := c.QueueList(ctx); return err },
+		"TokenCandidates": func() error { _, err := c.TokenCandidates(ctx); return err },
+	}
+	for name, call := range calls {
+		err := call()
+		if err == nil || !strings.Contains(err.Error(), `unexpected JS result: "missing value"`) {
+			t.Errorf("%s: err=%v", name, err)
+		}
+	}
+}
+
+func TestRunnerErrorIsReturned(t *testing.T) {
+	r := &fakeRunner{err: errors.New("Not authorized to send Apple events to Safari. (-1743)")}
+	c, err := New(Options{Browser: "safari", URLContains: "pocketcasts.com", Runner: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Status(context.Background()); err != r.err {
+		t.Fatalf("err=%v", err)
+	}
+}
+
+func TestStatusDefaultsToUnknown(t *testing.T) {
+	c, _ := newFakeController(t, "chrome", `{}`)
+	st, err := c.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.State != "unknown" {
+		t.Fatalf("state=%q", st.State)
+	}
+}
+
+func TestQueueListAndTokens(t *testing.T) {
+	c, _ := newFakeController(t, "chrome",
+		`{"found":true,"items":[{"title":"Ep 1","href":"/episode/1"}]}`,
+		`{"candidates":[{"sourceKey":"localStorage:token","token":"a.b.c"}]}`,
+	)
+	items, err := c.QueueList(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Href != "/episode/1" {
+		t.Fatalf("items=%+v", items)
+	}
+	cands, err := c.TokenCandidates(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cands) != 1 || cands[0].Token != "a.b.c" {
+		t.Fatalf("cands=%+v", cands)
+	}
+}
+
+func TestSetTabURLAndTabURLs(t *testing.T) {
+	c, r := newFakeController(t, "safari", "ok")
+	if err := c.SetTabURL(context.Background(), "  https://pocketcasts.com/episode/1 "); err != nil {
+		t.Fatal(err)
+	}
+	urls, err := c.TabURLs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(urls) != 2 || urls[0] != "https://example.com/" {
+		t.Fatalf("urls=%q", urls)
+	}
+	if len(r.calls) != 1 {
+		t.Fatalf("calls=%d", len(r.calls))
+	}
+	if r.calls[0].script != appleScriptSafariSetURL || strings.Join(r.calls[0].args, "|") != "Safari|7|2|https://pocketcasts.com/episode/1" {
+		t.Fatalf("set URL call=%+v", r.calls[0])
+	}
+
+	if err := c.SetTabURL(context.Background(), " "); err == nil {
+		t.Fatal("expected error for empty URL")
+	}
+	if len(r.calls) != 1 {
+		t.Fatal("empty URL should not run a script")
+	}
+}
+
+func TestStatusDecodesMediaDetails(t *testing.T) {
+	c, _ := newFakeController(t, "chrome", `{"state":"playing","title":"Ep","podcast":"Show","currentTime":12.5,"duration":600,"playbackRate":1.25,"volume":0.8,"muted":false,"episodeUuid":"e1","found":true}`)
+	st, err := c.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := StatusResult{State: "playing", Title: "Ep", Podcast: "Show", CurrentTime: 12.5, Duration: 600, PlaybackRate: 1.25, Volume: 0.8, EpisodeUUID: "e1"}
+	if st != want {
+		t.Fatalf("st=%+v", st)
+	}
+}
+
+func TestMediaActions(t *testing.T) {
+	c, r := newFakeController(t, "chrome", `{"applied":true,"media":{"found":true,"currentTime":130,"duration":600}}`)
+	res, err := c.Seek(context.Background(), 30, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Applied || res.Media == nil || res.Media.CurrentTime != 130 {
+		t.Fatalf("res=%+v", res)
+	}
+	if js := r.calls[0].args[4]; !strings.Contains(js, `const op = "seekBy", v = 30.000000;`) || !strings.Contains(js, `"Skip forward"`) {
+		t.Fatalf("unexpected JS:\n%s", js)
+	}
+
+	if _, err := c.SetSpeed(context.Background(), 0); err == nil {
+		t.Fatal("expected error for speed 0")
+	}
+	if _, err := c.SetVolume(context.Background(), 101); err == nil {
+		t.Fatal("expected error for volume 101")
+	}
+	if len(r.calls) != 1 {
+		t.Fatal("invalid values should not run a script")
+	}
+}
+
+func TestMediaActionFallbackAndNotFound(t *testing.T) {
+	c, _ := newFakeController(t, "chrome", `{"clicked":true,"clickedLabel":"Skip back"}`, `{"clicked":false,"clickedLabel":""}`)
+	res, err := c.Seek(context.Background(), -10, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ClickedLabel != "Skip back" || res.Media != nil {
+		t.Fatalf("res=%+v", res)
+	}
+	_, err = c.SetVolume(context.Background(), 50)
+	if err == nil || !strings.Contains(err.Error(), "no audio element or matching control found in page (action=volume)") {
+		t.Fatalf("err=%v", err)
+	}
+}
+
+func TestQueueListOpensUpNextPanel(t *testing.T) {
+	c, r := newFakeController(t, "chrome",
+		`{"found":false,"opened":true,"items":[]}`,
+		`{"found":true,"scoped":true,"items":[{"title":"Next","episodeUuid":"e2","podcast":"Show","duration":"45m"},{"title":"Now","episodeUuid":"e1","nowPlaying":true}]}`,
+	)
+	items, err := c.QueueList(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.calls) != 2 {
+		t.Fatalf("expected a retry after opening the panel, calls=%d", len(r.calls))
+	}
+	if len(items) != 2 || items[0].Podcast != "Show" || items[0].Duration != "45m" || !items[1].NowPlaying {
+		t.Fatalf("items=%+v", items)
+	}
+
+	c, _ = newFakeController(t, "chrome", `{"found":true,"items":null}`)
+	items, err = c.QueueList(context.Background())
+	if err != nil || items == nil || len(items) != 0 {
+		t.Fatalf("items=%v err=%v", items, err)
+	}
+}
+
+func TestQueueListClosesPanelWhenItNeverRenders(t *testing.T) {
+	c, r := newFakeController(t, "chrome", `{"found":false,"opened":true,"items":[]}`)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := c.QueueList(ctx); err == nil || !strings.Contains(err.Error(), "did not open") {
+		t.Fatalf("err=%v", err)
+	}
+	if len(r.calls) != queueOpenAttempts+1 {
+		t.Fatalf("calls=%d", len(r.calls))
+	}
+	if last := r.calls[len(r.calls)-1]; last.args[4] != jsQueueClose() {
+		t.Fatalf("last script was not the close script: %q", last.args[4])
+	}
+}
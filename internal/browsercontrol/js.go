@@ -54,35 +54,305 @@ func jsToggle() string {
 }
 
 func jsStatus() string {
-	return `(function(){
+	// The player buttons decide the state; the media element is the fallback for
+	// builds whose aria-labels we don't know.
+	return `(function(){` + jsMediaFns + `
   const hasPause = !!document.querySelector('button[aria-label="Pause"], button[aria-label="Pause episode"]');
   const hasPlay = !!document.querySelector('button[aria-label="Play"], button[aria-label="Resume"], button[aria-label="Play episode"]');
-  if (hasPause) return JSON.stringify({state:"playing"});
-  if (hasPlay) return JSON.stringify({state:"paused"});
-  return JSON.stringify({state:"unknown"});
+  const m = findMedia();
+  const st = mediaState(m);
+  if (hasPause) st.state = "playing";
+  else if (hasPlay) st.state = "paused";
+  else if (m && (m.currentSrc || m.src)) st.state = m.paused ? "paused" : "playing";
+  else st.state = "unknown";
+  return JSON.stringify(st);
+})()`
+}
+
+func jsIsPlaying() string {
+	return `(function(){
+  const els = Array.from(document.querySelectorAll('audio, video'));
+  return JSON.stringify({playing: els.some(function(e){ return !e.paused && !e.ended; })});
 })()`
 }
 
 func jsQueueList() string {
-	// Best-effort: collect episode links currently visible in the page.
-	// This works when "Up Next" is visible, but may include other episode links too.
+	// Reads the Up Next panel in order. When the panel is closed, the first call clicks
+	// its toggle and returns {found:false, opened:true}; the caller retries until it
+	// has rendered, and the panel is closed again once read.
+	// An open panel with no episodes is found with no items.
+	// Without a panel or toggle (older builds), it falls back to every episode link on
+	// the page, as before, with scoped:false.
+	return `(function(){` + jsMediaFns + `
+  const openedKey = '` + jsUpNextOpenedKey + `';
+  // Episode links are /episode/<uuid> or /podcasts/<podcast>/<episode>.
+  function episodeLinks(root){
+    return Array.from(root.querySelectorAll('a[href]')).filter(function(a){
+      const href = a.getAttribute('href') || '';
+      return href.indexOf('/episode/') >= 0 || (href.indexOf('/podcasts/') >= 0 && uuidsOf(href).length > 1);
+    });
+  }
+  const panelSels = ['[data-testid*="up-next" i]', '[class*="UpNext"]', '[class*="up-next" i]', '[aria-label*="Up Next" i]:not(button)'];
+  function upNextHeadings(){
+    return Array.from(document.querySelectorAll('h1, h2, h3, h4, [role="heading"]')).filter(function(h){
+      return /^\s*up next\s*$/i.test(h.textContent || '');
+    });
+  }
+  function visible(el){
+    return !!(el.offsetWidth || el.offsetHeight || el.getClientRects().length);
+  }
+  function findPanel(){
+    for (const sel of panelSels){
+      for (const el of document.querySelectorAll(sel)){
+        if (episodeLinks(el).length) return el;
+      }
+    }
+    for (const h of upNextHeadings()){
+      for (let el = h.parentElement; el && el !== document.body; el = el.parentElement){
+        if (episodeLinks(el).length) return el;
+      }
+    }
+    return null;
+  }
+  // findEmptyPanel finds an open panel with nothing in it: a visible panel
+  // container or "Up Next" heading that isn't the toggle itself.
+  function findEmptyPanel(){
+    for (const sel of panelSels){
+      for (const el of document.querySelectorAll(sel)){
+        if (!el.closest('button, [role="button"]') && visible(el)) return el;
+      }
+    }
+    for (const h of upNextHeadings()){
+      if (!h.closest('button, [role="button"]') && visible(h)) return h.parentElement || h;
+    }
+    return null;
+  }
+  function findToggle(){
+    return document.querySelector('` + jsUpNextToggle + `');
+  }
+  function uuidsOf(href){
+    return (href || '').match(/[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}/gi) || [];
+  }
+  function text(el){
+    return el ? (el.textContent || '').replace(/\s+/g,' ').trim() : '';
+  }
+  function collect(root){
+    const meta = (navigator.mediaSession && navigator.mediaSession.metadata) || null;
+    const playingTitle = (meta && meta.title) ? meta.title.trim() : '';
+    const seen = new Set();
+    const items = [];
+    for (const a of episodeLinks(root)){
+      const href = (a.href || a.getAttribute('href') || '').trim();
+      if (!href || seen.has(href)) continue;
+      seen.add(href);
+      let row = a.closest('li, [role="listitem"], [draggable="true"]');
+      if (!row){
+        row = a.parentElement || a;
+        if (episodeLinks(row).length > 1) row = a;
+      }
+      const uuids = uuidsOf(href);
+      const podcastLink = Array.from(row.querySelectorAll('a[href*="/podcasts/"]')).find(function(l){ return uuidsOf(l.getAttribute('href')).length === 1; });
+      const podcastEl = podcastLink || row.querySelector('[class*="podcast" i]:not(a)');
+      const rowText = text(row);
+      const dur = rowText.match(/\b\d+\s*h(?:\s*\d+\s*m(?:in)?)?\b(?:\s*left)?|\b\d+\s*m(?:in)?\b(?:\s*left)?|\b\d{1,2}:\d{2}(?::\d{2})?\b/i);
+      const title = text(a) || (a.getAttribute('aria-label') || '').trim();
+      items.push({
+        title: title,
+        href: href,
+        episodeUuid: uuids.length ? uuids[uuids.length-1] : '',
+        podcastUuid: uuids.length > 1 ? uuids[0] : uuidsOf(podcastLink ? podcastLink.getAttribute('href') : '')[0] || '',
+        podcast: podcastEl ? text(podcastEl) : '',
+        duration: dur ? dur[0].trim() : '',
+        nowPlaying: !!row.querySelector('[aria-current="true"], [class*="playing" i], [class*="NowPlaying"]') || (playingTitle !== '' && title === playingTitle)
+      });
+      if (items.length >= 500) break;
+    }
+    return items;
+  }
+
+  const panel = findPanel() || findEmptyPanel();
+  if (panel){
+    const items = collect(panel);
+    if (window[openedKey]){
+      delete window[openedKey];
+      const t = findToggle();
+      if (t) t.click();
+    }
+    return JSON.stringify({found:true, scoped:true, items: items});
+  }
+  const toggle = findToggle();
+  // A stale marker (the panel never rendered) must not block a new attempt.
+  if (toggle && !(window[openedKey] > Date.now() - 5000)){
+    window[openedKey] = Date.now();
+    toggle.click();
+    return JSON.stringify({found:false, opened:true, items: []});
+  }
+  if (toggle) return JSON.stringify({found:false, opened:true, items: []});
+  return JSON.stringify({found:true, scoped:false, items: collect(document).slice(0, 100)});
+})()`
+}
+
+const (
+	// jsUpNextOpenedKey marks, on window, a panel that jsQueueList opened itself.
+	jsUpNextOpenedKey = "__pocketcastsctlOpenedUpNext"
+	jsUpNextToggle    = `button[aria-label*="Up Next" i], [role="button"][aria-label*="Up Next" i], button[title*="Up Next" i]`
+)
+
+// jsQueueClose closes the Up Next panel again if jsQueueList opened it.
+func jsQueueClose() string {
 	return `(function(){
-  const anchors = Array.from(document.querySelectorAll('a[href*="/episode/"]'));
-  const seen = new Set();
-  const items = [];
-  for (const a of anchors){
-    const href = (a.href || a.getAttribute('href') || '').trim();
-    const title = (a.textContent || '').replace(/\s+/g,' ').trim();
-    const key = href + '|' + title;
-    if (!href || seen.has(key)) continue;
-    seen.add(key);
-    items.push({title, href});
-    if (items.length >= 100) break;
-  }
-  return JSON.stringify(items);
+  const openedKey = '` + jsUpNextOpenedKey + `';
+  if (!window[openedKey]) return JSON.stringify({closed:false});
+  delete window[openedKey];
+  const t = document.querySelector('` + jsUpNextToggle + `');
+  if (t) t.click();
+  return JSON.stringify({closed:!!t});
 })()`
 }
 
+// jsMediaFns finds the page's media element and describes it. The element may be
+// detached from the DOM in some builds, in which case found is false.
+const jsMediaFns = `
+  function findMedia(){
+    const els = Array.from(document.querySelectorAll('audio, video'));
+    return els.find(function(e){ return e.currentSrc || e.src; }) || els[0] || null;
+  }
+  function artworkURL(meta){
+    const art = (meta && meta.artwork) ? Array.from(meta.artwork) : [];
+    let best = '', bestSize = -1;
+    for (const a of art){
+      const size = parseInt(String(a.sizes || '').split('x')[0], 10) || 0;
+      if (a.src && size >= bestSize){ best = a.src; bestSize = size; }
+    }
+    return best;
+  }
+  const uuidPattern = '[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}';
+  // routeUUIDs reads an /episode/<e> or /podcasts/<p>[/<e>] path. Other routes
+  // (and podcast pages, for the episode) yield empty strings.
+  function routeUUIDs(path){
+    let m = (path || '').match(new RegExp('/episode/(' + uuidPattern + ')', 'i'));
+    if (m) return {episode: m[1], podcast: ''};
+    m = (path || '').match(new RegExp('/podcasts/(' + uuidPattern + ')(?:/(' + uuidPattern + '))?', 'i'));
+    if (m) return {episode: m[2] || '', podcast: m[1]};
+    return {episode: '', podcast: ''};
+  }
+  function pathOf(href){
+    try { return new URL(href, 'https://play.pocketcasts.com').pathname; } catch (e) { return ''; }
+  }
+  // playingUUIDs identifies the playing episode from the now-playing row's link,
+  // then a UUID in the audio URL, then the route if it is that episode's page
+  // (the page may show another episode, so this is the last resort). Unknown
+  // stays empty rather than guessing from whatever page is open.
+  function playingUUIDs(path, src, nowPlayingHref){
+    const row = routeUUIDs(pathOf(nowPlayingHref));
+    if (row.episode) return row;
+    const inSrc = (src || '').match(new RegExp(uuidPattern, 'gi')) || [];
+    if (inSrc.length) return {episode: inSrc[inSrc.length-1], podcast: inSrc.length > 1 ? inSrc[0] : ''};
+    const route = routeUUIDs(path);
+    return route.episode ? route : {episode: '', podcast: ''};
+  }
+  function nowPlayingHref(){
+    const links = document.querySelectorAll('[aria-current="true"] a[href], [class*="NowPlaying"] a[href], [class*="now-playing" i] a[href]');
+    for (const a of links){
+      const href = a.getAttribute('href') || '';
+      if (routeUUIDs(pathOf(href)).episode) return href;
+    }
+    return '';
+  }
+  function mediaState(m){
+    const meta = (navigator.mediaSession && navigator.mediaSession.metadata) || null;
+    const src = m ? (m.currentSrc || m.src || '') : '';
+    const playing = playingUUIDs(location.pathname || '', src, nowPlayingHref());
+    return {
+      found: !!m,
+      ready: !!m && m.readyState >= 1,
+      paused: m ? m.paused : true,
+      currentTime: m ? (m.currentTime || 0) : 0,
+      duration: (m && isFinite(m.duration)) ? m.duration : 0,
+      src: src,
+      title: (meta && meta.title) ? meta.title : (document.title || ''),
+      podcast: (meta && meta.artist) ? meta.artist : '',
+      artwork: artworkURL(meta),
+      playbackRate: m ? (m.playbackRate || 0) : 0,
+      volume: m ? m.volume : 0,
+      muted: m ? m.muted : false,
+      episodeUuid: playing.episode,
+      podcastUuid: playing.podcast,
+      url: location.href
+    };
+  }`
+
+func jsMediaState() string {
+	return `(function(){` + jsMediaFns + `
+  return JSON.stringify(mediaState(findMedia()));
+})()`
+}
+
+func jsSeekTo(seconds float64) string {
+	return fmt.Sprintf(`(function(){`+jsMediaFns+`
+  const m = findMedia();
+  if (m && m.readyState >= 1) m.currentTime = Math.max(0, %f);
+  return JSON.stringify(mediaState(m));
+})()`, seconds)
+}
+
+// jsMediaAction applies op to the media element and returns {applied, media}.
+// When the element is missing (or has no metadata yet, for seeks) it clicks the
+// matching player button instead and returns {clicked, clickedLabel}.
+//
+// op is one of seekTo/seekBy (seconds), speed (rate), volume (0..1) or mute (toggle).
+func jsMediaAction(op string, value float64) string {
+	var fallback []string
+	switch {
+	case op == "seekBy" && value >= 0:
+		fallback = []string{"Skip forward", "Jump forward"}
+	case op == "seekBy":
+		fallback = []string{"Skip back", "Jump back"}
+	case op == "mute":
+		fallback = []string{"Mute", "Unmute"}
+	}
+	return fmt.Sprintf(`(function(){`+jsMediaFns+`
+  const op = %q, v = %f;
+  const m = findMedia();
+  const seeking = op === "seekTo" || op === "seekBy";
+  if (m && (!seeking || m.readyState >= 1)){
+    const end = isFinite(m.duration) ? m.duration : Infinity;
+    switch (op){
+      case "seekTo": m.currentTime = Math.min(Math.max(0, v), end); break;
+      case "seekBy": m.currentTime = Math.min(Math.max(0, m.currentTime + v), end); break;
+      case "speed": m.playbackRate = v; break;
+      case "volume": m.volume = v; if (v > 0) m.muted = false; break;
+      case "mute": m.muted = !m.muted; break;
+    }
+    return JSON.stringify({clicked:false, clickedLabel:"", applied:true, media: mediaState(m)});
+  }
+  const labels = %s;
+  for (const label of labels){
+    const btn = document.querySelector('button[aria-label="'+label+'"]');
+    if (btn){
+      btn.click();
+      return JSON.stringify({clicked:true, clickedLabel: label});
+    }
+  }
+  return JSON.stringify({clicked:false, clickedLabel:""});
+})()`, op, value, toJSArray(fallback))
+}
+
+// jsWatchHook installs (once per document) capture listeners that report media
+// events through the named CDP binding. Media events don't bubble, but capturing
+// listeners on document still see them.
+func jsWatchHook(binding string) string {
+	return fmt.Sprintf(`(function(){
+  const b = %q;
+  if (window.__pocketcastsctlWatchHooked || typeof window[b] !== 'function') return;
+  window.__pocketcastsctlWatchHooked = true;
+  const notify = function(e){ try { window[b](e.type); } catch (err) {} };
+  ['play','pause','ended','loadedmetadata','emptied'].forEach(function(t){
+    document.addEventListener(t, notify, true);
+  });
+})()`, binding)
+}
+
 func toJSArray(ss []string) string {
 	// safe enough for our fixed label strings
 	out := "["
@@ -0,0 +1,57 @@
+package browsercontrol
+
+import (
+	"encoding/json"
+	"os/exec"
+	"testing"
+)
+
+// runJSFns evaluates expr after the page helpers in jsMediaFns with node, which
+// is enough for the helpers that don't touch the DOM.
+func runJSFns(t *testing.T, expr string) string {
+	t.Helper()
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node not installed")
+	}
+	out, err := exec.Command(node, "-e", jsMediaFns+"\nprocess.stdout.write(JSON.stringify("+expr+"));").CombinedOutput()
+	if err != nil {
+		t.Fatalf("node: %v\n%s", err, out)
+	}
+	return string(out)
+}
+
+func TestPlayingUUIDs(t *testing.T) {
+	const (
+		pod   = "11111111-1111-1111-1111-111111111111"
+		ep    = "22222222-2222-2222-2222-222222222222"
+		other = "33333333-3333-3333-3333-333333333333"
+	)
+	tests := []struct {
+		name, path, src, nowPlaying string
+		episode, podcast            string
+	}{
+		{name: "podcast page", path: "/podcasts/" + pod},
+		{name: "other route", path: "/discover/" + other},
+		{name: "episode page", path: "/episode/" + ep, episode: ep},
+		{name: "podcast episode page", path: "/podcasts/" + pod + "/" + ep, episode: ep, podcast: pod},
+		{name: "now playing row wins", path: "/episode/" + other, nowPlaying: "https://play.pocketcasts.com/podcasts/" + pod + "/" + ep, episode: ep, podcast: pod},
+		{name: "audio src", path: "/podcasts/" + other, src: "https://cache.example.com/" + ep + ".mp3", episode: ep},
+	}
+	var cases [][3]string
+	for _, tt := range tests {
+		cases = append(cases, [3]string{tt.path, tt.src, tt.nowPlaying})
+	}
+	in, _ := json.Marshal(cases)
+	out := runJSFns(t, string(in)+".map(function(c){ return playingUUIDs(c[0], c[1], c[2]); })")
+
+	var got []struct{ Episode, Podcast string }
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("decode %q: %v", out, err)
+	}
+	for i, tt := range tests {
+		if got[i].Episode != tt.episode || got[i].Podcast != tt.podcast {
+			t.Errorf("%s: got episode %q podcast %q, want %q %q", tt.name, got[i].Episode, got[i].Podcast, tt.episode, tt.podcast)
+		}
+	}
+}
@@ -13,10 +13,24 @@ type browser struct {
 type browserKind int
 
 const (
-	kindChromium browserKind = iota
-	kindSafari
+	kindChromium browserKind = iota // AppleScript, Chrome dictionary
+	kindSafari                      // AppleScript, Safari dictionary
+	kindJXAChromium
+	kindJXADetect    // custom apps: the dictionary is checked at run time
+	kindUnscriptable // no tab scripting at all (Firefox and its forks)
 )
 
+// unscriptableApps are apps known to have no tab scripting, keyed by normalized
+// name. Firefox and Zen themselves never get here: New sends them to WebDriver BiDi. Commands fail before running a script, rather than with whatever error
+// osascript reports.
+var unscriptableApps = map[string]bool{
+	"firefoxdeveloperedition": true,
+	"firefoxnightly":          true,
+	"librewolf":               true,
+	"floorp":                  true,
+	"waterfox":                true,
+}
+
 func parseBrowser(name string, appOverride string) (browser, error) {
 	nameNorm := normalize(name)
 	appOverride = normalizeAppName(appOverride)
@@ -28,7 +42,7 @@ func parseBrowser(name string, appOverride string) (browser, error) {
 		if appOverride == "" {
 			return browser{}, fmt.Errorf("browser=chromium requires --browser-app")
 		}
-		return browser{kind: kindChromium, appName: appOverride}, nil
+		return browser{kind: kindJXAChromium, appName: appOverride}, nil
 	case "brave", "bravebrowser":
 		return browser{kind: kindChromium, appName: chooseApp(appOverride, "Brave Browser")}, nil
 	case "edge", "microsoftedge":
@@ -37,50 +51,71 @@ func parseBrowser(name string, appOverride string) (browser, error) {
 		return browser{kind: kindChromium, appName: chooseApp(appOverride, "Arc")}, nil
 	case "dia":
 		return browser{kind: kindChromium, appName: chooseApp(appOverride, "Dia")}, nil
+	case "vivaldi":
+		return browser{kind: kindJXAChromium, appName: chooseApp(appOverride, "Vivaldi")}, nil
+	case "opera":
+		return browser{kind: kindJXADetect, appName: chooseApp(appOverride, "Opera")}, nil
+	case "orion":
+		return browser{kind: kindJXADetect, appName: chooseApp(appOverride, "Orion")}, nil
 	case "safari":
 		return browser{kind: kindSafari, appName: chooseApp(appOverride, "Safari")}, nil
+	case "safaritechnologypreview", "stp":
+		return browser{kind: kindSafari, appName: chooseApp(appOverride, "Safari Technology Preview")}, nil
 	default:
-		// Treat unknown as a custom app name for Chromium-style scripting.
-		if appOverride != "" {
-			return browser{kind: kindChromium, appName: appOverride}, nil
+		// Unknown names are custom app names; the JXA scripts find out at run time
+		// whether the app speaks the Chromium or the Safari dictionary.
+		app := appOverride
+		if app == "" {
+			app = strings.TrimSpace(name)
 		}
-		if name != "" {
-			return browser{kind: kindChromium, appName: name}, nil
+		if app == "" {
+			return browser{}, fmt.Errorf("unsupported browser: %q", name)
 		}
-		return browser{}, fmt.Errorf("unsupported browser: %q", name)
+		if unscriptableApps[normalize(app)] {
+			return browser{kind: kindUnscriptable, appName: app}, nil
+		}
+		return browser{kind: kindJXADetect, appName: app}, nil
 	}
 }
 
-func (b browser) appleScript() string {
-	switch b.kind {
-	case kindChromium:
-		return appleScriptChromium
-	case kindSafari:
-		return appleScriptSafari
-	default:
-		return appleScriptChromium
+// capabilities is all or nothing: an app is either known to be unscriptable or
+// assumed to support every op until a JXA script reports otherwise.
+func (b browser) capabilities() capabilities {
+	if b.kind == kindUnscriptable {
+		return capabilities{}
 	}
+	return allCapabilities
 }
 
-func (b browser) appleScriptSetURL() string {
-	switch b.kind {
-	case kindChromium:
-		return appleScriptChromiumSetURL
-	case kindSafari:
-		return appleScriptSafariSetURL
-	default:
-		return appleScriptChromiumSetURL
+// script returns the AppleScript or JXA script for op, or an UnsupportedError when
+// the browser is known to lack the capability.
+func (b browser) script(op scriptOp) (string, error) {
+	if !b.capabilities().has(op) {
+		return "", &UnsupportedError{App: b.appName, Capability: op.capability()}
 	}
-}
-
-func (b browser) appleScriptListURLs() string {
 	switch b.kind {
-	case kindChromium:
-		return appleScriptChromiumListURLs
+	case kindJXAChromium:
+		return jxaScript(jxaChromium, op), nil
+	case kindJXADetect:
+		return jxaScript(jxaDetect, op), nil
 	case kindSafari:
-		return appleScriptSafariListURLs
+		switch op {
+		case opListTabs:
+			return appleScriptSafariListTabs, nil
+		case opRunJS:
+			return appleScriptSafari, nil
+		default:
+			return appleScriptSafariSetURL, nil
+		}
 	default:
-		return appleScriptChromiumListURLs
+		switch op {
+		case opListTabs:
+			return appleScriptChromiumListTabs, nil
+		case opRunJS:
+			return appleScriptChromium, nil
+		default:
+			return appleScriptChromiumSetURL, nil
+		}
 	}
 }
 
@@ -109,46 +144,32 @@ func normalizeAppName(s string) string {
 	return strings.TrimSpace(s)
 }
 
+// The scripts below address one tab as (window id, tab index), as listed by the
+// ListTabs scripts; choosing among matching tabs happens in Go.
+//
+// argv: appName, windowID, tabIndex, focus ("1" to bring the tab forward), js.
 const appleScriptChromium = `
 using terms from application "Google Chrome"
 on run argv
   set appName to item 1 of argv
-  set urlNeedle to item 2 of argv
-  set js to item 3 of argv
-  set matched to 0
-  set lastErr to ""
-  set lastURL to ""
+  set wid to (item 2 of argv) as integer
+  set ti to (item 3 of argv) as integer
+  set focusTab to (item 4 of argv) is "1"
+  set js to item 5 of argv
 
   tell application appName
-    repeat with w in windows
-      repeat with t in tabs of w
-        try
-          set u to URL of t
-          if u contains urlNeedle then
-            set matched to matched + 1
-            set lastURL to u
-            try
-              try
-                set active tab index of w to (index of t)
-              end try
-              try
-                set index of w to 1
-              end try
-              return execute active tab of w javascript js
-            on error errMsg number errNum
-              set lastErr to errMsg & " (" & errNum & ")"
-            end try
-          end if
-        end try
-      end repeat
-    end repeat
+    set w to window id wid
+    if focusTab then
+      try
+        set active tab index of w to ti
+      end try
+      try
+        set index of w to 1
+      end try
+      return execute active tab of w javascript js
+    end if
+    return execute (tab ti of w) javascript js
   end tell
-
-  if matched > 0 then
-    error "Found " & matched & " matching tab(s) but JavaScript execution failed (lastURL=" & lastURL & "): " & lastErr
-  end if
-
-  error "No tab found in " & appName & " with URL containing: " & urlNeedle
 end run
 end using terms from
 `
@@ -156,51 +177,33 @@ end using terms from
 const appleScriptSafari = `
 on run argv
   set appName to item 1 of argv
-  set urlNeedle to item 2 of argv
-  set js to item 3 of argv
+  set wid to (item 2 of argv) as integer
+  set ti to (item 3 of argv) as integer
+  set js to item 5 of argv
 
   tell application appName
-    repeat with w in windows
-      repeat with t in tabs of w
-        try
-          set u to URL of t
-          if u contains urlNeedle then
-            return do JavaScript js in t
-          end if
-        end try
-      end repeat
-    end repeat
+    return do JavaScript js in tab ti of window id wid
   end tell
-
-  error "No tab found in " & appName & " with URL containing: " & urlNeedle
 end run
 `
 
+// argv: appName, windowID, tabIndex, newURL.
 const appleScriptChromiumSetURL = `
 using terms from application "Google Chrome"
 on run argv
   set appName to item 1 of argv
-  set urlNeedle to item 2 of argv
-  set newURL to item 3 of argv
+  set wid to (item 2 of argv) as integer
+  set ti to (item 3 of argv) as integer
+  set newURL to item 4 of argv
 
   tell application appName
-    repeat with w in windows
-      repeat with t in tabs of w
-        try
-          set u to URL of t
-          if u contains urlNeedle then
-            try
-              set active tab index of w to (index of t)
-            end try
-            set URL of t to newURL
-            return "ok"
-          end if
-        end try
-      end repeat
-    end repeat
+    set w to window id wid
+    try
+      set active tab index of w to ti
+    end try
+    set URL of tab ti of w to newURL
   end tell
-
-  error "No tab found in " & appName & " with URL containing: " & urlNeedle
+  return "ok"
 end run
 end using terms from
 `
@@ -208,83 +211,76 @@ end using terms from
 const appleScriptSafariSetURL = `
 on run argv
   set appName to item 1 of argv
-  set urlNeedle to item 2 of argv
-  set newURL to item 3 of argv
+  set wid to (item 2 of argv) as integer
+  set ti to (item 3 of argv) as integer
+  set newURL to item 4 of argv
 
   tell application appName
-    repeat with w in windows
-      repeat with t in tabs of w
-        try
-          set u to URL of t
-          if u contains urlNeedle then
-            set URL of t to newURL
-            return "ok"
-          end if
-        end try
-      end repeat
-    end repeat
+    set URL of tab ti of window id wid to newURL
   end tell
-
-  error "No tab found in " & appName & " with URL containing: " & urlNeedle
+  return "ok"
 end run
 `
 
-const appleScriptChromiumListURLs = `
+// argv: appName. Prints one "windowID<TAB>tabIndex<TAB>url<TAB>title" line per tab.
+const appleScriptChromiumListTabs = `
 using terms from application "Google Chrome"
 on run argv
   set appName to item 1 of argv
-  set urls to {}
+  set sep to character id 9
+  set nl to character id 10
+  set out to ""
 
   tell application appName
     repeat with w in windows
-      repeat with t in tabs of w
-        try
-          set u to URL of t
-          if u is not missing value then
-            copy u to end of urls
-          end if
-        end try
-      end repeat
+      try
+        set wid to id of w
+        set ti to 0
+        repeat with t in tabs of w
+          set ti to ti + 1
+          try
+            set u to URL of t
+            if u is missing value then set u to ""
+            set n to title of t
+            if n is missing value then set n to ""
+            set out to out & wid & sep & ti & sep & u & sep & n & nl
+          end try
+        end repeat
+      end try
     end repeat
   end tell
 
-  if (count of urls) is 0 then
-    return "[]"
-  end if
-
-  set AppleScript's text item delimiters to "\",\""
-  set joined to urls as text
-  set AppleScript's text item delimiters to ""
-  return "[\"" & joined & "\"]"
+  return out
 end run
 end using terms from
 `
 
-const appleScriptSafariListURLs = `
+const appleScriptSafariListTabs = `
 on run argv
   set appName to item 1 of argv
-  set urls to {}
+  set sep to character id 9
+  set nl to character id 10
+  set out to ""
 
   tell application appName
     repeat with w in windows
-      repeat with t in tabs of w
-        try
-          set u to URL of t
-          if u is not missing value then
-            copy u to end of urls
-          end if
-        end try
-      end repeat
+      try
+        set wid to id of w
+        set ti to 0
+        repeat with t in tabs of w
+          set ti to ti + 1
+          try
+            set u to URL of t
+            if u is missing value then set u to ""
+            set n to name of t
+            if n is missing value then set n to ""
+            set out to out & wid & sep & ti & sep & u & sep & n & nl
+          end try
+        end repeat
+      end try
     end repeat
   end tell
 
-  if (count of urls) is 0 then
-    return "[]"
-  end if
-
-  set AppleScript's text item delimiters to "\",\""
-  set joined to urls as text
-  set AppleScript's text item delimiters to ""
-  return "[\"" & joined & "\"]"
+  return out
 end run
 `
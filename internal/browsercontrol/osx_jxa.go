@@ -0,0 +1,231 @@
+package browsercontrol
+
+import (
+	"fmt"
+	"strings"
+)
+
+// JavaScript for Automation (JXA) scripts drive browsers that are not covered by
+// the AppleScript above. Unlike "using terms from", JXA resolves a browser's
+// dictionary at run time, so scripts compile without Google Chrome installed and
+// can check what a custom app supports before using it.
+
+// jxaShebang marks a script as JXA; OSAScript strips it and runs osascript -l JavaScript.
+const jxaShebang = "#!/usr/bin/osascript -l JavaScript\n"
+
+// unsupportedMarker prefixes the error a JXA script throws when the app's
+// dictionary lacks a capability; see unsupportedFromOutput.
+const unsupportedMarker = "pocketcastsctl unsupported: "
+
+// jxaDialect selects which scripting dictionary a JXA script speaks.
+type jxaDialect int
+
+// Safari itself is driven with AppleScript; jxaDetect covers apps that speak its
+// dictionary (app.doJavaScript(js, {in: tab}), tab.name).
+const (
+	jxaChromium jxaDialect = iota // tab.execute({javascript}), tab.title
+	jxaDetect                     // checks the dictionary at run time
+)
+
+func (d jxaDialect) String() string {
+	if d == jxaChromium {
+		return "chromium"
+	}
+	return "detect"
+}
+
+// scriptOp is one thing the osascript backend asks a browser to do.
+type scriptOp int
+
+const (
+	opListTabs scriptOp = iota
+	opRunJS
+	opSetURL
+)
+
+func (op scriptOp) String() string {
+	switch op {
+	case opListTabs:
+		return "list-tabs"
+	case opRunJS:
+		return "run-js"
+	default:
+		return "set-url"
+	}
+}
+
+// capability phrases complete "<app> cannot ... via Apple events".
+func (op scriptOp) capability() string {
+	switch op {
+	case opListTabs:
+		return "list tabs"
+	case opRunJS:
+		return "execute JavaScript in tabs"
+	default:
+		return "set tab URLs"
+	}
+}
+
+// capabilities records what a browser's scripting dictionary is known to support.
+// Browsers using jxaDetect claim everything and are checked when a script runs.
+type capabilities struct {
+	ListTabs bool
+	ExecJS   bool
+	SetURL   bool
+}
+
+func (c capabilities) has(op scriptOp) bool {
+	switch op {
+	case opListTabs:
+		return c.ListTabs
+	case opRunJS:
+		return c.ExecJS
+	default:
+		return c.SetURL
+	}
+}
+
+var allCapabilities = capabilities{ListTabs: true, ExecJS: true, SetURL: true}
+
+// UnsupportedError reports that a browser can't do something through Apple events,
+// either because it is known not to or because its dictionary lacks the command.
+type UnsupportedError struct {
+	App        string
+	Capability string
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("%s cannot %s via Apple events", e.App, e.Capability)
+}
+
+// unsupportedFromOutput turns the error of a JXA script that found a capability
+// missing into an UnsupportedError. osascript reports it as
+// "execution error: Error: pocketcastsctl unsupported: list tabs (-2700)".
+func unsupportedFromOutput(app string, err error) error {
+	msg := err.Error()
+	i := strings.Index(msg, unsupportedMarker)
+	if i < 0 {
+		return err
+	}
+	capability := msg[i+len(unsupportedMarker):]
+	if j := strings.Index(capability, " (-"); j >= 0 {
+		capability = capability[:j]
+	}
+	return &UnsupportedError{App: app, Capability: strings.TrimSpace(capability)}
+}
+
+// jxaScript generates the script for op. The argv layout matches the AppleScript
+// versions: list-tabs (app), run-js (app, windowID, tabIndex, focus, js),
+// set-url (app, windowID, tabIndex, url).
+func jxaScript(d jxaDialect, op scriptOp) string {
+	var body string
+	switch op {
+	case opListTabs:
+		body = strings.ReplaceAll(jxaListTabs, "TITLE", d.titleExpr())
+	case opRunJS:
+		body = jxaRunJSHead + d.execBody() + "}\n"
+	default:
+		body = jxaSetURL
+	}
+	return jxaShebang + jxaUnsupported + body
+}
+
+func (d jxaDialect) titleExpr() string {
+	if d == jxaChromium {
+		return "t.title()"
+	}
+	return `(typeof t.title === "function" ? t.title() : t.name())`
+}
+
+func (d jxaDialect) execBody() string {
+	switch d {
+	case jxaChromium:
+		return jxaExecChromium
+	default:
+		return `  if (typeof app.execute === "function") {
+` + indent(jxaExecChromium) + `  }
+  if (typeof app.doJavaScript === "function") {
+` + indent(jxaExecSafari) + `  }
+  unsupported("execute JavaScript in tabs");
+`
+	}
+}
+
+func indent(s string) string {
+	lines := strings.SplitAfter(s, "\n")
+	for i, l := range lines {
+		if l != "" && l != "\n" {
+			lines[i] = "  " + l
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+const jxaUnsupported = `function unsupported(what) {
+  throw new Error("` + unsupportedMarker + `" + what);
+}
+
+`
+
+const jxaListTabs = `function run(argv) {
+  const app = Application(argv[0]);
+  const lines = [];
+  for (const w of app.windows()) {
+    if (typeof w.tabs !== "function") {
+      unsupported("list tabs");
+    }
+    let wid, tabs;
+    try {
+      wid = w.id();
+      tabs = w.tabs();
+    } catch (e) {
+      continue;
+    }
+    tabs.forEach((t, i) => {
+      try {
+        lines.push([wid, i + 1, t.url() || "", TITLE || ""].join("\t"));
+      } catch (e) {}
+    });
+  }
+  return lines.join("\n");
+}
+`
+
+const jxaRunJSHead = `function run(argv) {
+  const app = Application(argv[0]);
+  const w = app.windows.byId(Number(argv[1]));
+  const ti = Number(argv[2]);
+  const focus = argv[3] === "1";
+  const js = argv[4];
+  const tab = w.tabs[ti - 1];
+
+`
+
+const jxaExecChromium = `  if (focus) {
+    try {
+      w.activeTabIndex = ti;
+    } catch (e) {}
+    try {
+      w.index = 1;
+    } catch (e) {}
+  }
+  return tab.execute({ javascript: js });
+`
+
+const jxaExecSafari = `  return app.doJavaScript(js, { in: tab });
+`
+
+const jxaSetURL = `function run(argv) {
+  const app = Application(argv[0]);
+  const w = app.windows.byId(Number(argv[1]));
+  const ti = Number(argv[2]);
+  if (typeof w.tabs !== "function") {
+    unsupported("set tab URLs");
+  }
+  try {
+    w.activeTabIndex = ti;
+  } catch (e) {}
+  w.tabs[ti - 1].url = argv[3];
+  return "ok";
+}
+`
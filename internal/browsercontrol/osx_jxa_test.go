@@ -0,0 +1,104 @@
+package browsercontrol
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+func TestJXAScriptsGolden(t *testing.T) {
+	for _, d := range []jxaDialect{jxaChromium, jxaDetect} {
+		for _, op := range []scriptOp{opListTabs, opRunJS, opSetURL} {
+			name := filepath.Join("testdata", "jxa", d.String()+"-"+op.String()+".js")
+			got := jxaScript(d, op)
+			if *update {
+				if err := os.WriteFile(name, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				continue
+			}
+			want, err := os.ReadFile(name)
+			if err != nil {
+				t.Fatalf("%v (run go test -update to create it)", err)
+			}
+			if got != string(want) {
+				t.Errorf("%s differs from generated script (run go test -update):\n%s", name, got)
+			}
+		}
+	}
+}
+
+func TestParseBrowserSelectsScripts(t *testing.T) {
+	tests := []struct {
+		name, app string
+		wantApp   string
+		wantRunJS string
+	}{
+		{name: "chrome", wantApp: "Google Chrome", wantRunJS: appleScriptChromium},
+		{name: "Safari", wantApp: "Safari", wantRunJS: appleScriptSafari},
+		{name: "vivaldi", wantApp: "Vivaldi", wantRunJS: jxaScript(jxaChromium, opRunJS)},
+		{name: "chromium", app: "Chromium", wantApp: "Chromium", wantRunJS: jxaScript(jxaChromium, opRunJS)},
+		{name: "orion", wantApp: "Orion", wantRunJS: jxaScript(jxaDetect, opRunJS)},
+		{name: "SigmaOS", wantApp: "SigmaOS", wantRunJS: jxaScript(jxaDetect, opRunJS)},
+	}
+	for _, tt := range tests {
+		b, err := parseBrowser(tt.name, tt.app)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		script, err := b.script(opRunJS)
+		if err != nil || b.appName != tt.wantApp || script != tt.wantRunJS {
+			t.Errorf("%s: app=%q err=%v, unexpected script", tt.name, b.appName, err)
+		}
+	}
+}
+
+func TestUnscriptableBrowserFailsBeforeRunning(t *testing.T) {
+	b, err := parseBrowser("floorp", "")
+	if err != nil || b.capabilities() != (capabilities{}) {
+		t.Fatalf("floorp: %+v err=%v", b, err)
+	}
+
+	// Firefox itself goes to WebDriver BiDi; a fork named as a custom app reaches
+	// the osascript backend and must fail there without running anything.
+	r := &fakeRunner{tabList: defaultTabList}
+	c, err := New(Options{Browser: "LibreWolf", URLContains: "pocketcasts.com", Runner: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Status(context.Background())
+	var u *UnsupportedError
+	if !errors.As(err, &u) || err.Error() != "LibreWolf cannot list tabs via Apple events" {
+		t.Fatalf("err=%v", err)
+	}
+	if r.lists != 0 || len(r.calls) != 0 {
+		t.Fatal("no script should run")
+	}
+}
+
+func TestJXAUnsupportedErrorFromScript(t *testing.T) {
+	r := &fakeRunner{tabList: defaultTabList, err: errors.New("execution error: Error: " + unsupportedMarker + "list tabs (-2700)")}
+	c, err := New(Options{Browser: "SigmaOS", URLContains: "pocketcasts.com", Runner: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Status(context.Background())
+	var u *UnsupportedError
+	if !errors.As(err, &u) || err.Error() != "SigmaOS cannot list tabs via Apple events" {
+		t.Fatalf("err=%v", err)
+	}
+
+	plain := errors.New("Not authorized to send Apple events to SigmaOS. (-1743)")
+	if got := unsupportedFromOutput("SigmaOS", plain); got != plain {
+		t.Fatalf("got %v", got)
+	}
+	if !strings.HasPrefix(jxaScript(jxaDetect, opListTabs), jxaShebang) {
+		t.Fatal("JXA scripts must start with the shebang")
+	}
+}
@@ -0,0 +1,185 @@
+package browsercontrol
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Tab is an open browser tab. Window and Index are 1-based; Window is 0 when the
+// backend can't tell windows apart (CDP).
+type Tab struct {
+	Window int    `json:"window"`
+	Index  int    `json:"index"`
+	Title  string `json:"title"`
+	URL    string `json:"url"`
+
+	// ref addresses the tab in its backend: the AppleScript window id, or the
+	// DevTools WebSocket URL.
+	ref string
+}
+
+// TargetTab is a tab whose URL contains URLContains. N is the number Options.Tab
+// accepts; Selected marks the tab commands act on.
+type TargetTab struct {
+	Tab
+	N        int  `json:"n"`
+	Playing  bool `json:"playing"`
+	Selected bool `json:"selected"`
+}
+
+// Tabs returns every open tab.
+func (c *Controller) Tabs(ctx context.Context) ([]Tab, error) {
+	defer c.hold(ctx)()
+	return c.backend.tabs(ctx)
+}
+
+// TargetTabs lists the tabs commands could act on, checking each for playback.
+func (c *Controller) TargetTabs(ctx context.Context) ([]TargetTab, error) {
+	defer c.hold(ctx)()
+	tabs, err := c.matchingTabs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]TargetTab, len(tabs))
+	for i, t := range tabs {
+		out[i] = TargetTab{Tab: t, N: i + 1, Playing: c.tabPlaying(ctx, t)}
+	}
+	if len(out) > 0 {
+		i, err := c.chooseTab(len(out), func(i int) bool { return out[i].Playing })
+		if err != nil {
+			return out, err
+		}
+		out[i].Selected = true
+	}
+	return out, nil
+}
+
+// resolveTarget picks the tab once and reuses it for the controller's lifetime, so
+// a command that makes several calls never switches tabs halfway.
+func (c *Controller) resolveTarget(ctx context.Context) (Tab, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.target != nil {
+		return *c.target, nil
+	}
+	tabs, err := c.matchingTabs(ctx)
+	if err != nil {
+		return Tab{}, err
+	}
+	if len(tabs) == 0 {
+		return Tab{}, fmt.Errorf("No tab found %s with URL containing: %s", c.backend.describe(), c.urlContains)
+	}
+	i, err := c.chooseTab(len(tabs), func(i int) bool { return c.tabPlaying(ctx, tabs[i]) })
+	if err != nil {
+		return Tab{}, err
+	}
+	c.target = &tabs[i]
+	return tabs[i], nil
+}
+
+func (c *Controller) forgetTarget() {
+	c.mu.Lock()
+	c.target = nil
+	c.mu.Unlock()
+}
+
+func (c *Controller) matchingTabs(ctx context.Context) ([]Tab, error) {
+	tabs, err := c.backend.tabs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := tabs[:0]
+	for _, t := range tabs {
+		if strings.Contains(t.URL, c.urlContains) {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+// chooseTab applies Options.Tab, then prefers a playing tab, then the first one.
+// playing is only consulted when there is a choice to make.
+func (c *Controller) chooseTab(n int, playing func(i int) bool) (int, error) {
+	if c.tabN > 0 {
+		if c.tabN > n {
+			return 0, fmt.Errorf("tab %d not found: %d tab(s) with URL containing %q", c.tabN, n, c.urlContains)
+		}
+		return c.tabN - 1, nil
+	}
+	if n > 1 {
+		for i := 0; i < n; i++ {
+			if playing(i) {
+				return i, nil
+			}
+		}
+	}
+	return 0, nil
+}
+
+// tabPlaying reports whether tab has a media element that is playing. Errors count
+// as not playing.
+func (c *Controller) tabPlaying(ctx context.Context, t Tab) bool {
+	out, err := c.backend.runJS(ctx, t, jsIsPlaying(), false)
+	if err != nil {
+		return false
+	}
+	var res struct {
+		Playing bool `json:"playing"`
+	}
+	return json.Unmarshal([]byte(out), &res) == nil && res.Playing
+}
+
+// parseOSATabs parses the tab list script's output: one "windowID<TAB>tabIndex<TAB>
+// url<TAB>title" line per tab. Tabs are ordered by window id, which unlike AppleScript's
+// front-to-back window index doesn't change when a window is brought forward.
+func parseOSATabs(out string) ([]Tab, error) {
+	type row struct {
+		windowID int
+		tab      Tab
+	}
+	var rows []row
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		f := strings.SplitN(line, "\t", 4)
+		if len(f) < 3 {
+			return nil, fmt.Errorf("unexpected tab list line: %q", line)
+		}
+		wid, err := strconv.Atoi(strings.TrimSpace(f[0]))
+		if err != nil {
+			return nil, fmt.Errorf("unexpected tab list line: %q", line)
+		}
+		idx, err := strconv.Atoi(strings.TrimSpace(f[1]))
+		if err != nil {
+			return nil, fmt.Errorf("unexpected tab list line: %q", line)
+		}
+		t := Tab{Index: idx, URL: f[2], ref: strconv.Itoa(wid)}
+		if len(f) == 4 {
+			t.Title = f[3]
+		}
+		rows = append(rows, row{windowID: wid, tab: t})
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].windowID != rows[j].windowID {
+			return rows[i].windowID < rows[j].windowID
+		}
+		return rows[i].tab.Index < rows[j].tab.Index
+	})
+	tabs := make([]Tab, 0, len(rows))
+	window, lastID := 0, 0
+	for i, r := range rows {
+		if i == 0 || r.windowID != lastID {
+			window++
+			lastID = r.windowID
+		}
+		r.tab.Window = window
+		tabs = append(tabs, r.tab)
+	}
+	return tabs, nil
+}
@@ -0,0 +1,99 @@
+package browsercontrol
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const multiTabList = "" +
+	"9\t1\thttps://play.pocketcasts.com/podcasts\tPocket Casts (work)\n" +
+	"3\t2\thttps://play.pocketcasts.com/up-next\tUp Next\twith a tab\n" +
+	"3\t1\thttps://example.com/\tExample\n" +
+	"9\t2\thttps://play.pocketcasts.com/discover\tDiscover\n"
+
+func TestParseOSATabsOrdersByWindowID(t *testing.T) {
+	tabs, err := parseOSATabs(multiTabList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, tab := range tabs {
+		got = append(got, fmt.Sprintf("%s/%d/%d %s", tab.ref, tab.Window, tab.Index, tab.Title))
+	}
+	want := "3/1/1 Example|3/1/2 Up Next\twith a tab|9/2/1 Pocket Casts (work)|9/2/2 Discover"
+	if strings.Join(got, "|") != want {
+		t.Fatalf("got  %q\nwant %q", strings.Join(got, "|"), want)
+	}
+	if _, err := parseOSATabs("garbage"); err == nil {
+		t.Fatal("expected error for malformed line")
+	}
+}
+
+func TestTargetPrefersPlayingTab(t *testing.T) {
+	r := &fakeRunner{tabList: multiTabList, playing: map[string]bool{"9/2": true}, outputs: []string{`{"clicked":true,"clickedLabel":"Pause"}`}}
+	c, err := New(Options{URLContains: "pocketcasts.com", Runner: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := c.Do(context.Background(), ActionPause); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, call := range r.calls {
+		if call.args[1] != "9" || call.args[2] != "2" {
+			t.Fatalf("expected the playing tab (window 9, tab 2), got %q", call.args[:3])
+		}
+	}
+	if r.lists != 1 {
+		t.Fatalf("the target should be resolved once, lists=%d", r.lists)
+	}
+	if strings.Join(r.probed, ",") != "3/2,9/1,9/2" {
+		t.Fatalf("probed=%v", r.probed)
+	}
+}
+
+func TestTargetExplicitTab(t *testing.T) {
+	r := &fakeRunner{tabList: multiTabList, playing: map[string]bool{"9/2": true}, outputs: []string{`{}`}}
+	c, err := New(Options{URLContains: "pocketcasts.com", Runner: r, Tab: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Status(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.calls[0].args[1] + "/" + r.calls[0].args[2]; got != "9/1" || len(r.probed) != 0 {
+		t.Fatalf("target=%s probed=%v", got, r.probed)
+	}
+
+	c, _ = New(Options{URLContains: "pocketcasts.com", Runner: r, Tab: 4})
+	if _, err := c.Status(context.Background()); err == nil || !strings.Contains(err.Error(), "tab 4 not found: 3 tab(s)") {
+		t.Fatalf("err=%v", err)
+	}
+}
+
+func TestTargetTabsAndNoMatch(t *testing.T) {
+	r := &fakeRunner{tabList: multiTabList, playing: map[string]bool{"9/2": true}}
+	c, err := New(Options{URLContains: "pocketcasts.com", Runner: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tabs, err := c.TargetTabs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tabs) != 3 || tabs[0].N != 1 || tabs[0].Title != "Up Next\twith a tab" {
+		t.Fatalf("tabs=%+v", tabs)
+	}
+	if !tabs[2].Playing || !tabs[2].Selected || tabs[0].Selected {
+		t.Fatalf("expected tab 3 playing and selected: %+v", tabs)
+	}
+
+	c, _ = New(Options{Browser: "safari", URLContains: "nowhere", Runner: r})
+	_, err = c.Status(context.Background())
+	if err == nil || err.Error() != "No tab found in Safari with URL containing: nowhere" {
+		t.Fatalf("err=%v", err)
+	}
+}
@@ -0,0 +1,27 @@
+#!/usr/bin/osascript -l JavaScript
+function unsupported(what) {
+  throw new Error("pocketcastsctl unsupported: " + what);
+}
+
+function run(argv) {
+  const app = Application(argv[0]);
+  const lines = [];
+  for (const w of app.windows()) {
+    if (typeof w.tabs !== "function") {
+      unsupported("list tabs");
+    }
+    let wid, tabs;
+    try {
+      wid = w.id();
+      tabs = w.tabs();
+    } catch (e) {
+      continue;
+    }
+    tabs.forEach((t, i) => {
+      try {
+        lines.push([wid, i + 1, t.url() || "", t.title() || ""].join("\t"));
+      } catch (e) {}
+    });
+  }
+  return lines.join("\n");
+}
@@ -0,0 +1,23 @@
+#!/usr/bin/osascript -l JavaScript
+function unsupported(what) {
+  throw new Error("pocketcastsctl unsupported: " + what);
+}
+
+function run(argv) {
+  const app = Application(argv[0]);
+  const w = app.windows.byId(Number(argv[1]));
+  const ti = Number(argv[2]);
+  const focus = argv[3] === "1";
+  const js = argv[4];
+  const tab = w.tabs[ti - 1];
+
+  if (focus) {
+    try {
+      w.activeTabIndex = ti;
+    } catch (e) {}
+    try {
+      w.index = 1;
+    } catch (e) {}
+  }
+  return tab.execute({ javascript: js });
+}
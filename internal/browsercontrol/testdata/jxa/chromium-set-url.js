@@ -0,0 +1,18 @@
+#!/usr/bin/osascript -l JavaScript
+function unsupported(what) {
+  throw new Error("pocketcastsctl unsupported: " + what);
+}
+
+function run(argv) {
+  const app = Application(argv[0]);
+  const w = app.windows.byId(Number(argv[1]));
+  const ti = Number(argv[2]);
+  if (typeof w.tabs !== "function") {
+    unsupported("set tab URLs");
+  }
+  try {
+    w.activeTabIndex = ti;
+  } catch (e) {}
+  w.tabs[ti - 1].url = argv[3];
+  return "ok";
+}
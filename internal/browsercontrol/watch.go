@@ -0,0 +1,198 @@
+package browsercontrol
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type WatchEventType string
+
+const (
+	WatchOpened   WatchEventType = "opened"   // the tab was found (also the first event)
+	WatchEpisode  WatchEventType = "episode"  // a different episode is loaded
+	WatchPlay     WatchEventType = "play"     // playback started or resumed
+	WatchPause    WatchEventType = "pause"    // playback paused
+	WatchPosition WatchEventType = "position" // periodic tick while playing
+	WatchClosed   WatchEventType = "closed"   // the tab is gone or unreachable
+)
+
+// WatchEvent is one state change. Status is the page state after the change and is
+// nil for WatchClosed, which carries the reason in Error instead.
+type WatchEvent struct {
+	Time   time.Time      `json:"time"`
+	Type   WatchEventType `json:"type"`
+	Status *StatusResult  `json:"status,omitempty"`
+	Error  string         `json:"error,omitempty"`
+}
+
+type WatchOptions struct {
+	// Tick is the interval of position events while playing; 0 disables them.
+	Tick time.Duration
+	// Polling starts at MinInterval and backs off to MaxInterval while nothing changes.
+	MinInterval time.Duration
+	MaxInterval time.Duration
+}
+
+const watchCallTimeout = 10 * time.Second
+
+// statusWatcher fetches the status script's output repeatedly. Backends that can
+// keep a connection open (CDP) implement it to avoid per-poll setup and to wake the
+// loop on page media events.
+type statusWatcher interface {
+	status(ctx context.Context) (string, error)
+	// wake fires when the page reports a media event; nil if unsupported.
+	wake() <-chan struct{}
+	// close drops any connection; the next status call reconnects.
+	close()
+}
+
+type watcherBackend interface {
+	watcher(target func(context.Context) (Tab, error)) statusWatcher
+}
+
+// Watch polls the Web Player until ctx is done and calls emit for every change.
+// It returns nil when ctx is cancelled and emit's error if emit fails.
+func (c *Controller) Watch(ctx context.Context, opts WatchOptions, emit func(WatchEvent) error) error {
+	if opts.MinInterval <= 0 {
+		opts.MinInterval = time.Second
+	}
+	if opts.MaxInterval < opts.MinInterval {
+		opts.MaxInterval = opts.MinInterval
+	}
+
+	var w statusWatcher = pollWatcher{c: c}
+	if wb, ok := c.backend.(watcherBackend); ok {
+		w = wb.watcher(c.resolveTarget)
+	}
+	defer w.close()
+
+	var (
+		prev       *StatusResult
+		seenClosed bool
+		lastEvent  time.Time
+		interval   = opts.MinInterval
+	)
+	for {
+		callCtx, cancel := context.WithTimeout(ctx, watchCallTimeout)
+		st, err := c.watchStatus(callCtx, w)
+		cancel()
+		if ctx.Err() != nil {
+			return nil
+		}
+		now := time.Now()
+
+		var events []WatchEvent
+		if err != nil {
+			w.close()
+			c.forgetTarget()
+			if prev != nil || !seenClosed {
+				events = append(events, WatchEvent{Time: now, Type: WatchClosed, Error: err.Error()})
+			}
+			prev, seenClosed = nil, true
+		} else {
+			for _, typ := range statusEvents(prev, st) {
+				events = append(events, WatchEvent{Time: now, Type: typ, Status: &st})
+			}
+			if len(events) == 0 && st.State == "playing" && opts.Tick > 0 && now.Sub(lastEvent) >= opts.Tick {
+				events = append(events, WatchEvent{Time: now, Type: WatchPosition, Status: &st})
+			}
+			prev = &st
+		}
+
+		changed := false
+		for _, e := range events {
+			if err := emit(e); err != nil {
+				return err
+			}
+			lastEvent = now
+			if e.Type != WatchPosition {
+				changed = true
+			}
+		}
+
+		if changed {
+			interval = opts.MinInterval
+		} else {
+			interval = min(interval*2, opts.MaxInterval)
+		}
+		wait := interval
+		if prev != nil && prev.State == "playing" && opts.Tick > 0 {
+			wait = min(wait, opts.Tick)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		case <-w.wake():
+			timer.Stop()
+			interval = opts.MinInterval
+		}
+	}
+}
+
+func (c *Controller) watchStatus(ctx context.Context, w statusWatcher) (StatusResult, error) {
+	out, err := w.status(ctx)
+	if err != nil {
+		return StatusResult{}, err
+	}
+	st, err := decodeStatus(out)
+	if err != nil {
+		return StatusResult{}, err
+	}
+	// A CDP session stays attached to its tab when it navigates elsewhere.
+	if st.URL != "" && !strings.Contains(st.URL, c.urlContains) {
+		return StatusResult{}, fmt.Errorf("tab navigated away (url=%s)", st.URL)
+	}
+	return st, nil
+}
+
+// statusEvents lists what changed between two polls. prev is nil when the tab has
+// just been found.
+func statusEvents(prev *StatusResult, cur StatusResult) []WatchEventType {
+	if prev == nil {
+		return []WatchEventType{WatchOpened}
+	}
+	var out []WatchEventType
+	if k := episodeKey(cur); k != "" && k != episodeKey(*prev) {
+		out = append(out, WatchEpisode)
+	}
+	if cur.State != prev.State {
+		switch cur.State {
+		case "playing":
+			out = append(out, WatchPlay)
+		case "paused":
+			out = append(out, WatchPause)
+		}
+	}
+	return out
+}
+
+func episodeKey(st StatusResult) string {
+	if st.EpisodeUUID != "" {
+		return st.EpisodeUUID
+	}
+	title := strings.TrimSpace(st.Title)
+	if title == "" {
+		return ""
+	}
+	return title + "|" + strings.TrimSpace(st.Podcast)
+}
+
+// pollWatcher runs the status script through the backend on every poll, without
+// focusing the tab.
+type pollWatcher struct {
+	c *Controller
+}
+
+func (p pollWatcher) status(ctx context.Context) (string, error) {
+	return p.c.runJSFocus(ctx, jsStatus(), false)
+}
+
+func (pollWatcher) wake() <-chan struct{} { return nil }
+
+func (pollWatcher) close() {}
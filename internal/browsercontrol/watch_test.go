@@ -0,0 +1,125 @@
+package browsercontrol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type runnerFunc func(ctx context.Context, script string, args ...string) (string, error)
+
+func (f runnerFunc) Run(ctx context.Context, script string, args ...string) (string, error) {
+	return f(ctx, script, args...)
+}
+
+func TestStatusEvents(t *testing.T) {
+	playing := StatusResult{State: "playing", EpisodeUUID: "e1"}
+	tests := []struct {
+		name string
+		prev *StatusResult
+		cur  StatusResult
+		want []WatchEventType
+	}{
+		{name: "first", prev: nil, cur: playing, want: []WatchEventType{WatchOpened}},
+		{name: "unchanged", prev: &playing, cur: playing, want: nil},
+		{name: "pause", prev: &playing, cur: StatusResult{State: "paused", EpisodeUUID: "e1"}, want: []WatchEventType{WatchPause}},
+		{name: "episode", prev: &playing, cur: StatusResult{State: "playing", EpisodeUUID: "e2"}, want: []WatchEventType{WatchEpisode}},
+		{name: "title fallback", prev: &StatusResult{State: "paused", Title: "A"}, cur: StatusResult{State: "playing", Title: "B"}, want: []WatchEventType{WatchEpisode, WatchPlay}},
+		{name: "unknown state", prev: &playing, cur: StatusResult{State: "unknown"}, want: nil},
+	}
+	for _, tt := range tests {
+		got := statusEvents(tt.prev, tt.cur)
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWatchPollsAndReportsClosedTab(t *testing.T) {
+	// Each step is one poll. A closed step fails the script on the cached tab, then
+	// lists no tabs when the controller looks for it again.
+	steps := []struct {
+		out    string
+		closed bool
+	}{
+		{out: `{"state":"paused","episodeUuid":"e1"}`},
+		{out: `{"state":"playing","episodeUuid":"e1","currentTime":1}`},
+		{out: `{"state":"playing","episodeUuid":"e1","currentTime":2}`},
+		{out: `{"state":"playing","episodeUuid":"e2"}`},
+		{closed: true},
+		{closed: true},
+		{out: `{"state":"playing","episodeUuid":"e2","url":"https://example.com/"}`},
+		{out: `{"state":"paused","episodeUuid":"e2","url":"https://play.pocketcasts.com/"}`},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	i := 0
+	r := runnerFunc(func(_ context.Context, script string, _ ...string) (string, error) {
+		if i >= len(steps) {
+			cancel()
+			return "", ctx.Err()
+		}
+		s := steps[i]
+		if script == appleScriptChromiumListTabs {
+			if s.closed {
+				i++
+				return "", nil
+			}
+			return defaultTabList, nil
+		}
+		i++
+		if s.closed {
+			return "", errors.New("Google Chrome got an error: Can't get window id 7. (-1728)")
+		}
+		return s.out, nil
+	})
+	c, err := New(Options{URLContains: "pocketcasts.com", Runner: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	err = c.Watch(ctx, WatchOptions{Tick: time.Nanosecond, MinInterval: time.Millisecond, MaxInterval: 2 * time.Millisecond}, func(e WatchEvent) error {
+		s := string(e.Type)
+		if e.Status != nil {
+			s += ":" + e.Status.State
+		}
+		got = append(got, s)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"opened:paused", "play:playing", "position:playing", "episode:playing", "closed", "opened:paused"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("events:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestWatchDoesNotFocusTab(t *testing.T) {
+	c, r := newFakeController(t, "chrome", `{"state":"playing","episodeUuid":"e1"}`)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	polls := 0
+	err := c.Watch(ctx, WatchOptions{Tick: time.Nanosecond, MinInterval: time.Millisecond, MaxInterval: 2 * time.Millisecond}, func(WatchEvent) error {
+		if polls++; polls >= 3 {
+			cancel()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.calls) == 0 {
+		t.Fatal("watch ran no scripts")
+	}
+	for _, call := range r.calls {
+		if call.args[3] != "0" {
+			t.Fatalf("watch poll focused the tab: args=%q", call.args[:4])
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package capture
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"pocketcastsctl/internal/fsutil"
+)
+
+// CA is the local certificate authority the proxy signs intercepted hosts with.
+// Clients trust it once (see CertPath); its key never leaves the config dir.
+type CA struct {
+	CertPath string
+	cert     *x509.Certificate
+	key      crypto.Signer
+
+	mu     sync.Mutex
+	leaves map[string]*tls.Certificate
+}
+
+// DefaultDomains are the domains a CA for the default --host may sign for. The
+// Pocket Casts web player and API live under these.
+var DefaultDomains = []string{"pocketcasts.com", "pocketcasts.net"}
+
+// CA lifetimes. A CA limited by name constraints can only impersonate Pocket Casts
+// and lasts a year; one that can sign for any host is worth replacing sooner.
+const (
+	constrainedCALifetime   = 365 * 24 * time.Hour
+	unconstrainedCALifetime = 30 * 24 * time.Hour
+)
+
+// leafKey is shared by every host certificate; generating one key per host would
+// slow down the first request to each.
+var (
+	leafKeyOnce sync.Once
+	leafKey     *ecdsa.PrivateKey
+	leafKeyErr  error
+)
+
+// LoadOrCreateCA reads the CA from certPath and keyPath, creating both on first
+// use or once the CA has expired. A new CA may only sign for domains (and their
+// subdomains); nil domains make a CA that can sign for any host, including IP
+// addresses. created reports whether a new CA was written, which clients need to
+// trust before intercepted requests succeed.
+func LoadOrCreateCA(certPath, keyPath string, domains []string) (ca *CA, created bool, err error) {
+	ca, err = loadCA(certPath, keyPath)
+	switch {
+	case err == nil && time.Now().Before(ca.cert.NotAfter):
+		if !slices.Equal(ca.cert.PermittedDNSDomains, domains) {
+			return nil, false, fmt.Errorf("capture CA %s was made for other hosts (%s); remove it from the trust store and delete it", certPath, strings.Join(ca.cert.PermittedDNSDomains, ", "))
+		}
+		return ca, false, nil
+	case err == nil:
+		// Expired: replace it, as clients reject its certificates anyway.
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, false, err
+	}
+	ca, err = createCA(certPath, keyPath, domains)
+	if err != nil {
+		return nil, false, err
+	}
+	return ca, true, nil
+}
+
+func loadCA(certPath, keyPath string) (*CA, error) {
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("load capture CA: %w", err)
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("load capture CA: %w", err)
+	}
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok || !cert.IsCA {
+		return nil, fmt.Errorf("load capture CA: %s is not a CA certificate and key", certPath)
+	}
+	return &CA{CertPath: certPath, cert: cert, key: key, leaves: map[string]*tls.Certificate{}}, nil
+}
+
+func createCA(certPath, keyPath string, domains []string) (*CA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "pocketcastsctl capture CA", Organization: []string{"pocketcastsctl"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(unconstrainedCALifetime),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	if len(domains) > 0 {
+		tmpl.NotAfter = now.Add(constrainedCALifetime)
+		tmpl.PermittedDNSDomainsCritical = true
+		tmpl.PermittedDNSDomains = domains
+		// No IP addresses at all.
+		tmpl.ExcludedIPRanges = []*net.IPNet{
+			{IP: net.IPv4zero, Mask: net.CIDRMask(0, 32)},
+			{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)},
+		}
+	} else {
+		tmpl.Subject.CommonName = "pocketcastsctl capture CA (all hosts)"
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	// The key first: a certificate without its key would fail every later load.
+	if err := fsutil.WriteFileAtomic(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		return nil, err
+	}
+	if err := fsutil.WriteFileAtomic(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		return nil, err
+	}
+	return &CA{CertPath: certPath, cert: cert, key: key, leaves: map[string]*tls.Certificate{}}, nil
+}
+
+// Certificate returns the CA certificate, e.g. to add to an x509.CertPool.
+func (ca *CA) Certificate() *x509.Certificate {
+	return ca.cert
+}
+
+// Permits reports whether the CA may sign for host: clients reject certificates
+// for names outside its constraints, so the proxy tunnels those instead.
+func (ca *CA) Permits(host string) bool {
+	if len(ca.cert.PermittedDNSDomains) == 0 {
+		return true
+	}
+	if net.ParseIP(host) != nil {
+		return false
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, d := range ca.cert.PermittedDNSDomains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// leaf returns a certificate for host (a name or IP address) signed by the CA.
+func (ca *CA) leaf(host string) (*tls.Certificate, error) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	if c := ca.leaves[host]; c != nil && time.Now().Before(c.Leaf.NotAfter) {
+		return c, nil
+	}
+
+	leafKeyOnce.Do(func() { leafKey, leafKeyErr = ecdsa.GenerateKey(elliptic.P256(), rand.Reader) })
+	if leafKeyErr != nil {
+		return nil, leafKeyErr
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: host},
+		NotBefore:    now.Add(-time.Hour),
+		// Clients reject leaf certificates valid for more than 398 days.
+		NotAfter:    now.AddDate(0, 0, 397),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if tmpl.NotAfter.After(ca.cert.NotAfter) {
+		tmpl.NotAfter = ca.cert.NotAfter
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		tmpl.IPAddresses = []net.IP{ip}
+	} else {
+		tmpl.DNSNames = []string{host}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &leafKey.PublicKey, ca.key)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	c := &tls.Certificate{Certificate: [][]byte{der, ca.cert.Raw}, PrivateKey: leafKey, Leaf: leaf}
+	ca.leaves[host] = c
+	return c, nil
+}
+
+func randomSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
@@ -0,0 +1,225 @@
+package capture
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pocketcastsctl/internal/har"
+)
+
+func TestLoadOrCreateCA(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "ca-key.pem")
+
+	ca, created, err := LoadOrCreateCA(certPath, keyPath, DefaultDomains)
+	if err != nil || !created {
+		t.Fatalf("first LoadOrCreateCA: created=%v err=%v", created, err)
+	}
+	if fi, err := os.Stat(keyPath); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Fatalf("key file: %v %v", fi.Mode(), err)
+	}
+	again, created, err := LoadOrCreateCA(certPath, keyPath, DefaultDomains)
+	if err != nil || created {
+		t.Fatalf("second LoadOrCreateCA: created=%v err=%v", created, err)
+	}
+	if !again.Certificate().Equal(ca.Certificate()) {
+		t.Fatal("second load returned a different CA")
+	}
+
+	leaf, err := again.leaf("api.pocketcasts.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Certificate())
+	if _, err := leaf.Leaf.Verify(x509.VerifyOptions{DNSName: "api.pocketcasts.com", Roots: roots}); err != nil {
+		t.Fatalf("leaf does not verify against the CA: %v", err)
+	}
+	if ca.Certificate().NotAfter.After(time.Now().Add(constrainedCALifetime)) || leaf.Leaf.NotAfter.After(ca.Certificate().NotAfter) {
+		t.Errorf("CA valid until %v, leaf until %v", ca.Certificate().NotAfter, leaf.Leaf.NotAfter)
+	}
+
+	// The CA can't be used to impersonate anything else.
+	for _, host := range []string{"example.com", "pocketcasts.com.example.com", "127.0.0.1"} {
+		if again.Permits(host) {
+			t.Errorf("CA permits %s", host)
+		}
+		other, err := again.leaf(host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := other.Leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: roots}); err == nil {
+			t.Errorf("leaf for %s verifies", host)
+		}
+	}
+	if !again.Permits("cache.pocketcasts.net") {
+		t.Error("CA does not permit cache.pocketcasts.net")
+	}
+
+	if _, _, err := LoadOrCreateCA(certPath, keyPath, nil); err == nil {
+		t.Error("loading the constrained CA for any host succeeded")
+	}
+}
+
+type testProxy struct {
+	ca     *CA
+	out    *har.Writer
+	path   string
+	client *http.Client
+}
+
+// newTestProxy starts a proxy recording hosts containing host to a temp file,
+// with a client that uses it and trusts the CA and upstream's certificate.
+func newTestProxy(t *testing.T, upstream *httptest.Server, host string, maxBody int64) *testProxy {
+	t.Helper()
+	dir := t.TempDir()
+	ca, _, err := LoadOrCreateCA(filepath.Join(dir, "ca.pem"), filepath.Join(dir, "ca-key.pem"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "capture.har")
+	out, err := har.CreateWriter(path, "pocketcastsctl", "test", har.DefaultRedactOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { out.Close() })
+
+	p := New(ca, out, Options{Host: host, MaxBodySize: maxBody, Transport: upstream.Client().Transport})
+	srv := httptest.NewServer(p)
+	t.Cleanup(srv.Close)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Certificate())
+	if upstream.Certificate() != nil {
+		roots.AddCert(upstream.Certificate())
+	}
+	proxyURL, _ := url.Parse(srv.URL)
+	tr := &http.Transport{Proxy: http.ProxyURL(proxyURL), TLSClientConfig: &tls.Config{RootCAs: roots}}
+	t.Cleanup(tr.CloseIdleConnections)
+	return &testProxy{ca: ca, out: out, path: path, client: &http.Client{Transport: tr}}
+}
+
+func (tp *testProxy) do(t *testing.T, method, url, body string) string {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer secret-token")
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := tp.client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func upstreamHandler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret-token" {
+			t.Errorf("upstream got Authorization %q", r.Header.Get("Authorization"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/user/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "cookie-secret"})
+			io.WriteString(w, `{"token":"fresh-secret","uuid":"abc"}`)
+		case "/big":
+			io.WriteString(w, `{"episodes":"`+strings.Repeat("x", 4096)+`"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func TestProxyRecordsHTTPS(t *testing.T) {
+	upstream := httptest.NewTLSServer(upstreamHandler(t))
+	defer upstream.Close()
+	tp := newTestProxy(t, upstream, "127.0.0.1", 1024)
+
+	if got := tp.do(t, "POST", upstream.URL+"/user/login?v=2", `{"email":"me@example.com"}`); got != `{"token":"fresh-secret","uuid":"abc"}` {
+		t.Fatalf("client got %q", got)
+	}
+	if got := tp.do(t, "GET", upstream.URL+"/big", ""); len(got) < 4096 {
+		t.Fatalf("client got %d bytes of the large body", len(got))
+	}
+
+	// The file is complete while the capture is still running.
+	f, err := har.ReadFile(tp.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Log.Entries) != 2 || tp.out.Len() != 2 {
+		t.Fatalf("recorded %d entries", len(f.Log.Entries))
+	}
+	login, big := f.Log.Entries[0], f.Log.Entries[1]
+	if login.Request.URL != upstream.URL+"/user/login?v=2" || login.Response.Status != 200 {
+		t.Errorf("login entry: %s -> %d", login.Request.URL, login.Response.Status)
+	}
+	if !strings.Contains(login.Response.Content.Text, `"uuid":"abc"`) {
+		t.Errorf("response body not recorded: %q", login.Response.Content.Text)
+	}
+	if big.Response.Content.Text != "" || !strings.Contains(big.Response.Content.Comment, "body skipped") {
+		t.Errorf("large body recorded: %+v", big.Response.Content)
+	}
+
+	b, err := os.ReadFile(tp.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"secret-token", "fresh-secret", "cookie-secret", "me@example.com"} {
+		if strings.Contains(string(b), secret) {
+			t.Errorf("capture contains %q", secret)
+		}
+	}
+}
+
+func TestProxyOnlyRecordsMatchingHosts(t *testing.T) {
+	t.Run("http", func(t *testing.T) {
+		upstream := httptest.NewServer(upstreamHandler(t))
+		defer upstream.Close()
+		tp := newTestProxy(t, upstream, "pocketcasts", 0)
+		if got := tp.do(t, "GET", upstream.URL+"/user/login", ""); !strings.Contains(got, "fresh-secret") {
+			t.Fatalf("client got %q", got)
+		}
+		if n := tp.out.Len(); n != 0 {
+			t.Fatalf("recorded %d entries for another host", n)
+		}
+	})
+
+	t.Run("https", func(t *testing.T) {
+		upstream := httptest.NewTLSServer(upstreamHandler(t))
+		defer upstream.Close()
+		tp := newTestProxy(t, upstream, "pocketcasts", 0)
+		req, _ := http.NewRequest("GET", upstream.URL+"/user/login", nil)
+		req.Header.Set("Authorization", "Bearer secret-token")
+		resp, err := tp.client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		// Tunnelled, not intercepted: the client saw upstream's own certificate.
+		if !resp.TLS.PeerCertificates[0].Equal(upstream.Certificate()) {
+			t.Error("connection to another host was intercepted")
+		}
+		if n := tp.out.Len(); n != 0 {
+			t.Fatalf("recorded %d entries for another host", n)
+		}
+	})
+}
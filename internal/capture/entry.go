@@ -0,0 +1,164 @@
+package capture
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"pocketcastsctl/internal/har"
+)
+
+// entry is a HAR 1.2 entry, with the fields browsers export.
+type entry struct {
+	StartedDateTime string   `json:"startedDateTime"`
+	Time            float64  `json:"time"`
+	Request         request  `json:"request"`
+	Response        response `json:"response"`
+	Cache           struct{} `json:"cache"`
+	Timings         timings  `json:"timings"`
+	Comment         string   `json:"comment,omitempty"`
+}
+
+type request struct {
+	Method      string           `json:"method"`
+	URL         string           `json:"url"`
+	HTTPVersion string           `json:"httpVersion"`
+	Headers     []har.Header     `json:"headers"`
+	Cookies     []har.Cookie     `json:"cookies"`
+	QueryString []har.QueryParam `json:"queryString"`
+	PostData    *har.PostData    `json:"postData,omitempty"`
+	HeadersSize int              `json:"headersSize"`
+	BodySize    int64            `json:"bodySize"`
+}
+
+type response struct {
+	Status      int          `json:"status"`
+	StatusText  string       `json:"statusText"`
+	HTTPVersion string       `json:"httpVersion"`
+	Headers     []har.Header `json:"headers"`
+	Cookies     []har.Cookie `json:"cookies"`
+	Content     content      `json:"content"`
+	RedirectURL string       `json:"redirectURL"`
+	HeadersSize int          `json:"headersSize"`
+	BodySize    int64        `json:"bodySize"`
+}
+
+type content struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// setText stores body as text, or base64 if it isn't UTF-8.
+func (c *content) setText(body []byte) {
+	if utf8.Valid(body) {
+		c.Text = string(body)
+		return
+	}
+	c.Text = base64.StdEncoding.EncodeToString(body)
+	c.Encoding = "base64"
+}
+
+// timings are in milliseconds; -1 marks phases a proxy doesn't see.
+type timings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func newEntry(started time.Time, r *http.Request, body []byte) *entry {
+	e := &entry{
+		StartedDateTime: started.UTC().Format("2006-01-02T15:04:05.000Z07:00"),
+		Request: request{
+			Method:      r.Method,
+			URL:         r.URL.String(),
+			HTTPVersion: r.Proto,
+			Headers:     harHeaders(r.Header, r.Host),
+			Cookies:     []har.Cookie{},
+			QueryString: []har.QueryParam{},
+			HeadersSize: -1,
+			BodySize:    int64(len(body)),
+		},
+		Response: response{Headers: []har.Header{}, Cookies: []har.Cookie{}, HeadersSize: -1, BodySize: -1},
+		Timings:  timings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1, Wait: -1, Receive: -1},
+	}
+	for _, c := range r.Cookies() {
+		e.Request.Cookies = append(e.Request.Cookies, har.Cookie{Name: c.Name, Value: c.Value})
+	}
+	q := r.URL.Query()
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range q[k] {
+			e.Request.QueryString = append(e.Request.QueryString, har.QueryParam{Name: k, Value: v})
+		}
+	}
+	if len(body) > 0 {
+		e.Request.PostData = &har.PostData{MimeType: r.Header.Get("Content-Type"), Text: string(body)}
+	}
+	return e
+}
+
+func newResponse(resp *http.Response) response {
+	out := response{
+		Status:      resp.StatusCode,
+		StatusText:  strings.TrimSpace(strings.TrimPrefix(resp.Status, fmt.Sprint(resp.StatusCode))),
+		HTTPVersion: resp.Proto,
+		Headers:     harHeaders(resp.Header, ""),
+		Cookies:     []har.Cookie{},
+		Content:     content{MimeType: resp.Header.Get("Content-Type")},
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    -1,
+	}
+	for _, c := range resp.Cookies() {
+		out.Cookies = append(out.Cookies, har.Cookie{Name: c.Name, Value: c.Value})
+	}
+	return out
+}
+
+// finish fills in the total time and the receive phase once the body is done.
+func (e *entry) finish(started, waited time.Time) {
+	now := time.Now()
+	e.Time = ms(now.Sub(started))
+	e.Timings.Send = 0
+	if e.Response.Status != 0 {
+		e.Timings.Receive = ms(now.Sub(waited))
+	}
+}
+
+// harHeaders lists h in name order; host, if set, is added as the Host header,
+// which net/http keeps out of the map.
+func harHeaders(h http.Header, host string) []har.Header {
+	out := []har.Header{}
+	if host != "" {
+		out = append(out, har.Header{Name: "Host", Value: host})
+	}
+	names := make([]string, 0, len(h))
+	for k := range h {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		for _, v := range h[k] {
+			out = append(out, har.Header{Name: k, Value: v})
+		}
+	}
+	return out
+}
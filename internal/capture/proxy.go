@@ -0,0 +1,411 @@
+// Package capture is a local HTTP(S) forward proxy that records Pocket Casts
+// traffic as a redacted HAR, as an alternative to exporting one from the
+// browser's network panel.
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"pocketcastsctl/internal/har"
+)
+
+type Options struct {
+	// Host selects the hosts to record: those whose name contains it. HTTPS to
+	// other hosts is tunnelled without being decrypted. "" records everything.
+	Host string
+	// MaxBodySize is the largest response body recorded; larger ones are passed
+	// through and noted in content.comment. 0 records every body.
+	MaxBodySize int64
+	// Transport sends requests upstream. nil uses a copy of
+	// http.DefaultTransport that ignores proxy environment variables.
+	Transport http.RoundTripper
+	// Logf, if set, is called once per recorded request and for errors.
+	Logf func(format string, args ...any)
+}
+
+// Proxy is an http.Handler for use as an HTTP and HTTPS proxy. CONNECT requests
+// to recorded hosts are answered with a certificate signed by the CA, so clients
+// must trust it.
+type Proxy struct {
+	ca        *CA
+	out       *har.Writer
+	host      string
+	maxBody   int64
+	transport http.RoundTripper
+	logf      func(format string, args ...any)
+}
+
+func New(ca *CA, out *har.Writer, opts Options) *Proxy {
+	p := &Proxy{
+		ca:        ca,
+		out:       out,
+		host:      strings.ToLower(strings.TrimSpace(opts.Host)),
+		maxBody:   opts.MaxBodySize,
+		transport: opts.Transport,
+		logf:      opts.Logf,
+	}
+	if p.transport == nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.Proxy = nil // HTTPS_PROXY may point back at us
+		p.transport = t
+	}
+	return p
+}
+
+// hopHeaders describe one connection, not the request, and are not forwarded.
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+func removeHopHeaders(h http.Header) {
+	for _, v := range h.Values("Connection") {
+		for _, name := range strings.Split(v, ",") {
+			h.Del(strings.TrimSpace(name))
+		}
+	}
+	for _, name := range hopHeaders {
+		h.Del(name)
+	}
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		p.connect(w, r)
+		return
+	}
+	if !r.URL.IsAbs() {
+		http.Error(w, "pocketcastsctl capture is a proxy: configure it as the HTTP and HTTPS proxy", http.StatusBadRequest)
+		return
+	}
+	p.forward(w, r)
+}
+
+func (p *Proxy) match(host string) bool {
+	return p.host == "" || strings.Contains(strings.ToLower(host), p.host)
+}
+
+func (p *Proxy) log(format string, args ...any) {
+	if p.logf != nil {
+		p.logf(format, args...)
+	}
+}
+
+// connect answers a CONNECT request. Recorded hosts the CA may sign for get a TLS
+// server with a certificate from the CA; the rest get a plain tunnel.
+func (p *Proxy) connect(w http.ResponseWriter, r *http.Request) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "CONNECT is not supported on this connection", http.StatusInternalServerError)
+		return
+	}
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		p.log("CONNECT %s: %v", r.Host, err)
+		return
+	}
+	if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+		conn.Close()
+		return
+	}
+	client := bufferedConn(conn, brw.Reader)
+	if !p.match(r.URL.Hostname()) || !p.ca.Permits(r.URL.Hostname()) {
+		p.tunnel(client, r.Host)
+		return
+	}
+	p.intercept(client, r.Host)
+}
+
+func (p *Proxy) tunnel(client net.Conn, addr string) {
+	upstream, err := net.DialTimeout("tcp", addr, 30*time.Second)
+	if err != nil {
+		p.log("CONNECT %s: %v", addr, err)
+		client.Close()
+		return
+	}
+	pipe(client, upstream)
+}
+
+// intercept terminates TLS from the client and serves its requests through
+// forward, which sends them on to addr.
+func (p *Proxy) intercept(client net.Conn, addr string) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host, port = addr, "443"
+	}
+	target := host
+	if port != "443" {
+		target = addr
+	}
+
+	conn := tls.Server(client, &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"http/1.1"},
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			name := hello.ServerName
+			if name == "" {
+				name = host
+			}
+			return p.ca.leaf(name)
+		},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := conn.HandshakeContext(ctx); err != nil {
+		p.log("%s: TLS handshake failed (does the client trust %s?): %v", addr, p.ca.CertPath, err)
+		conn.Close()
+		return
+	}
+
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.URL.Scheme = "https"
+			r.URL.Host = target
+			p.forward(w, r)
+		}),
+		ReadHeaderTimeout: 30 * time.Second,
+		ErrorLog:          log.New(io.Discard, "", 0),
+	}
+	_ = srv.Serve(newConnListener(conn))
+}
+
+// forward sends r upstream, streams the response back and records both if the
+// host matches.
+func (p *Proxy) forward(w http.ResponseWriter, r *http.Request) {
+	started := time.Now()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+	upgrade := ""
+	if httpTokenContains(r.Header, "Connection", "upgrade") {
+		upgrade = r.Header.Get("Upgrade")
+	}
+	removeHopHeaders(out.Header)
+	if upgrade != "" {
+		out.Header.Set("Connection", "Upgrade")
+		out.Header.Set("Upgrade", upgrade)
+	}
+	// Let the transport negotiate compression and decode it, so bodies are
+	// recorded as text.
+	out.Header.Del("Accept-Encoding")
+
+	record := p.match(r.URL.Hostname())
+	e := newEntry(started, r, body)
+	resp, err := p.transport.RoundTrip(out)
+	if err != nil {
+		p.log("%s %s: %v", r.Method, r.URL, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		if record {
+			e.Comment = "pocketcastsctl: " + err.Error()
+			e.finish(started, time.Now())
+			p.write(e, 0)
+		}
+		return
+	}
+	defer resp.Body.Close()
+	waited := time.Now()
+	e.Timings.Wait = ms(waited.Sub(started))
+	e.Response = newResponse(resp)
+
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		if record {
+			e.finish(started, waited)
+			p.write(e, 0)
+		}
+		p.switchProtocols(w, resp)
+		return
+	}
+
+	removeHopHeaders(resp.Header)
+	for k, vs := range resp.Header {
+		w.Header()[k] = vs
+	}
+	w.WriteHeader(resp.StatusCode)
+	rec := &bodyRecorder{limit: p.maxBody}
+	n, err := io.Copy(flushWriter{w}, io.TeeReader(resp.Body, rec))
+	if err != nil {
+		p.log("%s %s: copying response: %v", r.Method, r.URL, err)
+	}
+	if !record {
+		return
+	}
+	var skipped int64
+	if rec.over {
+		skipped = n
+	} else {
+		e.Response.Content.setText(rec.buf.Bytes())
+	}
+	e.Response.Content.Size = n
+	e.Response.BodySize = n
+	e.finish(started, waited)
+	p.write(e, skipped)
+}
+
+func (p *Proxy) write(e *entry, skipped int64) {
+	if err := p.out.WriteEntry(e, skipped); err != nil {
+		p.log("recording %s %s: %v", e.Request.Method, e.Request.URL, err)
+		return
+	}
+	p.log("%s %s -> %d", e.Request.Method, e.Request.URL, e.Response.Status)
+}
+
+// switchProtocols relays an upgraded connection (a WebSocket) after a 101
+// response. Only the handshake is recorded.
+func (p *Proxy) switchProtocols(w http.ResponseWriter, resp *http.Response) {
+	upstream, ok := resp.Body.(io.ReadWriteCloser)
+	hj, hok := w.(http.Hijacker)
+	if !ok || !hok {
+		http.Error(w, "cannot relay upgraded connection", http.StatusBadGateway)
+		return
+	}
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	resp.Body = http.NoBody
+	if err := resp.Write(conn); err != nil {
+		conn.Close()
+		upstream.Close()
+		return
+	}
+	pipe(bufferedConn(conn, brw.Reader), upstream)
+}
+
+// pipe copies between a and b until either side is done, then closes both.
+func pipe(a, b io.ReadWriteCloser) {
+	done := make(chan struct{}, 2)
+	cp := func(dst io.Writer, src io.Reader) {
+		_, _ = io.Copy(dst, src)
+		done <- struct{}{}
+	}
+	go cp(a, b)
+	go cp(b, a)
+	<-done
+	a.Close()
+	b.Close()
+	<-done
+}
+
+func httpTokenContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bodyRecorder keeps the first limit bytes written to it (all if limit is 0),
+// and notes whether there were more.
+type bodyRecorder struct {
+	limit int64
+	buf   bytes.Buffer
+	over  bool
+}
+
+func (b *bodyRecorder) Write(p []byte) (int, error) {
+	if b.over {
+		return len(p), nil
+	}
+	if b.limit > 0 && int64(b.buf.Len()+len(p)) > b.limit {
+		b.over = true
+		b.buf = bytes.Buffer{}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// flushWriter flushes after every write, so streamed responses reach the client
+// as they arrive.
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if fl, ok := f.w.(http.Flusher); ok {
+		fl.Flush()
+	}
+	return n, err
+}
+
+// bufferedConn returns conn reading through r, which may already hold bytes the
+// client sent (such as its TLS hello).
+func bufferedConn(conn net.Conn, r *bufio.Reader) net.Conn {
+	if r == nil || r.Buffered() == 0 {
+		return conn
+	}
+	return &readerConn{Conn: conn, r: r}
+}
+
+type readerConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *readerConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// connListener hands an http.Server one connection that was accepted
+// elsewhere; Accept then blocks until the connection is closed.
+type connListener struct {
+	conn      net.Conn
+	accepted  sync.Once
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func newConnListener(conn net.Conn) *connListener {
+	return &connListener{conn: conn, closed: make(chan struct{})}
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	var c net.Conn
+	l.accepted.Do(func() { c = &listenerConn{Conn: l.conn, l: l} })
+	if c != nil {
+		return c, nil
+	}
+	<-l.closed
+	return nil, net.ErrClosed
+}
+
+func (l *connListener) Close() error   { return nil }
+func (l *connListener) Addr() net.Addr { return l.conn.LocalAddr() }
+
+type listenerConn struct {
+	net.Conn
+	l *connListener
+}
+
+func (c *listenerConn) Close() error {
+	c.l.closeOnce.Do(func() { close(c.l.closed) })
+	return c.Conn.Close()
+}
@@ -0,0 +1,82 @@
+package chapters
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Chapter is a single navigable chapter. Times are in seconds from the start of the episode.
+type Chapter struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end,omitempty"`
+	Title string  `json:"title"`
+	URL   string  `json:"url,omitempty"`
+}
+
+// Sort orders chapters by start time, keeping the original order for ties.
+func Sort(chs []Chapter) {
+	sort.SliceStable(chs, func(i, j int) bool { return chs[i].Start < chs[j].Start })
+}
+
+// Index returns the index of the chapter playing at pos, or -1 if pos is before the first chapter.
+func Index(chs []Chapter, pos float64) int {
+	idx := -1
+	for i, ch := range chs {
+		if ch.Start <= pos {
+			idx = i
+			continue
+		}
+		break
+	}
+	return idx
+}
+
+// Resolve maps a selector (next, prev, or a 1-based chapter number) to a chapter index,
+// relative to the chapter playing at pos.
+func Resolve(chs []Chapter, pos float64, sel string) (int, error) {
+	if len(chs) == 0 {
+		return 0, fmt.Errorf("no chapters")
+	}
+	sel = strings.ToLower(strings.TrimSpace(sel))
+	cur := Index(chs, pos)
+	switch sel {
+	case "next", "n":
+		if cur+1 >= len(chs) {
+			return 0, fmt.Errorf("already at the last chapter")
+		}
+		return cur + 1, nil
+	case "prev", "previous", "p":
+		// Like most players: jump to the start of the current chapter unless we are near it.
+		if cur >= 0 && pos-chs[cur].Start > 3 {
+			return cur, nil
+		}
+		if cur <= 0 {
+			return 0, nil
+		}
+		return cur - 1, nil
+	}
+	n, err := strconv.Atoi(sel)
+	if err != nil {
+		return 0, fmt.Errorf("invalid chapter selector %q (use next, prev or a number)", sel)
+	}
+	if n <= 0 || n > len(chs) {
+		return 0, fmt.Errorf("chapter out of range: %d (1..%d)", n, len(chs))
+	}
+	return n - 1, nil
+}
+
+// FormatTime renders seconds as h:mm:ss or m:ss.
+func FormatTime(sec float64) string {
+	if sec < 0 {
+		sec = 0
+	}
+	s := int(sec)
+	h, m := s/3600, (s%3600)/60
+	s = s % 60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
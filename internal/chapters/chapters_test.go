@@ -0,0 +1,201 @@
+package chapters
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// id3Frame builds a frame for the given tag version (3 or 4).
+func id3Frame(major byte, id string, data []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString(id)
+	if major == 4 {
+		b.Write(syncsafeBytes(len(data)))
+	} else {
+		_ = binary.Write(&b, binary.BigEndian, uint32(len(data)))
+	}
+	b.Write([]byte{0, 0})
+	b.Write(data)
+	return b.Bytes()
+}
+
+func id3Tag(major byte, frames ...[]byte) []byte {
+	body := bytes.Join(frames, nil)
+	body = append(body, make([]byte, 16)...) // padding
+	var b bytes.Buffer
+	b.WriteString("ID3")
+	b.Write([]byte{major, 0, 0})
+	b.Write(syncsafeBytes(len(body)))
+	b.Write(body)
+	// trailing audio data must not be consumed
+	b.Write([]byte{0xFF, 0xFB, 0x90, 0x00})
+	return b.Bytes()
+}
+
+func chapFrame(major byte, id string, startMS, endMS uint32, sub ...[]byte) []byte {
+	var b bytes.Buffer
+	b.WriteString(id)
+	b.WriteByte(0)
+	_ = binary.Write(&b, binary.BigEndian, startMS)
+	_ = binary.Write(&b, binary.BigEndian, endMS)
+	_ = binary.Write(&b, binary.BigEndian, uint32(0xFFFFFFFF))
+	_ = binary.Write(&b, binary.BigEndian, uint32(0xFFFFFFFF))
+	for _, s := range sub {
+		b.Write(s)
+	}
+	return id3Frame(major, "CHAP", b.Bytes())
+}
+
+func ctocFrame(major byte, children ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("toc")
+	b.WriteByte(0)
+	b.WriteByte(0x03) // top-level, ordered
+	b.WriteByte(byte(len(children)))
+	for _, c := range children {
+		b.WriteString(c)
+		b.WriteByte(0)
+	}
+	return id3Frame(major, "CTOC", b.Bytes())
+}
+
+func tit2(major byte, enc byte, s string) []byte {
+	data := []byte{enc}
+	switch enc {
+	case 1:
+		data = append(data, 0xFF, 0xFE)
+		for _, r := range s {
+			data = append(data, byte(r), byte(r>>8))
+		}
+	default:
+		data = append(data, s...)
+	}
+	return id3Frame(major, "TIT2", data)
+}
+
+func syncsafeBytes(n int) []byte {
+	return []byte{byte(n>>21) & 0x7f, byte(n>>14) & 0x7f, byte(n>>7) & 0x7f, byte(n) & 0x7f}
+}
+
+func TestReadID3OrdersByTOC(t *testing.T) {
+	for _, major := range []byte{3, 4} {
+		tag := id3Tag(major,
+			id3Frame(major, "TIT2", []byte("\x03Episode")),
+			chapFrame(major, "ch1", 90_000, 180_000, tit2(major, 1, "Second")),
+			chapFrame(major, "ch0", 0, 90_000, tit2(major, 3, "Intro")),
+			ctocFrame(major, "ch0", "ch1"),
+		)
+		r := bytes.NewReader(tag)
+		chs, err := ReadID3(r)
+		if err != nil {
+			t.Fatalf("v2.%d: %v", major, err)
+		}
+		if len(chs) != 2 {
+			t.Fatalf("v2.%d: len=%d", major, len(chs))
+		}
+		if chs[0].Title != "Intro" || chs[0].Start != 0 || chs[0].End != 90 {
+			t.Fatalf("v2.%d: unexpected first: %+v", major, chs[0])
+		}
+		if chs[1].Title != "Second" || chs[1].Start != 90 {
+			t.Fatalf("v2.%d: unexpected second: %+v", major, chs[1])
+		}
+		if r.Len() != 4 {
+			t.Fatalf("v2.%d: consumed past the tag (remaining=%d)", major, r.Len())
+		}
+	}
+}
+
+func TestReadID3WithoutTOCSortsByStart(t *testing.T) {
+	wxxx := id3Frame(3, "WXXX", []byte("\x00desc\x00https://example.com/b"))
+	tag := id3Tag(3,
+		chapFrame(3, "b", 60_000, 120_000, tit2(3, 0, "B"), wxxx),
+		chapFrame(3, "a", 0, 60_000),
+	)
+	chs, err := ReadID3(bytes.NewReader(tag))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chs) != 2 || chs[0].Title != "a" || chs[1].Title != "B" {
+		t.Fatalf("unexpected chapters: %+v", chs)
+	}
+	if chs[1].URL != "https://example.com/b" {
+		t.Fatalf("URL=%q", chs[1].URL)
+	}
+}
+
+func TestReadID3NoTag(t *testing.T) {
+	if _, err := ReadID3(strings.NewReader("\xFF\xFB\x90\x00 not a tag")); err != ErrNoID3 {
+		t.Fatalf("err=%v", err)
+	}
+	chs, err := ReadID3(bytes.NewReader(id3Tag(4, id3Frame(4, "TIT2", []byte("\x03x")))))
+	if err != nil || len(chs) != 0 {
+		t.Fatalf("chs=%+v err=%v", chs, err)
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	chs, err := ParseJSON([]byte(`{"version":"1.2.0","chapters":[
+  {"startTime":300.5,"title":"Main"},
+  {"startTime":0,"title":"Intro","url":"https://example.com"},
+  {"startTime":120,"title":"Hidden","toc":false}
+]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chs) != 2 || chs[0].Title != "Intro" || chs[1].Start != 300.5 {
+		t.Fatalf("unexpected chapters: %+v", chs)
+	}
+}
+
+func TestFindFeedChapters(t *testing.T) {
+	feed := `<?xml version="1.0"?>
+<rss version="2.0" xmlns:podcast="https://podcastindex.org/namespace/1.0">
+<channel>
+  <item><guid>a</guid><enclosure url="https://cdn.example.com/a.mp3"/></item>
+  <item>
+    <guid>b</guid>
+    <enclosure url="https://cdn.example.com/b.mp3?tracking=1"/>
+    <podcast:chapters url="https://example.com/b.json" type="application/json+chapters"/>
+  </item>
+</channel>
+</rss>`
+	got, err := findFeedChapters(strings.NewReader(feed), "https://cdn.example.com/b.mp3?other=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "https://example.com/b.json" {
+		t.Fatalf("got %q", got)
+	}
+	if _, err := findFeedChapters(strings.NewReader(feed), "https://cdn.example.com/a.mp3"); err == nil {
+		t.Fatal("expected error for episode without chapters")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	chs := []Chapter{{Start: 0, Title: "a"}, {Start: 60, Title: "b"}, {Start: 120, Title: "c"}}
+	tests := []struct {
+		pos  float64
+		sel  string
+		want int
+	}{
+		{pos: 10, sel: "next", want: 1},
+		{pos: 70, sel: "prev", want: 1},
+		{pos: 61, sel: "prev", want: 0},
+		{pos: 1, sel: "prev", want: 0},
+		{pos: 0, sel: "3", want: 2},
+	}
+	for _, tt := range tests {
+		got, err := Resolve(chs, tt.pos, tt.sel)
+		if err != nil || got != tt.want {
+			t.Fatalf("Resolve(%v, %q) = %d, %v; want %d", tt.pos, tt.sel, got, err, tt.want)
+		}
+	}
+	if _, err := Resolve(chs, 130, "next"); err == nil {
+		t.Fatal("expected error past last chapter")
+	}
+	if _, err := Resolve(chs, 0, "4"); err == nil {
+		t.Fatal("expected out of range error")
+	}
+}
@@ -0,0 +1,139 @@
+package chapters
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ParseJSON parses a Podcasting 2.0 chapters document (application/json+chapters).
+// Chapters marked "toc": false are silent markers and are skipped.
+func ParseJSON(b []byte) ([]Chapter, error) {
+	var doc struct {
+		Chapters []struct {
+			StartTime float64 `json:"startTime"`
+			EndTime   float64 `json:"endTime"`
+			Title     string  `json:"title"`
+			URL       string  `json:"url"`
+			TOC       *bool   `json:"toc"`
+		} `json:"chapters"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("parse chapters JSON: %w", err)
+	}
+	out := make([]Chapter, 0, len(doc.Chapters))
+	for _, c := range doc.Chapters {
+		if c.TOC != nil && !*c.TOC {
+			continue
+		}
+		out = append(out, Chapter{
+			Start: c.StartTime,
+			End:   c.EndTime,
+			Title: strings.TrimSpace(c.Title),
+			URL:   strings.TrimSpace(c.URL),
+		})
+	}
+	Sort(out)
+	return out, nil
+}
+
+// FetchID3 reads chapters from the ID3 tag at the start of a remote MP3.
+// Only the tag is downloaded; the body is closed as soon as the tag has been read.
+func FetchID3(ctx context.Context, audioURL, userAgent string) ([]Chapter, error) {
+	resp, err := get(ctx, audioURL, userAgent)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ReadID3(resp.Body)
+}
+
+// FetchFeed finds the episode whose enclosure matches audioURL in an RSS feed and
+// loads the chapters document referenced by its <podcast:chapters> element.
+func FetchFeed(ctx context.Context, feedURL, audioURL, userAgent string) ([]Chapter, error) {
+	resp, err := get(ctx, feedURL, userAgent)
+	if err != nil {
+		return nil, err
+	}
+	chaptersURL, err := findFeedChapters(resp.Body, audioURL)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err = get(ctx, chaptersURL, userAgent)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, err
+	}
+	return ParseJSON(b)
+}
+
+func findFeedChapters(r io.Reader, audioURL string) (string, error) {
+	var feed struct {
+		Items []struct {
+			GUID      string `xml:"guid"`
+			Enclosure struct {
+				URL string `xml:"url,attr"`
+			} `xml:"enclosure"`
+			Chapters struct {
+				URL  string `xml:"url,attr"`
+				Type string `xml:"type,attr"`
+			} `xml:"https://podcastindex.org/namespace/1.0 chapters"`
+		} `xml:"channel>item"`
+	}
+	if err := xml.NewDecoder(r).Decode(&feed); err != nil {
+		return "", fmt.Errorf("parse feed: %w", err)
+	}
+	want := normalizeMediaURL(audioURL)
+	for _, it := range feed.Items {
+		if normalizeMediaURL(it.Enclosure.URL) != want && strings.TrimSpace(it.GUID) != strings.TrimSpace(audioURL) {
+			continue
+		}
+		if strings.TrimSpace(it.Chapters.URL) == "" {
+			return "", errors.New("episode has no podcast:chapters element in feed")
+		}
+		return strings.TrimSpace(it.Chapters.URL), nil
+	}
+	return "", errors.New("episode not found in feed")
+}
+
+// normalizeMediaURL drops the query string, which hosts often use for tracking.
+func normalizeMediaURL(u string) string {
+	u = strings.TrimSpace(u)
+	if i := strings.IndexByte(u, '?'); i >= 0 {
+		u = u[:i]
+	}
+	return strings.ToLower(u)
+}
+
+func get(ctx context.Context, urlStr, userAgent string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSpace(urlStr), nil)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(userAgent) != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		resp.Body.Close()
+		return nil, fmt.Errorf("http %d: %s", resp.StatusCode, string(b))
+	}
+	return resp, nil
+}
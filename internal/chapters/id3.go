@@ -0,0 +1,301 @@
+package chapters
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxTagSize bounds how much of an ID3 tag we are willing to buffer (embedded artwork can be large).
+const maxTagSize = 32 << 20
+
+// ErrNoID3 is returned when the input does not start with an ID3v2 tag.
+var ErrNoID3 = errors.New("no ID3v2 tag found")
+
+// ReadFile reads ID3v2 CHAP/CTOC chapters from an audio file on disk.
+func ReadFile(path string) ([]Chapter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadID3(f)
+}
+
+// ReadID3 reads the ID3v2 tag at the start of r and returns its chapters.
+// Chapters are ordered by the top-level CTOC frame when present, otherwise by start time.
+// Only the tag itself is consumed from r.
+func ReadID3(r io.Reader) ([]Chapter, error) {
+	var hdr [10]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, ErrNoID3
+		}
+		return nil, err
+	}
+	if string(hdr[:3]) != "ID3" {
+		return nil, ErrNoID3
+	}
+	major := hdr[3]
+	flags := hdr[5]
+	size := syncsafe(hdr[6:10])
+	if size > maxTagSize {
+		return nil, fmt.Errorf("ID3 tag too large: %d bytes", size)
+	}
+	if major < 3 || major > 4 {
+		// ID3v2.2 has no chapter frames.
+		return nil, nil
+	}
+
+	tag := make([]byte, size)
+	if _, err := io.ReadFull(r, tag); err != nil {
+		return nil, fmt.Errorf("read ID3 tag: %w", err)
+	}
+	if flags&0x80 != 0 && major == 3 {
+		tag = unsynchronise(tag)
+	}
+	if flags&0x40 != 0 {
+		tag = skipExtendedHeader(tag, major)
+	}
+
+	frames := parseFrames(tag, major)
+
+	var chs []Chapter
+	byID := map[string]int{}
+	var toc []string
+	for _, fr := range frames {
+		switch fr.id {
+		case "CHAP":
+			id, ch, ok := parseCHAP(fr.data, major)
+			if !ok {
+				continue
+			}
+			byID[id] = len(chs)
+			chs = append(chs, ch)
+		case "CTOC":
+			children, topLevel, ok := parseCTOC(fr.data)
+			if ok && (topLevel || toc == nil) {
+				toc = children
+			}
+		}
+	}
+	if len(chs) == 0 {
+		return nil, nil
+	}
+
+	if len(toc) > 0 {
+		ordered := make([]Chapter, 0, len(chs))
+		used := map[int]bool{}
+		for _, id := range toc {
+			if i, ok := byID[id]; ok && !used[i] {
+				used[i] = true
+				ordered = append(ordered, chs[i])
+			}
+		}
+		if len(ordered) > 0 {
+			return ordered, nil
+		}
+	}
+	Sort(chs)
+	return chs, nil
+}
+
+type frame struct {
+	id   string
+	data []byte
+}
+
+func parseFrames(b []byte, major byte) []frame {
+	var out []frame
+	for len(b) >= 10 {
+		id := string(b[:4])
+		if b[0] == 0 || !validFrameID(id) {
+			// padding or garbage
+			break
+		}
+		var n int
+		if major == 4 {
+			n = syncsafe(b[4:8])
+		} else {
+			n = int(binary.BigEndian.Uint32(b[4:8]))
+		}
+		b = b[10:]
+		if n < 0 || n > len(b) {
+			break
+		}
+		out = append(out, frame{id: id, data: b[:n]})
+		b = b[n:]
+	}
+	return out
+}
+
+func parseCHAP(b []byte, major byte) (string, Chapter, bool) {
+	id, rest, ok := cutNull(b)
+	if !ok || len(rest) < 16 {
+		return "", Chapter{}, false
+	}
+	startMS := binary.BigEndian.Uint32(rest[0:4])
+	endMS := binary.BigEndian.Uint32(rest[4:8])
+	ch := Chapter{
+		Start: float64(startMS) / 1000,
+		End:   float64(endMS) / 1000,
+	}
+	for _, sub := range parseFrames(rest[16:], major) {
+		switch sub.id {
+		case "TIT2":
+			ch.Title = strings.TrimSpace(decodeText(sub.data))
+		case "WXXX":
+			ch.URL = decodeWXXX(sub.data)
+		}
+	}
+	if ch.Title == "" {
+		ch.Title = id
+	}
+	return id, ch, true
+}
+
+func parseCTOC(b []byte) ([]string, bool, bool) {
+	_, rest, ok := cutNull(b)
+	if !ok || len(rest) < 2 {
+		return nil, false, false
+	}
+	topLevel := rest[0]&0x02 != 0
+	count := int(rest[1])
+	rest = rest[2:]
+	children := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		var id string
+		id, rest, ok = cutNull(rest)
+		if !ok {
+			break
+		}
+		children = append(children, id)
+	}
+	return children, topLevel, true
+}
+
+func decodeText(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	enc, b := b[0], b[1:]
+	switch enc {
+	case 1, 2:
+		return decodeUTF16(b, enc == 2)
+	case 3:
+		return strings.TrimRight(string(b), "\x00")
+	default:
+		return latin1(bytes.TrimRight(b, "\x00"))
+	}
+}
+
+func decodeWXXX(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	enc, rest := b[0], b[1:]
+	// Skip the description, whose terminator depends on the encoding.
+	if enc == 1 || enc == 2 {
+		for i := 0; i+1 < len(rest); i += 2 {
+			if rest[i] == 0 && rest[i+1] == 0 {
+				return strings.TrimSpace(latin1(bytes.TrimRight(rest[i+2:], "\x00")))
+			}
+		}
+		return ""
+	}
+	_, u, ok := cutNull(rest)
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(latin1(bytes.TrimRight(u, "\x00")))
+}
+
+func decodeUTF16(b []byte, bigEndian bool) string {
+	if len(b) >= 2 {
+		switch {
+		case b[0] == 0xFF && b[1] == 0xFE:
+			bigEndian = false
+			b = b[2:]
+		case b[0] == 0xFE && b[1] == 0xFF:
+			bigEndian = true
+			b = b[2:]
+		}
+	}
+	u := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		var v uint16
+		if bigEndian {
+			v = uint16(b[i])<<8 | uint16(b[i+1])
+		} else {
+			v = uint16(b[i+1])<<8 | uint16(b[i])
+		}
+		if v == 0 {
+			break
+		}
+		u = append(u, v)
+	}
+	return string(utf16.Decode(u))
+}
+
+func latin1(b []byte) string {
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
+
+func cutNull(b []byte) (string, []byte, bool) {
+	i := bytes.IndexByte(b, 0)
+	if i < 0 {
+		return "", nil, false
+	}
+	return string(b[:i]), b[i+1:], true
+}
+
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+func unsynchronise(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	for i := 0; i < len(b); i++ {
+		out = append(out, b[i])
+		if b[i] == 0xFF && i+1 < len(b) && b[i+1] == 0x00 {
+			i++
+		}
+	}
+	return out
+}
+
+func skipExtendedHeader(b []byte, major byte) []byte {
+	if len(b) < 4 {
+		return b
+	}
+	var n int
+	if major == 4 {
+		// v2.4: size includes itself.
+		n = syncsafe(b[:4])
+	} else {
+		// v2.3: size excludes the 4 size bytes.
+		n = int(binary.BigEndian.Uint32(b[:4])) + 4
+	}
+	if n < 0 || n > len(b) {
+		return nil
+	}
+	return b[n:]
+}
+
+func validFrameID(id string) bool {
+	for _, r := range id {
+		if !('A' <= r && r <= 'Z') && !('0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -6,12 +6,16 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"pocketcastsctl/internal/fsutil"
 )
 
 type Config struct {
 	Browser     string            `json:"browser"`
 	BrowserApp  string            `json:"browser_app"`
 	URLContains string            `json:"url_contains"`
+	CDPURL      string            `json:"cdp_url,omitempty"`
+	BiDiURL     string            `json:"bidi_url,omitempty"`
 	APIBaseURL  string            `json:"api_base_url"`
 	APIHeaders  map[string]string `json:"api_headers"`
 }
@@ -42,6 +46,30 @@ func StatePath() string {
 	return filepath.Join(Dir(), "state.json")
 }
 
+func HistoryPath() string {
+	return filepath.Join(Dir(), "history.jsonl")
+}
+
+// CaptureCAPath and CaptureCAKeyPath hold the certificate authority `capture`
+// signs intercepted Pocket Casts hosts with. It can't sign for other domains.
+func CaptureCAPath() string {
+	return filepath.Join(Dir(), "capture-ca.pem")
+}
+
+func CaptureCAKeyPath() string {
+	return filepath.Join(Dir(), "capture-ca-key.pem")
+}
+
+// CaptureAnyCAPath and CaptureAnyCAKeyPath hold the CA used when `capture
+// --host` records other hosts, which can sign for any of them.
+func CaptureAnyCAPath() string {
+	return filepath.Join(Dir(), "capture-any-ca.pem")
+}
+
+func CaptureAnyCAKeyPath() string {
+	return filepath.Join(Dir(), "capture-any-ca-key.pem")
+}
+
 func Load() (Config, error) {
 	p := Path()
 	b, err := os.ReadFile(p)
@@ -74,15 +102,39 @@ func Load() (Config, error) {
 	return cfg, nil
 }
 
+// Save atomically replaces the config file. Callers that load, modify and save
+// should use Update so concurrent invocations don't lose each other's changes.
 func Save(cfg Config) error {
-	p := Path()
-	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
-		return err
-	}
 	b, err := json.MarshalIndent(cfg, "", "  ")
 	if err != nil {
 		return err
 	}
 	b = append(b, '\n')
-	return os.WriteFile(p, b, 0o600)
+	return fsutil.WriteFileAtomic(Path(), b, 0o600)
+}
+
+// Lock takes the advisory lock guarding read-modify-write cycles of the config file.
+func Lock() (func() error, error) {
+	return fsutil.Lock(Path())
+}
+
+// Update re-reads the config under the lock, applies fn and saves the result.
+func Update(fn func(cfg *Config) error) (Config, error) {
+	unlock, err := Lock()
+	if err != nil {
+		return Config{}, err
+	}
+	defer unlock()
+
+	cfg, err := Load()
+	if err != nil {
+		return Config{}, err
+	}
+	if err := fn(&cfg); err != nil {
+		return Config{}, err
+	}
+	if err := Save(cfg); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
 }
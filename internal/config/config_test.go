@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+const (
+	stressGoroutines = 8
+	stressProcesses  = 4
+	stressIterations = 25
+)
+
+// TestHelperConfigProcess is re-executed by TestConcurrentUpdate as a separate process.
+func TestHelperConfigProcess(t *testing.T) {
+	worker := os.Getenv("POCKETCASTSCTL_CONFIG_STRESS")
+	if worker == "" {
+		t.Skip("helper process")
+	}
+	if err := hammer(worker); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// hammer bumps a per-worker header so a lost update shows up as a stale counter.
+func hammer(worker string) error {
+	key := "X-Stress-" + worker
+	for i := 0; i < stressIterations; i++ {
+		_, err := Update(func(cfg *Config) error {
+			n, _ := strconv.Atoi(cfg.APIHeaders[key])
+			cfg.APIHeaders[key] = strconv.Itoa(n + 1)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := Load(); err != nil {
+			return fmt.Errorf("load: %w", err)
+		}
+	}
+	return nil
+}
+
+func setConfigHome(t *testing.T) {
+	dir := t.TempDir()
+	// os.UserConfigDir uses XDG_CONFIG_HOME on Linux and $HOME/Library on macOS.
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+}
+
+func TestConcurrentUpdate(t *testing.T) {
+	setConfigHome(t)
+
+	var cmds []*exec.Cmd
+	for i := 0; i < stressProcesses; i++ {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestHelperConfigProcess$")
+		cmd.Env = append(os.Environ(), "POCKETCASTSCTL_CONFIG_STRESS=proc"+strconv.Itoa(i))
+		cmd.Stderr = os.Stderr
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		cmds = append(cmds, cmd)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, stressGoroutines)
+	for i := 0; i < stressGoroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := hammer("goroutine" + strconv.Itoa(i)); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	for _, cmd := range cmds {
+		if err := cmd.Wait(); err != nil {
+			t.Fatalf("helper process: %v", err)
+		}
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(cfg.APIHeaders), stressGoroutines+stressProcesses; got != want {
+		t.Fatalf("headers=%d want %d: %v", got, want, cfg.APIHeaders)
+	}
+	for k, v := range cfg.APIHeaders {
+		if v != strconv.Itoa(stressIterations) {
+			t.Fatalf("lost updates for %s: %s", k, v)
+		}
+	}
+}
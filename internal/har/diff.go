@@ -0,0 +1,424 @@
+package har
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type DiffOptions struct {
+	Host string
+}
+
+// Diff compares two captures of the same flows, typically one recorded when the
+// queue api commands were written and one recorded now. A capture only shows the
+// requests that were made, so an endpoint missing from the new file may simply
+// not have been exercised.
+type Diff struct {
+	Added   []string       `json:"added,omitempty"`   // endpoints only in the new capture
+	Removed []string       `json:"removed,omitempty"` // endpoints only in the old capture
+	Changed []EndpointDiff `json:"changed,omitempty"`
+	GraphQL []Change       `json:"graphql,omitempty"`
+}
+
+// EndpointDiff lists the changes to an endpoint present in both captures, e.g.
+// "POST api.pocketcasts.com/up_next/list" or "POST play.pocketcasts.com/graphql (Login)".
+type EndpointDiff struct {
+	Endpoint string   `json:"endpoint"`
+	Changes  []Change `json:"changes"`
+}
+
+// Change is one difference. Where names a body field ("response.episodes[].uuid"),
+// a header requirement ("header authz") or a GraphQL operation or variable. Old and
+// New describe the field ("optional string (uuid)") or are empty when it is absent.
+// Breaking marks changes that can break existing callers: removed endpoints and
+// response fields, type changes, and new requirements on requests.
+type Change struct {
+	Kind     string `json:"kind"` // added|removed|changed
+	Where    string `json:"where"`
+	Old      string `json:"old,omitempty"`
+	New      string `json:"new,omitempty"`
+	Breaking bool   `json:"breaking,omitempty"`
+}
+
+// Empty reports whether the captures describe the same API.
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 && len(d.GraphQL) == 0
+}
+
+// Breaking reports whether any difference can break existing callers.
+func (d Diff) Breaking() bool {
+	if len(d.Removed) > 0 {
+		return true
+	}
+	for _, c := range d.GraphQL {
+		if c.Breaking {
+			return true
+		}
+	}
+	for _, ep := range d.Changed {
+		for _, c := range ep.Changes {
+			if c.Breaking {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// headerHints are the Summarize hints that are request requirements.
+var headerHints = []string{"authz", "cookie", "csrf"}
+
+// DiffFiles compares two HAR files, reading each in a single streaming pass.
+func DiffFiles(oldPath, newPath string, opts DiffOptions) (Diff, error) {
+	oldViews, err := captureViewsFile(oldPath, opts)
+	if err != nil {
+		return Diff{}, err
+	}
+	newViews, err := captureViewsFile(newPath, opts)
+	if err != nil {
+		return Diff{}, err
+	}
+	return compareViews(oldViews, newViews), nil
+}
+
+func Compare(oldFile, newFile File, opts DiffOptions) Diff {
+	return compareViews(fileViews(oldFile, opts), fileViews(newFile, opts))
+}
+
+// captureViews are the views of a capture that a Diff compares, built together
+// so a file is read once.
+type captureViews struct {
+	summary Summary
+	schemas []EndpointSchema
+	graphQL GraphQLOpsSummary
+}
+
+type viewCollector struct {
+	sm *summarizer
+	si *schemaInferrer
+	gq *graphQLCollector
+}
+
+func newViewCollector(opts DiffOptions) *viewCollector {
+	return &viewCollector{
+		sm: newSummarizer(SummarizeOptions{Host: opts.Host}),
+		si: newSchemaInferrer(SchemaOptions{Host: opts.Host}),
+		gq: newGraphQLCollector(GraphQLOpsOptions{Host: opts.Host}),
+	}
+}
+
+func (c *viewCollector) add(e Entry) {
+	c.sm.add(e)
+	c.si.add(e)
+	c.gq.add(e)
+}
+
+func (c *viewCollector) views() captureViews {
+	return captureViews{summary: c.sm.summary(), schemas: c.si.schemas(), graphQL: c.gq.summary()}
+}
+
+func captureViewsFile(path string, opts DiffOptions) (captureViews, error) {
+	c := newViewCollector(opts)
+	if err := eachEntryFile(path, DefaultMaxBodySize, func(_ int, e Entry) error {
+		c.add(e)
+		return nil
+	}); err != nil {
+		return captureViews{}, err
+	}
+	return c.views(), nil
+}
+
+func fileViews(f File, opts DiffOptions) captureViews {
+	c := newViewCollector(opts)
+	for _, e := range f.Log.Entries {
+		c.add(e)
+	}
+	return c.views()
+}
+
+func compareViews(oldViews, newViews captureViews) Diff {
+	var d Diff
+	changes := map[string][]Change{}
+
+	// Endpoints and header requirements come from Summarize, with paths templated
+	// like the schemas so per-podcast URLs don't show up as added and removed.
+	oldHints, newHints := endpointHints(oldViews.summary), endpointHints(newViews.summary)
+	for _, ep := range sortedKeys(oldHints) {
+		if _, ok := newHints[ep]; !ok {
+			d.Removed = append(d.Removed, ep)
+			continue
+		}
+		for _, h := range headerHints {
+			was, is := oldHints[ep][h], newHints[ep][h]
+			switch {
+			case is && !was:
+				changes[ep] = append(changes[ep], Change{Kind: "added", Where: "header " + h, Breaking: true})
+			case was && !is:
+				changes[ep] = append(changes[ep], Change{Kind: "removed", Where: "header " + h})
+			}
+		}
+	}
+	for _, ep := range sortedKeys(newHints) {
+		if _, ok := oldHints[ep]; !ok {
+			d.Added = append(d.Added, ep)
+		}
+	}
+
+	oldSchemas, newSchemas := schemasByEndpoint(oldViews.schemas), schemasByEndpoint(newViews.schemas)
+	for ep, o := range oldSchemas {
+		n, ok := newSchemas[ep]
+		if !ok {
+			continue
+		}
+		changes[ep] = append(changes[ep], diffBody("request", o.Request, n.Request)...)
+		changes[ep] = append(changes[ep], diffBody("response", o.Response, n.Response)...)
+	}
+	for _, ep := range sortedKeys(changes) {
+		if len(changes[ep]) > 0 {
+			d.Changed = append(d.Changed, EndpointDiff{Endpoint: ep, Changes: changes[ep]})
+		}
+	}
+
+	d.GraphQL = diffGraphQL(oldViews.graphQL.Ops, newViews.graphQL.Ops)
+	return d
+}
+
+func endpointKey(method, host, path, op string) string {
+	s := method + " " + host + path
+	if op != "" {
+		s += " (" + op + ")"
+	}
+	return s
+}
+
+func endpointHints(sum Summary) map[string]map[string]bool {
+	out := map[string]map[string]bool{}
+	for _, ec := range sum.Endpoints {
+		ep := endpointKey(ec.Method, ec.Host, templatePath(ec.Path), "")
+		if out[ep] == nil {
+			out[ep] = map[string]bool{}
+		}
+		for _, h := range ec.Hints {
+			out[ep][h] = true
+		}
+	}
+	return out
+}
+
+func schemasByEndpoint(eps []EndpointSchema) map[string]EndpointSchema {
+	out := make(map[string]EndpointSchema, len(eps))
+	for _, ep := range eps {
+		out[endpointKey(ep.Method, ep.Host, ep.Path, ep.Operation)] = ep
+	}
+	return out
+}
+
+// field is a flattened schema position.
+type field struct {
+	types    string // e.g. "string", "integer|null"
+	format   string
+	optional bool
+}
+
+func (f field) String() string {
+	s := f.types
+	if f.format != "" {
+		s += " (" + f.format + ")"
+	}
+	if f.optional {
+		s = "optional " + s
+	}
+	return s
+}
+
+// diffBody compares one body. Changes below a field that was added or removed as
+// a whole are not listed separately.
+func diffBody(body string, o, n *Schema) []Change {
+	oldFields, newFields := map[string]field{}, map[string]field{}
+	flattenSchema(body, o, false, oldFields)
+	flattenSchema(body, n, false, newFields)
+	request := body == "request"
+
+	paths := sortedKeys(oldFields)
+	for p := range newFields {
+		if _, ok := oldFields[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	var out []Change
+	var gone []string // added or removed subtrees
+	for _, p := range paths {
+		if under(p, gone) {
+			continue
+		}
+		of, inOld := oldFields[p]
+		nf, inNew := newFields[p]
+		switch {
+		case !inNew:
+			gone = append(gone, p)
+			out = append(out, Change{Kind: "removed", Where: p, Old: of.String(), Breaking: !request})
+		case !inOld:
+			gone = append(gone, p)
+			out = append(out, Change{Kind: "added", Where: p, New: nf.String(), Breaking: request && !nf.optional})
+		case of != nf:
+			c := Change{Kind: "changed", Where: p, Old: of.String(), New: nf.String()}
+			switch {
+			case of.types != nf.types || of.format != nf.format:
+				c.Breaking = true
+			case request:
+				c.Breaking = of.optional && !nf.optional
+			default:
+				c.Breaking = !of.optional && nf.optional
+			}
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func flattenSchema(path string, s *Schema, optional bool, out map[string]field) {
+	if s == nil {
+		return
+	}
+	out[path] = field{types: strings.Join(s.Type, "|"), format: s.Format, optional: optional}
+	required := map[string]bool{}
+	for _, k := range s.Required {
+		required[k] = true
+	}
+	for k, p := range s.Properties {
+		flattenSchema(path+"."+k, p, !required[k], out)
+	}
+	if s.Items != nil {
+		flattenSchema(path+"[]", s.Items, false, out)
+	}
+}
+
+func under(p string, roots []string) bool {
+	for _, r := range roots {
+		if strings.HasPrefix(p, r+".") || strings.HasPrefix(p, r+"[]") {
+			return true
+		}
+	}
+	return false
+}
+
+func diffGraphQL(oldOps, newOps []GraphQLOp) []Change {
+	label := func(op GraphQLOp) string { return op.OperationName + " (" + op.Path + ")" }
+	oldByName, newByName := map[string]GraphQLOp{}, map[string]GraphQLOp{}
+	for _, op := range oldOps {
+		oldByName[label(op)] = op
+	}
+	for _, op := range newOps {
+		newByName[label(op)] = op
+	}
+
+	var out []Change
+	for _, name := range sortedKeys(oldByName) {
+		n, ok := newByName[name]
+		if !ok {
+			out = append(out, Change{Kind: "removed", Where: "operation " + name, Breaking: true})
+			continue
+		}
+		o := oldByName[name]
+		was, is := toSet(o.VariableKeys), toSet(n.VariableKeys)
+		for _, k := range n.VariableKeys {
+			if !was[k] {
+				out = append(out, Change{Kind: "added", Where: "operation " + name + " variable " + k, Breaking: true})
+			}
+		}
+		for _, k := range o.VariableKeys {
+			if !is[k] {
+				out = append(out, Change{Kind: "removed", Where: "operation " + name + " variable " + k})
+			}
+		}
+	}
+	for _, name := range sortedKeys(newByName) {
+		if _, ok := oldByName[name]; !ok {
+			out = append(out, Change{Kind: "added", Where: "operation " + name})
+		}
+	}
+	return out
+}
+
+func toSet(ss []string) map[string]bool {
+	m := make(map[string]bool, len(ss))
+	for _, s := range ss {
+		m[s] = true
+	}
+	return m
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func FormatDiffText(d Diff) string {
+	if d.Empty() {
+		return "No API differences found.\n"
+	}
+	var b strings.Builder
+	section := func(title string) {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(title + ":\n")
+	}
+	if len(d.Added) > 0 {
+		section("Endpoints added")
+		for _, ep := range d.Added {
+			fmt.Fprintf(&b, "+ %s\n", ep)
+		}
+	}
+	if len(d.Removed) > 0 {
+		section("Endpoints removed")
+		for _, ep := range d.Removed {
+			fmt.Fprintf(&b, "- %s (breaking)\n", ep)
+		}
+	}
+	if len(d.Changed) > 0 {
+		section("Endpoints changed")
+		for _, ep := range d.Changed {
+			fmt.Fprintf(&b, "%s\n", ep.Endpoint)
+			for _, c := range ep.Changes {
+				fmt.Fprintf(&b, "  %s\n", formatChange(c))
+			}
+		}
+	}
+	if len(d.GraphQL) > 0 {
+		section("GraphQL operations")
+		for _, c := range d.GraphQL {
+			fmt.Fprintf(&b, "%s\n", formatChange(c))
+		}
+	}
+	return b.String()
+}
+
+func formatChange(c Change) string {
+	var s string
+	switch c.Kind {
+	case "added":
+		s = "+ " + c.Where
+		if c.New != "" {
+			s += ": " + c.New
+		}
+	case "removed":
+		s = "- " + c.Where
+		if c.Old != "" {
+			s += ": " + c.Old
+		}
+	default:
+		s = fmt.Sprintf("~ %s: %s -> %s", c.Where, c.Old, c.New)
+	}
+	if c.Breaking {
+		s += " (breaking)"
+	}
+	return s
+}
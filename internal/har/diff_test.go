@@ -0,0 +1,131 @@
+package har
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	oldFile := File{Log: Log{Entries: []Entry{
+		schemaEntry("POST", "https://api.pocketcasts.com/up_next/list", 200,
+			`{"version":1}`,
+			`{"serverModified":1,"episodes":[{"uuid":"11111111-2222-3333-4444-555555555555","duration":60,"podcast":{"title":"x"}}]}`),
+		schemaEntry("GET", "https://api.pocketcasts.com/podcast/11111111-2222-3333-4444-555555555555", 200, "", `{"title":"x"}`),
+		schemaEntry("POST", "https://api.pocketcasts.com/up_next/sync", 200, `{}`, `{}`),
+		schemaEntry("POST", "https://play.pocketcasts.com/graphql", 200, `{"operationName":"Login","variables":{"email":"x"}}`, `{}`),
+	}}}
+	newFile := File{Log: Log{Entries: []Entry{
+		schemaEntry("POST", "https://api.pocketcasts.com/up_next/list", 200,
+			`{"version":1,"model":"webplayer"}`,
+			`{"serverModified":1,"episodes":[{"uuid":"11111111-2222-3333-4444-555555555555","duration":"60s","isDeleted":false}]}`),
+		// Another podcast's URL is the same endpoint.
+		schemaEntry("GET", "https://api.pocketcasts.com/podcast/21111111-2222-3333-4444-555555555555", 200, "", `{"title":"y"}`),
+		schemaEntry("GET", "https://api.pocketcasts.com/user/stats", 200, "", `{}`),
+		schemaEntry("POST", "https://play.pocketcasts.com/graphql", 200, `{"operationName":"Login","variables":{"email":"x","otp":"1"}}`, `{}`),
+		schemaEntry("POST", "https://play.pocketcasts.com/graphql", 200, `{"operationName":"Refresh","variables":{}}`, `{}`),
+	}}}
+	newFile.Log.Entries[0].Request.Headers = []Header{{Name: "Authorization", Value: "Bearer x"}}
+
+	d := Compare(oldFile, newFile, DiffOptions{Host: "pocketcasts.com"})
+	if want := []string{"GET api.pocketcasts.com/user/stats"}; !reflect.DeepEqual(d.Added, want) {
+		t.Fatalf("Added=%v", d.Added)
+	}
+	if want := []string{"POST api.pocketcasts.com/up_next/sync"}; !reflect.DeepEqual(d.Removed, want) {
+		t.Fatalf("Removed=%v", d.Removed)
+	}
+	if len(d.Changed) != 2 || d.Changed[0].Endpoint != "POST api.pocketcasts.com/up_next/list" {
+		t.Fatalf("Changed=%+v", d.Changed)
+	}
+	// GraphQL bodies are compared per operation too.
+	if gql := d.Changed[1]; gql.Endpoint != "POST play.pocketcasts.com/graphql (Login)" || gql.Changes[0].Where != "request.variables.otp" {
+		t.Fatalf("Changed[1]=%+v", gql)
+	}
+	want := []Change{
+		{Kind: "added", Where: "header authz", Breaking: true},
+		{Kind: "added", Where: "request.model", New: "string", Breaking: true},
+		{Kind: "changed", Where: "response.episodes[].duration", Old: "integer", New: "string", Breaking: true},
+		{Kind: "added", Where: "response.episodes[].isDeleted", New: "boolean"},
+		{Kind: "removed", Where: "response.episodes[].podcast", Old: "object", Breaking: true},
+	}
+	if got := d.Changed[0].Changes; !reflect.DeepEqual(got, want) {
+		t.Fatalf("changes:\n got %+v\nwant %+v", got, want)
+	}
+	wantGQL := []Change{
+		{Kind: "added", Where: "operation Login (/graphql) variable otp", Breaking: true},
+		{Kind: "added", Where: "operation Refresh (/graphql)"},
+	}
+	if !reflect.DeepEqual(d.GraphQL, wantGQL) {
+		t.Fatalf("GraphQL=%+v", d.GraphQL)
+	}
+	if d.Empty() || !d.Breaking() {
+		t.Fatalf("Empty=%v Breaking=%v", d.Empty(), d.Breaking())
+	}
+
+	text := FormatDiffText(d)
+	for _, line := range []string{
+		"+ GET api.pocketcasts.com/user/stats\n",
+		"- POST api.pocketcasts.com/up_next/sync (breaking)\n",
+		"  ~ response.episodes[].duration: integer -> string (breaking)\n",
+		"  + response.episodes[].isDeleted: boolean\n",
+	} {
+		if !strings.Contains(text, line) {
+			t.Fatalf("missing %q in:\n%s", line, text)
+		}
+	}
+}
+
+func TestCompareSameCapture(t *testing.T) {
+	f := schemaFixture()
+	d := Compare(f, f, DiffOptions{})
+	if !d.Empty() || d.Breaking() {
+		t.Fatalf("diff of identical captures: %+v", d)
+	}
+	if got := FormatDiffText(d); got != "No API differences found.\n" {
+		t.Fatalf("text=%q", got)
+	}
+}
+
+func TestDiffFilesMatchesCompare(t *testing.T) {
+	oldFile := schemaFixture()
+	newFile := schemaFixture()
+	newFile.Log.Entries = newFile.Log.Entries[1:]
+	dir := t.TempDir()
+	oldPath, newPath := filepath.Join(dir, "old.har"), filepath.Join(dir, "new.har")
+	for path, f := range map[string]File{oldPath: oldFile, newPath: newFile} {
+		b, err := json.Marshal(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, b, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d, err := DiffFiles(oldPath, newPath, DiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := Compare(oldFile, newFile, DiffOptions{}); d.Empty() || !reflect.DeepEqual(d, want) {
+		t.Fatalf("DiffFiles=%+v\nCompare=%+v", d, want)
+	}
+}
+
+func TestDiffBodyOptionality(t *testing.T) {
+	req := &Schema{Type: SchemaType{"object"}, Properties: map[string]*Schema{"a": {Type: SchemaType{"string"}}}}
+	reqRequired := &Schema{Type: SchemaType{"object"}, Properties: req.Properties, Required: []string{"a"}}
+
+	// A request field becoming required breaks callers that don't send it; a
+	// response field becoming optional breaks callers that rely on it.
+	if c := diffBody("request", req, reqRequired); len(c) != 1 || !c[0].Breaking {
+		t.Fatalf("request optional->required: %+v", c)
+	}
+	if c := diffBody("request", reqRequired, req); len(c) != 1 || c[0].Breaking {
+		t.Fatalf("request required->optional: %+v", c)
+	}
+	if c := diffBody("response", reqRequired, req); len(c) != 1 || !c[0].Breaking || c[0].New != "optional string" {
+		t.Fatalf("response required->optional: %+v", c)
+	}
+}
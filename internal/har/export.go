@@ -0,0 +1,250 @@
+package har
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// TokenVar is the shell variable exported commands read the access token from.
+const TokenVar = "POCKETCASTS_TOKEN"
+
+type ExportFormat string
+
+const (
+	ExportCurl      ExportFormat = "curl"
+	ExportHTTPie    ExportFormat = "httpie"
+	ExportGoFixture ExportFormat = "go-fixture"
+)
+
+// ExportFormats are the formats ExportOptions.Format accepts; "" means curl.
+var ExportFormats = []ExportFormat{ExportCurl, ExportHTTPie, ExportGoFixture}
+
+type ExportOptions struct {
+	Host   string
+	Path   string // keep entries whose URL path contains this
+	Format ExportFormat
+	// Redact is applied to the whole capture before anything is exported.
+	Redact RedactOptions
+	// Package is the package clause of ExportGoFixture output.
+	Package string
+}
+
+// skipExportHeaders are set by the client (or are HTTP/2 pseudo-headers, which
+// start with ':'). Cookies are dropped: exported requests authenticate with the
+// token instead.
+var skipExportHeaders = map[string]bool{
+	"accept-encoding": true,
+	"connection":      true,
+	"content-length":  true,
+	"cookie":          true,
+	"host":            true,
+}
+
+// ExportFile redacts the capture at path and renders the matching entries. The
+// capture is streamed; only matching entries are kept.
+func ExportFile(path string, opts ExportOptions) ([]byte, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	r := newRedactor(opts.Redact)
+	redact := r.entryFunc(true)
+	var entries []Entry
+	err = streamEntries(in, nil, opts.Redact.maxBody(), func(i int, raw []byte, skipped int64, _ string) ([]byte, error) {
+		var orig Entry
+		if err := json.Unmarshal(raw, &orig); err != nil {
+			return nil, fmt.Errorf("parse HAR: entry %d: %w", i, err)
+		}
+		if !exportMatch(orig, opts) {
+			return nil, nil
+		}
+		redacted, err := redact(i, raw, skipped, "")
+		if err != nil {
+			return nil, err
+		}
+		var e Entry
+		if err := json.Unmarshal(redacted, &e); err != nil {
+			return nil, err
+		}
+		// Remove mode drops the header, so look at the original to see whether
+		// the request was authenticated.
+		e.Request.Headers = exportHeaders(e.Request.Headers, hasHeader(orig.Request.Headers, "authorization"))
+		entries = append(entries, e)
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no matching requests")
+	}
+
+	switch opts.Format {
+	case ExportCurl, "":
+		return []byte(formatShell(entries, curlCommand)), nil
+	case ExportHTTPie:
+		return []byte(formatShell(entries, httpieCommand)), nil
+	default:
+		return formatGoFixtures(entries, opts.Package)
+	}
+}
+
+func (o ExportOptions) validate() error {
+	if o.Format == "" || slices.Contains(ExportFormats, o.Format) {
+		return nil
+	}
+	return fmt.Errorf("invalid format %q (want curl, httpie or go-fixture)", o.Format)
+}
+
+func exportMatch(e Entry, opts ExportOptions) bool {
+	u, err := url.Parse(strings.TrimSpace(e.Request.URL))
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if h := strings.ToLower(strings.TrimSpace(opts.Host)); h != "" && !strings.Contains(strings.ToLower(u.Hostname()), h) {
+		return false
+	}
+	return opts.Path == "" || strings.Contains(u.EscapedPath(), opts.Path)
+}
+
+// exportHeaders drops client-managed headers and puts the token variable in place
+// of the (redacted) Authorization header.
+func exportHeaders(hs []Header, authorized bool) []Header {
+	var out []Header
+	for _, h := range hs {
+		name := strings.ToLower(strings.TrimSpace(h.Name))
+		if strings.HasPrefix(name, ":") || skipExportHeaders[name] || name == "authorization" {
+			continue
+		}
+		out = append(out, h)
+	}
+	if authorized {
+		out = append(out, Header{Name: "Authorization", Value: "Bearer $" + TokenVar})
+	}
+	return out
+}
+
+func formatShell(entries []Entry, command func(Entry) string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Exported by pocketcastsctl har export; set %s first (values are redacted).\n", TokenVar)
+	for _, e := range entries {
+		fmt.Fprintf(&b, "\n# %s %s -> %d\n%s\n", e.Request.Method, e.Request.URL, e.Response.Status, command(e))
+	}
+	return b.String()
+}
+
+func curlCommand(e Entry) string {
+	head := "curl "
+	if m := strings.ToUpper(e.Request.Method); m != "GET" {
+		head += "-X " + m + " "
+	}
+	parts := []string{head + shellQuote(e.Request.URL)}
+	for _, h := range e.Request.Headers {
+		parts = append(parts, "-H "+shellQuote(h.Name+": "+h.Value))
+	}
+	if e.Request.PostData != nil && e.Request.PostData.Text != "" {
+		parts = append(parts, "--data-raw "+shellQuote(e.Request.PostData.Text))
+	}
+	return strings.Join(parts, " \\\n  ")
+}
+
+func httpieCommand(e Entry) string {
+	parts := []string{"http " + strings.ToUpper(e.Request.Method) + " " + shellQuote(e.Request.URL)}
+	for _, h := range e.Request.Headers {
+		parts = append(parts, shellQuote(h.Name+":"+h.Value))
+	}
+	if e.Request.PostData != nil && e.Request.PostData.Text != "" {
+		parts = append(parts, "--raw "+shellQuote(e.Request.PostData.Text))
+	}
+	return strings.Join(parts, " \\\n  ")
+}
+
+// shellQuote single-quotes s, except for the token variable, which is left in
+// double quotes so the shell expands it.
+func shellQuote(s string) string {
+	if strings.Contains(s, "$"+TokenVar) {
+		r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", "$", `\$`)
+		q := r.Replace(s)
+		q = strings.ReplaceAll(q, `\$`+TokenVar, "$"+TokenVar)
+		return `"` + q + `"`
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func formatGoFixtures(entries []Entry, pkg string) ([]byte, error) {
+	if pkg == "" {
+		pkg = "pocketcasts"
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Exported by pocketcastsctl har export; values are redacted.\n"+
+		"// Serve them with httptest.NewServer(har.FixtureHandler(harFixtures)).\n\npackage %s\n\n", pkg)
+	b.WriteString("import \"pocketcastsctl/internal/har\"\n\n")
+	b.WriteString("var harFixtures = []har.Fixture{\n")
+	for _, e := range entries {
+		fx, err := fixtureFromEntry(e)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString("{\n")
+		fmt.Fprintf(&b, "Method: %q,\nPath: %q,\n", fx.Method, fx.Path)
+		if fx.RequestBody != "" {
+			fmt.Fprintf(&b, "RequestBody: %s,\n", goString(fx.RequestBody))
+		}
+		fmt.Fprintf(&b, "Status: %d,\n", fx.Status)
+		if fx.ContentType != "" {
+			fmt.Fprintf(&b, "ContentType: %q,\n", fx.ContentType)
+		}
+		if fx.ResponseBody != "" {
+			fmt.Fprintf(&b, "ResponseBody: %s,\n", goString(fx.ResponseBody))
+		}
+		b.WriteString("},\n")
+	}
+	b.WriteString("}\n")
+	out, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated Go: %w", err)
+	}
+	return out, nil
+}
+
+// goString prefers a raw string literal, which keeps JSON bodies readable.
+func goString(s string) string {
+	if strconv.CanBackquote(s) {
+		return "`" + s + "`"
+	}
+	return strconv.Quote(s)
+}
+
+func fixtureFromEntry(e Entry) (Fixture, error) {
+	u, err := url.Parse(e.Request.URL)
+	if err != nil {
+		return Fixture{}, err
+	}
+	body, err := e.Response.Content.DecodedText()
+	if err != nil {
+		return Fixture{}, fmt.Errorf("%s %s: %w", e.Request.Method, e.Request.URL, err)
+	}
+	fx := Fixture{
+		Method:       strings.ToUpper(e.Request.Method),
+		Path:         u.EscapedPath(),
+		Status:       e.Response.Status,
+		ContentType:  e.Response.Content.MimeType,
+		ResponseBody: body,
+	}
+	if e.Request.PostData != nil {
+		fx.RequestBody = e.Request.PostData.Text
+	}
+	return fx, nil
+}
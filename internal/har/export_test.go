@@ -0,0 +1,127 @@
+package har
+
+import (
+	"go/parser"
+	"go/token"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExportCurl(t *testing.T) {
+	out, err := ExportFile("testdata/login.har", ExportOptions{Format: ExportCurl, Path: "/user/", Redact: DefaultRedactOptions()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(out)
+	for _, want := range []string{
+		"curl -X POST 'https://api.pocketcasts.com/user/login' \\\n  -H 'Content-Type: application/json' \\\n  --data-raw '{\"email\":\"<redacted>\"",
+		"curl 'https://api.pocketcasts.com/user/profile' \\\n  -H \"Authorization: Bearer $POCKETCASTS_TOKEN\"",
+	} {
+		if !strings.Contains(s, want) {
+			t.Fatalf("missing %q in:\n%s", want, s)
+		}
+	}
+	for _, secret := range []string{"hunter2", "listener@example.com", "login-token"} {
+		if strings.Contains(s, secret) {
+			t.Fatalf("export leaks %q:\n%s", secret, s)
+		}
+	}
+	if strings.Contains(s, "play.pocketcasts.com") {
+		t.Fatalf("--path filter ignored:\n%s", s)
+	}
+}
+
+func TestExportHTTPieRemoveMode(t *testing.T) {
+	opts := DefaultRedactOptions()
+	opts.Mode = ReplaceRemove
+	out, err := ExportFile("testdata/login.har", ExportOptions{Format: ExportHTTPie, Path: "/user/profile", Redact: opts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The removed Authorization header still becomes the token variable.
+	want := "http GET 'https://api.pocketcasts.com/user/profile' \\\n  \"Authorization:Bearer $POCKETCASTS_TOKEN\"\n"
+	if !strings.Contains(string(out), want) {
+		t.Fatalf("missing %q in:\n%s", want, out)
+	}
+}
+
+func TestExportNoMatch(t *testing.T) {
+	if _, err := ExportFile("testdata/login.har", ExportOptions{Path: "/nope", Redact: DefaultRedactOptions()}); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestExportInvalidFormat(t *testing.T) {
+	// The format is checked before the capture is opened.
+	_, err := ExportFile("testdata/missing.har", ExportOptions{Format: "wget"})
+	if err == nil || !strings.Contains(err.Error(), `invalid format "wget"`) {
+		t.Fatalf("err=%v", err)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	for in, want := range map[string]string{
+		`{"a":"it's"}`: `'{"a":"it'\''s"}'`,
+		"Authorization: Bearer $POCKETCASTS_TOKEN": `"Authorization: Bearer $POCKETCASTS_TOKEN"`,
+		`X: "$HOME" $POCKETCASTS_TOKEN`:            `"X: \"\$HOME\" $POCKETCASTS_TOKEN"`,
+	} {
+		if got := shellQuote(in); got != want {
+			t.Errorf("shellQuote(%q)=%s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestExportGoFixture(t *testing.T) {
+	out, err := ExportFile("testdata/login.har", ExportOptions{Format: ExportGoFixture, Host: "api.pocketcasts.com", Package: "fake", Redact: DefaultRedactOptions()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "fixtures.go", out, 0); err != nil {
+		t.Fatalf("generated Go does not parse: %v\n%s", err, out)
+	}
+	s := string(out)
+	for _, want := range []string{
+		"package fake",
+		`Path:         "/user/profile",`,
+		// The base64 response is decoded (and its secrets redacted).
+		`ResponseBody: ` + "`" + `{"email":"<redacted>"`,
+	} {
+		if !strings.Contains(s, want) {
+			t.Fatalf("missing %q in:\n%s", want, s)
+		}
+	}
+}
+
+func TestFixtureHandler(t *testing.T) {
+	srv := httptest.NewServer(FixtureHandler([]Fixture{
+		{Method: "POST", Path: "/up_next/list", Status: 200, ContentType: "application/json", ResponseBody: `{"n":1}`},
+		{Method: "POST", Path: "/up_next/list", Status: 200, ContentType: "application/json", ResponseBody: `{"n":2}`},
+		{Method: "GET", Path: "/user/profile", Status: 401},
+	}))
+	defer srv.Close()
+
+	get := func(method, path string) (int, string) {
+		req, _ := http.NewRequest(method, srv.URL+path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(b)
+	}
+	for i, want := range []string{`{"n":1}`, `{"n":2}`, `{"n":2}`} {
+		if code, body := get("POST", "/up_next/list"); code != 200 || body != want {
+			t.Fatalf("call %d: %d %s, want %s", i, code, body, want)
+		}
+	}
+	if code, _ := get("GET", "/user/profile"); code != 401 {
+		t.Fatalf("profile status=%d", code)
+	}
+	if code, _ := get("GET", "/up_next/list"); code != 404 {
+		t.Fatalf("unmatched status=%d", code)
+	}
+}
@@ -0,0 +1,55 @@
+package har
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Fixture is one recorded request and its response, as written by
+// `har export --format go-fixture`.
+type Fixture struct {
+	Method       string
+	Path         string
+	RequestBody  string
+	Status       int
+	ContentType  string
+	ResponseBody string
+}
+
+// FixtureHandler serves fixtures as a fake API, e.g. behind httptest.NewServer.
+// Requests match on method and path; fixtures sharing both are replayed in order
+// and the last one repeats. Anything else gets 404.
+func FixtureHandler(fixtures []Fixture) http.Handler {
+	type key struct{ method, path string }
+	byKey := map[key][]Fixture{}
+	for _, fx := range fixtures {
+		k := key{fx.Method, fx.Path}
+		byKey[k] = append(byKey[k], fx)
+	}
+	var mu sync.Mutex
+	served := map[key]int{}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		k := key{r.Method, r.URL.EscapedPath()}
+		mu.Lock()
+		list := byKey[k]
+		n := served[k]
+		served[k]++
+		mu.Unlock()
+		if len(list) == 0 {
+			http.Error(w, fmt.Sprintf("no fixture for %s %s", r.Method, k.path), http.StatusNotFound)
+			return
+		}
+		fx := list[min(n, len(list)-1)]
+		if fx.ContentType != "" {
+			w.Header().Set("Content-Type", fx.ContentType)
+		}
+		status := fx.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(fx.ResponseBody))
+	})
+}
@@ -31,73 +31,102 @@ type GraphQLHit struct {
 	Path string `json:"path"`
 }
 
+// GraphQLOpsFile streams the capture; response bodies are never loaded.
 func GraphQLOpsFile(path string, opts GraphQLOpsOptions) (GraphQLOpsSummary, error) {
-	f, err := ReadFile(path)
-	if err != nil {
+	c := newGraphQLCollector(opts)
+	if err := eachEntryFile(path, 0, func(_ int, e Entry) error {
+		c.add(e)
+		return nil
+	}); err != nil {
 		return GraphQLOpsSummary{}, err
 	}
-	return GraphQLOps(f, opts), nil
+	return c.summary(), nil
 }
 
 func GraphQLOps(f File, opts GraphQLOpsOptions) GraphQLOpsSummary {
-	endpoints := Summarize(f, SummarizeOptions{Host: opts.Host})
+	c := newGraphQLCollector(opts)
+	for _, e := range f.Log.Entries {
+		c.add(e)
+	}
+	return c.summary()
+}
 
-	type key struct {
-		op   string
-		path string
+type graphQLKey struct {
+	op   string
+	path string
+}
+
+// graphQLCollector accumulates a GraphQLOpsSummary one entry at a time.
+type graphQLCollector struct {
+	opts      GraphQLOpsOptions
+	endpoints *summarizer
+	counts    map[graphQLKey]*GraphQLOp
+	unknown   map[string]bool
+}
+
+func newGraphQLCollector(opts GraphQLOpsOptions) *graphQLCollector {
+	return &graphQLCollector{
+		opts:      opts,
+		endpoints: newSummarizer(SummarizeOptions{Host: opts.Host}),
+		counts:    map[graphQLKey]*GraphQLOp{},
+		unknown:   map[string]bool{},
 	}
-	counts := map[key]*GraphQLOp{}
-	unknown := map[string]bool{}
+}
 
-	for _, e := range f.Log.Entries {
-		raw := strings.TrimSpace(e.Request.URL)
-		if raw == "" {
-			continue
-		}
+func (c *graphQLCollector) add(e Entry) {
+	c.endpoints.add(e)
 
-		if opts.Host != "" && !strings.Contains(strings.ToLower(raw), strings.ToLower(opts.Host)) {
-			continue
-		}
+	raw := strings.TrimSpace(e.Request.URL)
+	if raw == "" {
+		return
+	}
 
-		if e.Request.PostData == nil {
-			continue
-		}
-		if !strings.Contains(strings.ToLower(e.Request.PostData.MimeType), "json") {
-			continue
-		}
+	if c.opts.Host != "" && !strings.Contains(strings.ToLower(raw), strings.ToLower(c.opts.Host)) {
+		return
+	}
 
-		var body map[string]any
-		if err := json.Unmarshal([]byte(e.Request.PostData.Text), &body); err != nil {
-			continue
-		}
+	if e.Request.PostData == nil {
+		return
+	}
+	if !strings.Contains(strings.ToLower(e.Request.PostData.MimeType), "json") {
+		return
+	}
 
-		opName, _ := body["operationName"].(string)
-		if opName == "" {
-			unknown[raw] = true
-			continue
-		}
+	var body map[string]any
+	if err := json.Unmarshal([]byte(e.Request.PostData.Text), &body); err != nil {
+		return
+	}
 
-		varKeys := extractTopLevelKeys(body["variables"])
-		u, err := parseURL(raw)
-		if err != nil {
-			continue
-		}
-		k := key{op: opName, path: u.EscapedPath()}
-		item := counts[k]
-		if item == nil {
-			item = &GraphQLOp{
-				OperationName: opName,
-				Path:          k.path,
-				VariableKeys:  varKeys,
-			}
-			counts[k] = item
+	opName, _ := body["operationName"].(string)
+	if opName == "" {
+		c.unknown[raw] = true
+		return
+	}
+
+	varKeys := extractTopLevelKeys(body["variables"])
+	u, err := parseURL(raw)
+	if err != nil {
+		return
+	}
+	k := graphQLKey{op: opName, path: u.EscapedPath()}
+	item := c.counts[k]
+	if item == nil {
+		item = &GraphQLOp{
+			OperationName: opName,
+			Path:          k.path,
+			VariableKeys:  varKeys,
 		}
-		item.Count++
-		item.VariableKeys = unionKeys(item.VariableKeys, varKeys)
+		c.counts[k] = item
 	}
+	item.Count++
+	item.VariableKeys = unionKeys(item.VariableKeys, varKeys)
+}
+
+func (c *graphQLCollector) summary() GraphQLOpsSummary {
+	endpoints := c.endpoints.summary()
 
-	out := make([]GraphQLOp, 0, len(counts))
-	for _, v := range counts {
+	out := make([]GraphQLOp, 0, len(c.counts))
+	for _, v := range c.counts {
 		sort.Strings(v.VariableKeys)
 		out = append(out, *v)
 	}
@@ -109,7 +138,7 @@ func GraphQLOps(f File, opts GraphQLOpsOptions) GraphQLOpsSummary {
 	})
 
 	var unknownOut []GraphQLHit
-	for raw := range unknown {
+	for raw := range c.unknown {
 		u, err := parseURL(raw)
 		if err != nil {
 			continue
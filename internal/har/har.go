@@ -1,12 +1,14 @@
 package har
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/url"
-	"os"
 	"sort"
 	"strings"
+	"time"
 )
 
 type File struct {
@@ -18,8 +20,15 @@ type Log struct {
 }
 
 type Entry struct {
-	Request  Request  `json:"request"`
-	Response Response `json:"response"`
+	StartedDateTime string   `json:"startedDateTime,omitempty"`
+	Request         Request  `json:"request"`
+	Response        Response `json:"response"`
+	Timings         Timings  `json:"timings"`
+}
+
+// Timings are in milliseconds; -1 means the phase doesn't apply.
+type Timings struct {
+	Wait float64 `json:"wait"`
 }
 
 type Request struct {
@@ -29,6 +38,7 @@ type Request struct {
 	Cookies  []Cookie     `json:"cookies"`
 	PostData *PostData    `json:"postData,omitempty"`
 	Query    []QueryParam `json:"queryString,omitempty"`
+	BodySize int64        `json:"bodySize"`
 }
 
 type Header struct {
@@ -52,25 +62,54 @@ type PostData struct {
 }
 
 type Response struct {
-	Status  int      `json:"status"`
-	Headers []Header `json:"headers"`
-	Body    []byte   `json:"-"`
+	Status   int      `json:"status"`
+	Headers  []Header `json:"headers"`
+	Content  Content  `json:"content"`
+	BodySize int64    `json:"bodySize"`
+	Body     []byte   `json:"-"`
 }
 
-func ReadFile(path string) (File, error) {
-	b, err := os.ReadFile(path)
+// Content is a response body. Encoding is "base64" for bodies the browser could
+// not export as text. Comment notes a body skipped for size; see DefaultMaxBodySize.
+type Content struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+}
+
+// DecodedText returns the body, decoding base64 content.
+func (c Content) DecodedText() (string, error) {
+	if !strings.EqualFold(c.Encoding, "base64") {
+		return c.Text, nil
+	}
+	b, err := base64.StdEncoding.DecodeString(c.Text)
 	if err != nil {
-		return File{}, err
+		return "", fmt.Errorf("decode base64 content: %w", err)
 	}
+	return string(b), nil
+}
+
+// ReadFile loads every entry of a capture, skipping response bodies over
+// DefaultMaxBodySize. Commands that only need a pass over the entries stream them
+// with EachEntry instead.
+func ReadFile(path string) (File, error) {
 	var f File
-	if err := json.Unmarshal(b, &f); err != nil {
-		return File{}, fmt.Errorf("parse HAR: %w", err)
+	err := eachEntryFile(path, DefaultMaxBodySize, func(_ int, e Entry) error {
+		f.Log.Entries = append(f.Log.Entries, e)
+		return nil
+	})
+	if err != nil {
+		return File{}, err
 	}
 	return f, nil
 }
 
 type SummarizeOptions struct {
-	Host string
+	Host     string
+	Sort     string // one of SummarySorts; "" sorts by path
+	MinCount int    // leave out endpoints seen fewer times
 }
 
 type Summary struct {
@@ -78,101 +117,295 @@ type Summary struct {
 	Total      int             `json:"total"`
 	Matched    int             `json:"matched"`
 	Endpoints  []EndpointCount `json:"endpoints"`
+	Hidden     int             `json:"hidden,omitempty"` // endpoints below MinCount
 }
 
+// EndpointCount describes the requests to one method, host and path. Sizes are
+// averages over the entries that recorded one; timings are the server wait
+// (timings.wait), the time to first byte.
 type EndpointCount struct {
 	Method string   `json:"method"`
 	Host   string   `json:"host"`
 	Path   string   `json:"path"`
 	Count  int      `json:"count"`
 	Hints  []string `json:"hints,omitempty"`
+
+	// Statuses counts responses by status code; 0 is a request that never
+	// completed (blocked or cancelled).
+	Statuses      map[int]int `json:"statuses,omitempty"`
+	WaitP50Ms     float64     `json:"waitP50Ms,omitempty"`
+	WaitP95Ms     float64     `json:"waitP95Ms,omitempty"`
+	RequestBytes  int64       `json:"requestBytes,omitempty"`
+	ResponseBytes int64       `json:"responseBytes,omitempty"`
+	ContentTypes  []string    `json:"contentTypes,omitempty"`
+	FirstSeen     string      `json:"firstSeen,omitempty"`
+	LastSeen      string      `json:"lastSeen,omitempty"`
+	// NoAuthzOK counts 2xx responses to requests sent without an Authorization
+	// header, i.e. ones the API accepted on cookies alone (see the cookie hint) or
+	// with no credentials at all.
+	NoAuthzOK int `json:"noAuthzOK,omitempty"`
 }
 
+// SummarySorts are the orders SummarizeOptions.Sort accepts: by host and path
+// (the default), by count, by p95 wait or by response size (largest first), or by
+// first seen.
+var SummarySorts = []string{"path", "count", "wait", "size", "seen"}
+
+// SummarizeFile streams the capture; response bodies are never loaded.
 func SummarizeFile(path string, opts SummarizeOptions) (Summary, error) {
-	f, err := ReadFile(path)
-	if err != nil {
+	if err := opts.validate(); err != nil {
+		return Summary{}, err
+	}
+	sm := newSummarizer(opts)
+	if err := eachEntryFile(path, 0, func(_ int, e Entry) error {
+		sm.add(e)
+		return nil
+	}); err != nil {
 		return Summary{}, err
 	}
-	return Summarize(f, opts), nil
+	return sm.summary(), nil
 }
 
+// Summarize counts the requests in f. An unknown opts.Sort sorts by path.
 func Summarize(f File, opts SummarizeOptions) Summary {
-	hostNeedle := strings.TrimSpace(opts.Host)
-	hostNeedleLower := strings.ToLower(hostNeedle)
+	sm := newSummarizer(opts)
+	for _, e := range f.Log.Entries {
+		sm.add(e)
+	}
+	return sm.summary()
+}
 
-	type key struct {
-		method string
-		host   string
-		path   string
+func (o SummarizeOptions) validate() error {
+	if o.Sort == "" {
+		return nil
 	}
-	counts := map[key]*EndpointCount{}
+	for _, s := range SummarySorts {
+		if o.Sort == s {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid sort %q (want %s)", o.Sort, strings.Join(SummarySorts, ", "))
+}
 
-	total := len(f.Log.Entries)
-	matched := 0
+type summaryKey struct {
+	method string
+	host   string
+	path   string
+}
 
-	for _, e := range f.Log.Entries {
-		raw := strings.TrimSpace(e.Request.URL)
-		if raw == "" {
-			continue
-		}
-		u, err := url.Parse(raw)
-		if err != nil {
-			continue
-		}
+// summarizer accumulates a Summary one entry at a time.
+type summarizer struct {
+	opts    SummarizeOptions
+	counts  map[summaryKey]*endpointStats
+	total   int
+	matched int
+}
 
-		h := u.Hostname()
-		if hostNeedleLower != "" && !strings.Contains(strings.ToLower(h), hostNeedleLower) {
-			continue
-		}
-		matched++
+// endpointStats holds what an EndpointCount is computed from.
+type endpointStats struct {
+	ec           *EndpointCount
+	waits        []float64
+	reqBytes     int64
+	reqSized     int
+	respBytes    int64
+	respSized    int
+	contentTypes map[string]bool
+	first, last  time.Time
+}
 
-		p := u.EscapedPath()
-		k := key{method: strings.ToUpper(strings.TrimSpace(e.Request.Method)), host: h, path: p}
-		ec := counts[k]
-		if ec == nil {
-			ec = &EndpointCount{Method: k.method, Host: k.host, Path: k.path}
-			counts[k] = ec
-		}
-		ec.Count++
+func newSummarizer(opts SummarizeOptions) *summarizer {
+	opts.Host = strings.TrimSpace(opts.Host)
+	return &summarizer{opts: opts, counts: map[summaryKey]*endpointStats{}}
+}
 
-		if hasHeader(e.Request.Headers, "authorization") {
-			ec.Hints = addHint(ec.Hints, "authz")
+func (sm *summarizer) add(e Entry) {
+	sm.total++
+	raw := strings.TrimSpace(e.Request.URL)
+	if raw == "" {
+		return
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return
+	}
+
+	h := u.Hostname()
+	if sm.opts.Host != "" && !strings.Contains(strings.ToLower(h), strings.ToLower(sm.opts.Host)) {
+		return
+	}
+	sm.matched++
+
+	p := u.EscapedPath()
+	k := summaryKey{method: strings.ToUpper(strings.TrimSpace(e.Request.Method)), host: h, path: p}
+	st := sm.counts[k]
+	if st == nil {
+		st = &endpointStats{
+			ec:           &EndpointCount{Method: k.method, Host: k.host, Path: k.path, Statuses: map[int]int{}},
+			contentTypes: map[string]bool{},
 		}
-		if hasHeader(e.Request.Headers, "cookie") || len(e.Request.Cookies) > 0 {
-			ec.Hints = addHint(ec.Hints, "cookie")
+		sm.counts[k] = st
+	}
+	ec := st.ec
+	ec.Count++
+
+	authz := hasHeader(e.Request.Headers, "authorization")
+	if authz {
+		ec.Hints = addHint(ec.Hints, "authz")
+	}
+	if hasHeader(e.Request.Headers, "cookie") || len(e.Request.Cookies) > 0 {
+		ec.Hints = addHint(ec.Hints, "cookie")
+	}
+	if hasHeader(e.Request.Headers, "x-csrf-token") || hasHeader(e.Request.Headers, "x-xsrf-token") {
+		ec.Hints = addHint(ec.Hints, "csrf")
+	}
+	if e.Request.PostData != nil && strings.Contains(strings.ToLower(e.Request.PostData.MimeType), "json") {
+		ec.Hints = addHint(ec.Hints, "json")
+		if looksLikeGraphQL(e.Request.PostData.Text) {
+			ec.Hints = addHint(ec.Hints, "graphql")
 		}
-		if hasHeader(e.Request.Headers, "x-csrf-token") || hasHeader(e.Request.Headers, "x-xsrf-token") {
-			ec.Hints = addHint(ec.Hints, "csrf")
+	}
+
+	status := e.Response.Status
+	ec.Statuses[status]++
+	if status >= 200 && status < 300 && !authz {
+		ec.NoAuthzOK++
+	}
+	// HAR uses -1 for timings that don't apply.
+	if e.Timings.Wait >= 0 && status != 0 {
+		st.waits = append(st.waits, e.Timings.Wait)
+	}
+	if n := requestSize(e.Request); n >= 0 {
+		st.reqBytes += n
+		st.reqSized++
+	}
+	if n := responseSize(e.Response); n >= 0 {
+		st.respBytes += n
+		st.respSized++
+	}
+	if mt := mediaType(e.Response.Content.MimeType); mt != "" {
+		st.contentTypes[mt] = true
+	}
+	if t, err := time.Parse(time.RFC3339Nano, e.StartedDateTime); err == nil {
+		if st.first.IsZero() || t.Before(st.first) {
+			st.first = t
 		}
-		if e.Request.PostData != nil && strings.Contains(strings.ToLower(e.Request.PostData.MimeType), "json") {
-			ec.Hints = addHint(ec.Hints, "json")
-			if looksLikeGraphQL(e.Request.PostData.Text) {
-				ec.Hints = addHint(ec.Hints, "graphql")
-			}
+		if t.After(st.last) {
+			st.last = t
 		}
 	}
+}
 
-	out := make([]EndpointCount, 0, len(counts))
-	for _, v := range counts {
-		sort.Strings(v.Hints)
-		out = append(out, *v)
+// requestSize is the request body size, or -1 if unknown.
+func requestSize(r Request) int64 {
+	if r.BodySize > 0 {
+		return r.BodySize
 	}
-	sort.Slice(out, func(i, j int) bool {
-		if out[i].Host != out[j].Host {
-			return out[i].Host < out[j].Host
+	if r.PostData != nil {
+		return int64(len(r.PostData.Text))
+	}
+	if r.BodySize == 0 {
+		return 0
+	}
+	return -1
+}
+
+// responseSize is the decoded response body size, or -1 if unknown. Bodies
+// skipped while reading still have their recorded content.size.
+func responseSize(r Response) int64 {
+	switch {
+	case r.Content.Size > 0:
+		return r.Content.Size
+	case r.Content.Text != "":
+		if text, err := r.Content.DecodedText(); err == nil {
+			return int64(len(text))
 		}
-		if out[i].Path != out[j].Path {
-			return out[i].Path < out[j].Path
+		return -1
+	case r.BodySize >= 0 && r.Status != 0:
+		return r.BodySize
+	}
+	return -1
+}
+
+func mediaType(mimeType string) string {
+	mt, _, _ := strings.Cut(mimeType, ";")
+	return strings.ToLower(strings.TrimSpace(mt))
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	return sorted[max(i, 0)]
+}
+
+func (st *endpointStats) endpoint() EndpointCount {
+	ec := *st.ec
+	sort.Strings(ec.Hints)
+	sort.Float64s(st.waits)
+	ec.WaitP50Ms = percentile(st.waits, 50)
+	ec.WaitP95Ms = percentile(st.waits, 95)
+	if st.reqSized > 0 {
+		ec.RequestBytes = st.reqBytes / int64(st.reqSized)
+	}
+	if st.respSized > 0 {
+		ec.ResponseBytes = st.respBytes / int64(st.respSized)
+	}
+	ec.ContentTypes = sortedKeys(st.contentTypes)
+	if !st.first.IsZero() {
+		ec.FirstSeen = st.first.UTC().Format(time.RFC3339)
+		ec.LastSeen = st.last.UTC().Format(time.RFC3339)
+	}
+	return ec
+}
+
+func (sm *summarizer) summary() Summary {
+	s := Summary{
+		HostFilter: sm.opts.Host,
+		Total:      sm.total,
+		Matched:    sm.matched,
+		Endpoints:  make([]EndpointCount, 0, len(sm.counts)),
+	}
+	for _, st := range sm.counts {
+		if st.ec.Count < sm.opts.MinCount {
+			s.Hidden++
+			continue
 		}
-		return out[i].Method < out[j].Method
-	})
+		s.Endpoints = append(s.Endpoints, st.endpoint())
+	}
+	sortEndpoints(s.Endpoints, sm.opts.Sort)
+	return s
+}
 
-	return Summary{
-		HostFilter: hostNeedle,
-		Total:      total,
-		Matched:    matched,
-		Endpoints:  out,
+func sortEndpoints(eps []EndpointCount, by string) {
+	byPath := func(a, b EndpointCount) bool {
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Method < b.Method
 	}
+	sort.Slice(eps, func(i, j int) bool {
+		a, b := eps[i], eps[j]
+		switch {
+		case by == "count" && a.Count != b.Count:
+			return a.Count > b.Count
+		case by == "wait" && a.WaitP95Ms != b.WaitP95Ms:
+			return a.WaitP95Ms > b.WaitP95Ms
+		case by == "size" && a.ResponseBytes != b.ResponseBytes:
+			return a.ResponseBytes > b.ResponseBytes
+		case by == "seen" && a.FirstSeen != b.FirstSeen:
+			// Endpoints without timestamps go last.
+			if a.FirstSeen == "" || b.FirstSeen == "" {
+				return b.FirstSeen == ""
+			}
+			return a.FirstSeen < b.FirstSeen
+		}
+		return byPath(a, b)
+	})
 }
 
 func FormatSummaryText(s Summary) string {
@@ -183,20 +416,73 @@ func FormatSummaryText(s Summary) string {
 	} else {
 		fmt.Fprintf(&b, "Matched: %d\n", s.Matched)
 	}
-	if len(s.Endpoints) == 0 {
-		return b.String()
+	if len(s.Endpoints) > 0 {
+		b.WriteString("\nEndpoints:\n")
 	}
-	b.WriteString("\nEndpoints:\n")
 	for _, e := range s.Endpoints {
 		hints := ""
 		if len(e.Hints) > 0 {
 			hints = " [" + strings.Join(e.Hints, ",") + "]"
 		}
 		fmt.Fprintf(&b, "- %s %s %s%s (%d)\n", e.Host, e.Method, e.Path, hints, e.Count)
+		if details := endpointDetails(e); details != "" {
+			fmt.Fprintf(&b, "    %s\n", details)
+		}
+		if e.FirstSeen != "" {
+			if e.FirstSeen == e.LastSeen {
+				fmt.Fprintf(&b, "    seen %s\n", e.FirstSeen)
+			} else {
+				fmt.Fprintf(&b, "    seen %s .. %s\n", e.FirstSeen, e.LastSeen)
+			}
+		}
+		if e.NoAuthzOK > 0 {
+			fmt.Fprintf(&b, "    %d of %d succeeded without Authorization\n", e.NoAuthzOK, e.Count)
+		}
+	}
+	if s.Hidden > 0 {
+		fmt.Fprintf(&b, "\n%s below the minimum count not shown.\n", plural(s.Hidden, "endpoint"))
 	}
 	return b.String()
 }
 
+// endpointDetails renders statuses, wait times, sizes and content types on one
+// line, e.g. "200x3 401x1 | wait p50 120ms p95 310ms | req 42 B, resp 1.2 kB | application/json".
+func endpointDetails(e EndpointCount) string {
+	var parts []string
+	if len(e.Statuses) > 0 {
+		codes := make([]int, 0, len(e.Statuses))
+		for code := range e.Statuses {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		var ss []string
+		for _, code := range codes {
+			ss = append(ss, fmt.Sprintf("%dx%d", code, e.Statuses[code]))
+		}
+		parts = append(parts, strings.Join(ss, " "))
+	}
+	if e.WaitP95Ms > 0 {
+		parts = append(parts, fmt.Sprintf("wait p50 %.0fms p95 %.0fms", e.WaitP50Ms, e.WaitP95Ms))
+	}
+	if e.RequestBytes > 0 || e.ResponseBytes > 0 {
+		parts = append(parts, fmt.Sprintf("req %s, resp %s", formatBytes(e.RequestBytes), formatBytes(e.ResponseBytes)))
+	}
+	if len(e.ContentTypes) > 0 {
+		parts = append(parts, strings.Join(e.ContentTypes, ", "))
+	}
+	return strings.Join(parts, " | ")
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f kB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
 func hasHeader(hs []Header, nameLower string) bool {
 	for _, h := range hs {
 		if strings.ToLower(strings.TrimSpace(h.Name)) == nameLower {
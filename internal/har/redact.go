@@ -1,11 +1,21 @@
 package har
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
-	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"unicode/utf8"
+
+	"pocketcastsctl/internal/fsutil"
 )
 
 type RedactOptions struct {
@@ -13,13 +23,46 @@ type RedactOptions struct {
 	RedactQueryParms map[string]bool
 	RedactJSONKeys   map[string]bool
 	Replacement      string
+	// ScanValues also redacts values that look secret wherever they appear: JWTs,
+	// bearer tokens, emails and high-entropy strings.
+	ScanValues bool
+	// RedactUUIDs adds UUID-shaped values (account and episode ids) to ScanValues.
+	RedactUUIDs bool
+	// JSONPaths redacts values at these paths in JSON bodies, e.g. "$.user.email"
+	// or "$.episodes[*].url"; see ParseJSONPath.
+	JSONPaths []JSONPath
+	// Patterns are extra value detectors, applied wherever ScanValues looks.
+	Patterns []*regexp.Regexp
+	// Mode chooses what replaces a secret. The zero value is ReplaceText.
+	Mode ReplaceMode
+	// HashSalt is mixed into ReplaceHash placeholders so short values such as
+	// emails can't be recovered by hashing guesses.
+	HashSalt string
+	// MaxBodySize drops response bodies larger than this many bytes (audio,
+	// video) instead of loading them; 0 keeps every body.
+	MaxBodySize int64
 }
 
+// ReplaceMode is what a redacted value becomes.
+type ReplaceMode string
+
+const (
+	// ReplaceText substitutes Replacement.
+	ReplaceText ReplaceMode = "text"
+	// ReplaceHash substitutes Replacement plus a hash of the value, so one token
+	// maps to the same placeholder across entries.
+	ReplaceHash ReplaceMode = "hash"
+	// ReplaceRemove drops named headers, cookies, query parameters and JSON keys
+	// (array elements become null) and deletes matches inside larger strings.
+	ReplaceRemove ReplaceMode = "remove"
+)
+
 func DefaultRedactOptions() RedactOptions {
 	return RedactOptions{
 		RedactHeaders: map[string]bool{
 			"authorization":  true,
 			"cookie":         true,
+			"set-cookie":     true,
 			"x-csrf-token":   true,
 			"x-xsrf-token":   true,
 			"x-api-key":      true,
@@ -41,162 +84,415 @@ func DefaultRedactOptions() RedactOptions {
 			"cookie":       true,
 		},
 		Replacement: "<redacted>",
+		ScanValues:  true,
+		MaxBodySize: DefaultMaxBodySize,
 	}
 }
 
+// RedactFile streams inPath to outPath one entry at a time, so large captures
+// never sit in memory twice. Response bodies over opts.MaxBodySize are dropped.
+// outPath is replaced atomically, and only if the whole file was redacted.
 func RedactFile(inPath, outPath string, opts RedactOptions) error {
-	b, err := os.ReadFile(inPath)
+	in, err := os.Open(inPath)
 	if err != nil {
 		return err
 	}
+	defer in.Close()
+	r := newRedactor(opts)
+	return fsutil.WriteAtomic(outPath, 0o600, func(w io.Writer) error {
+		return streamEntries(in, w, opts.maxBody(), r.entryFunc(true))
+	})
+}
 
-	var root any
-	if err := json.Unmarshal(b, &root); err != nil {
-		return fmt.Errorf("parse HAR: %w", err)
+// AuditFile reports every location RedactFile would change, without values.
+func AuditFile(path string, opts RedactOptions) ([]Finding, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, err
 	}
+	defer in.Close()
+	r := newRedactor(opts)
+	if err := streamEntries(in, nil, opts.maxBody(), r.entryFunc(false)); err != nil {
+		return nil, err
+	}
+	return r.sortedFindings(), nil
+}
 
-	redactHAR(root, opts)
+func (o RedactOptions) maxBody() int64 {
+	if o.MaxBodySize <= 0 {
+		return -1
+	}
+	return o.MaxBodySize
+}
 
-	out, err := json.MarshalIndent(root, "", "  ")
-	if err != nil {
-		return err
+// redactor redacts one HAR in place and records what it changed.
+type redactor struct {
+	opts     RedactOptions
+	scan     scanner
+	entry    int
+	findings []Finding
+}
+
+func newRedactor(opts RedactOptions) *redactor {
+	r := &redactor{opts: opts}
+	r.scan = scanner{uuids: opts.RedactUUIDs, extra: opts.Patterns, replace: r.replacement}
+	return r
+}
+
+func (r *redactor) report(location, kind string) {
+	r.findings = append(r.findings, Finding{Entry: r.entry, Location: location, Kind: kind})
+}
+
+func (r *redactor) remove() bool {
+	return r.opts.Mode == ReplaceRemove
+}
+
+// replacement returns what value becomes (empty when removing).
+func (r *redactor) replacement(value string) string {
+	switch r.opts.Mode {
+	case ReplaceHash:
+		sum := sha256.Sum256([]byte(r.opts.HashSalt + value))
+		return r.opts.Replacement + ":" + hex.EncodeToString(sum[:6])
+	case ReplaceRemove:
+		return ""
+	default:
+		return r.opts.Replacement
 	}
-	out = append(out, '\n')
-	if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
-		return err
+}
+
+// redacted reports whether value is already a placeholder, so redacting a redacted
+// file (or auditing it) finds nothing.
+func (r *redactor) redacted(value any) bool {
+	s, ok := value.(string)
+	return ok && (s == r.opts.Replacement || strings.HasPrefix(s, r.opts.Replacement+":"))
+}
+
+// scanValue applies the value detectors to a whole field value.
+func (r *redactor) scanValue(s, location string, value bool) (string, bool) {
+	if !r.opts.ScanValues || s == "" || r.redacted(s) {
+		return s, false
+	}
+	out, kinds := r.scan.scan(s, value)
+	for _, k := range kinds {
+		r.report(location, k)
 	}
-	return os.WriteFile(outPath, out, 0o600)
+	return out, len(kinds) > 0
 }
 
-func redactHAR(root any, opts RedactOptions) {
+// redactHAR redacts a whole decoded HAR in place.
+func redactHAR(root any, opts RedactOptions) []Finding {
+	r := newRedactor(opts)
 	m, ok := root.(map[string]any)
 	if !ok {
-		return
+		return nil
 	}
 	log, ok := m["log"].(map[string]any)
 	if !ok {
-		return
+		return nil
 	}
 	entries, ok := log["entries"].([]any)
 	if !ok {
-		return
+		return nil
 	}
-
-	for _, entry := range entries {
-		em, ok := entry.(map[string]any)
-		if !ok {
-			continue
-		}
-		req, ok := em["request"].(map[string]any)
-		if !ok {
-			continue
+	for i, entry := range entries {
+		if em, ok := entry.(map[string]any); ok {
+			r.redactEntry(i, em)
 		}
-
-		redactHeaders(req["headers"], opts)
-		redactCookies(req["cookies"], opts)
-		redactQuery(req["queryString"], opts)
-		redactPostData(req["postData"], opts)
 	}
+	return r.sortedFindings()
 }
 
-func redactHeaders(headers any, opts RedactOptions) {
-	hs, ok := headers.([]any)
-	if !ok {
-		return
-	}
-	for _, h := range hs {
-		hm, ok := h.(map[string]any)
-		if !ok {
-			continue
+// entryFunc redacts entries as streamEntries hands them over; write is false when
+// only the findings are wanted.
+func (r *redactor) entryFunc(write bool) entryFunc {
+	return func(i int, raw []byte, skipped int64, indent string) ([]byte, error) {
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber() // keep numbers as written
+		var em map[string]any
+		if err := dec.Decode(&em); err != nil {
+			return nil, fmt.Errorf("parse HAR: entry %d: %w", i, err)
 		}
-		name, _ := hm["name"].(string)
-		if name == "" {
-			continue
+		if skipped > 0 {
+			if resp, ok := em["response"].(map[string]any); ok {
+				if cm, ok := resp["content"].(map[string]any); ok {
+					cm["comment"] = skippedComment(skipped)
+					delete(cm, "encoding")
+				}
+			}
 		}
-		if opts.RedactHeaders[strings.ToLower(strings.TrimSpace(name))] {
-			hm["value"] = opts.Replacement
+		r.redactEntry(i, em)
+		if !write {
+			return nil, nil
 		}
+		return marshalJSON(em, indent)
 	}
 }
 
-func redactCookies(cookies any, opts RedactOptions) {
-	cs, ok := cookies.([]any)
-	if !ok {
+func (r *redactor) redactEntry(i int, em map[string]any) {
+	r.entry = i
+	if req, ok := em["request"].(map[string]any); ok {
+		r.redactURL(req, "url", "request.url")
+		r.redactNamed(req, "headers", "request.headers", r.opts.RedactHeaders)
+		r.redactNamed(req, "cookies", "request.cookies", nil)
+		r.redactNamed(req, "queryString", "request.queryString", r.opts.RedactQueryParms)
+		r.redactPostData(req["postData"], "request.postData")
+	}
+	if resp, ok := em["response"].(map[string]any); ok {
+		r.redactURL(resp, "redirectURL", "response.redirectURL")
+		r.redactNamed(resp, "headers", "response.headers", r.opts.RedactHeaders)
+		r.redactNamed(resp, "cookies", "response.cookies", nil)
+		r.redactContent(resp["content"], "response.content")
+	}
+}
+
+// sortedFindings orders findings by entry, then location; JSON objects are
+// walked in map order.
+func (r *redactor) sortedFindings() []Finding {
+	sort.SliceStable(r.findings, func(i, j int) bool {
+		a, b := r.findings[i], r.findings[j]
+		if a.Entry != b.Entry {
+			return a.Entry < b.Entry
+		}
+		return a.Location < b.Location
+	})
+	return r.findings
+}
+
+// redactURL redacts query parameters by name and secrets anywhere in the URL
+// (e.g. an email in the path).
+func (r *redactor) redactURL(m map[string]any, key, location string) {
+	raw, _ := m[key].(string)
+	if raw == "" {
 		return
 	}
-	for _, c := range cs {
-		cm, ok := c.(map[string]any)
-		if !ok {
-			continue
+	if u, err := url.Parse(raw); err == nil && u.RawQuery != "" {
+		q := u.Query()
+		changed := false
+		for name, vs := range q {
+			if !r.opts.RedactQueryParms[strings.ToLower(name)] {
+				continue
+			}
+			if r.remove() {
+				q.Del(name)
+				changed = true
+				continue
+			}
+			for i := range vs {
+				if !r.redacted(vs[i]) {
+					vs[i] = r.replacement(vs[i])
+					changed = true
+				}
+			}
 		}
-		if _, ok := cm["value"]; ok {
-			cm["value"] = opts.Replacement
+		if changed {
+			r.report(location, "name")
+			u.RawQuery = q.Encode()
+			raw = u.String()
 		}
 	}
+	raw, _ = r.scanValue(raw, location, false)
+	m[key] = raw
 }
 
-func redactQuery(query any, opts RedactOptions) {
-	qs, ok := query.([]any)
+// redactNamed redacts a HAR name/value list (headers, cookies, queryString,
+// postData.params). Entries whose lower-cased name is in names are redacted whole;
+// a nil names redacts every entry, as for cookies. Other values are scanned.
+func (r *redactor) redactNamed(m map[string]any, key, location string, names map[string]bool) {
+	list, ok := m[key].([]any)
 	if !ok {
 		return
 	}
-	for _, q := range qs {
-		qm, ok := q.(map[string]any)
+	kind := "name"
+	if names == nil {
+		kind = "cookie"
+	}
+	kept := list[:0]
+	for _, item := range list {
+		im, ok := item.(map[string]any)
 		if !ok {
+			kept = append(kept, item)
 			continue
 		}
-		name, _ := qm["name"].(string)
-		if name == "" {
+		name, _ := im["name"].(string)
+		loc := location + "[" + name + "]"
+		value, _ := im["value"].(string)
+		if names == nil || names[strings.ToLower(strings.TrimSpace(name))] {
+			if _, has := im["value"]; has && !r.redacted(im["value"]) {
+				r.report(loc, kind)
+				if r.remove() {
+					continue
+				}
+				im["value"] = r.replacement(value)
+			}
+			kept = append(kept, item)
 			continue
 		}
-		if opts.RedactQueryParms[strings.ToLower(strings.TrimSpace(name))] {
-			qm["value"] = opts.Replacement
+		if v, changed := r.scanValue(value, loc, true); changed {
+			im["value"] = v
 		}
+		kept = append(kept, item)
 	}
+	m[key] = kept
 }
 
-func redactPostData(postData any, opts RedactOptions) {
+func (r *redactor) redactPostData(postData any, location string) {
 	pm, ok := postData.(map[string]any)
 	if !ok {
 		return
 	}
+	r.redactNamed(pm, "params", location+".params", r.opts.RedactQueryParms)
 	mime, _ := pm["mimeType"].(string)
 	text, _ := pm["text"].(string)
 	if text == "" {
 		return
 	}
+	pm["text"] = r.redactBody(text, mime, location+".text")
+}
 
-	if !strings.Contains(strings.ToLower(mime), "json") {
+// redactContent redacts a response body. Browsers export binary or
+// compressed-then-decoded bodies with encoding "base64"; those are decoded,
+// redacted and re-encoded. Binary bodies are left alone.
+func (r *redactor) redactContent(content any, location string) {
+	cm, ok := content.(map[string]any)
+	if !ok {
 		return
 	}
-
-	var body any
-	if err := json.Unmarshal([]byte(text), &body); err != nil {
+	text, _ := cm["text"].(string)
+	if text == "" {
 		return
 	}
-	redactJSON(body, opts)
-	b, err := json.Marshal(body)
-	if err != nil {
+	encoding, _ := cm["encoding"].(string)
+	isBase64 := strings.EqualFold(encoding, "base64")
+	if isBase64 {
+		b, err := base64.StdEncoding.DecodeString(text)
+		if err != nil || !utf8.Valid(b) {
+			return
+		}
+		text = string(b)
+	}
+
+	mime, _ := cm["mimeType"].(string)
+	redacted := r.redactBody(text, mime, location+".text")
+	if redacted == text {
 		return
 	}
-	pm["text"] = string(b)
+	if isBase64 {
+		redacted = base64.StdEncoding.EncodeToString([]byte(redacted))
+	}
+	cm["text"] = redacted
+}
+
+// redactBody redacts RedactJSONKeys, JSONPaths and secret values in a JSON body,
+// or secret values in any other text.
+func (r *redactor) redactBody(text, mime, location string) string {
+	if strings.Contains(strings.ToLower(mime), "json") || looksLikeJSON(text) {
+		var body any
+		if err := json.Unmarshal([]byte(text), &body); err == nil {
+			if !r.redactJSON(body, location, nil) {
+				return text
+			}
+			b, err := marshalBody(body)
+			if err != nil {
+				return text
+			}
+			return string(b)
+		}
+	}
+	out, _ := r.scanValue(text, location, false)
+	return out
+}
+
+// marshalBody encodes a redacted JSON body without escaping <, > and &, so
+// placeholders stay readable in the HAR and in exported commands.
+func marshalBody(v any) ([]byte, error) {
+	return marshalJSON(v, "")
+}
+
+// marshalJSON is marshalBody with indentation: lines after the first start with
+// prefix and nest by two spaces. An empty prefix produces compact JSON.
+func marshalJSON(v any, prefix string) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if prefix != "" {
+		enc.SetIndent(prefix, "  ")
+	}
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
 }
 
-func redactJSON(v any, opts RedactOptions) {
+// redactJSON reports whether it changed anything. path holds the keys (string)
+// and indexes (int) from the body's root to v, for JSONPaths.
+func (r *redactor) redactJSON(v any, location string, path []any) bool {
+	changed := false
 	switch vv := v.(type) {
 	case map[string]any:
 		for k, child := range vv {
-			if opts.RedactJSONKeys[k] {
-				vv[k] = opts.Replacement
+			loc := location + "." + k
+			childPath := append(path[:len(path):len(path)], k)
+			if r.opts.RedactJSONKeys[k] || r.matchPath(childPath) {
+				if !r.redacted(child) {
+					r.report(loc, "name")
+					changed = true
+					if r.remove() {
+						delete(vv, k)
+					} else {
+						vv[k] = r.replacement(fmt.Sprint(child))
+					}
+				}
+				continue
+			}
+			if s, ok := child.(string); ok {
+				if out, ok := r.scanValue(s, loc, true); ok {
+					vv[k] = out
+					changed = true
+				}
 				continue
 			}
-			redactJSON(child, opts)
+			changed = r.redactJSON(child, loc, childPath) || changed
 		}
 	case []any:
-		for _, child := range vv {
-			redactJSON(child, opts)
+		for i, child := range vv {
+			loc := fmt.Sprintf("%s[%d]", location, i)
+			childPath := append(path[:len(path):len(path)], i)
+			if r.matchPath(childPath) {
+				if child != nil && !r.redacted(child) {
+					r.report(loc, "name")
+					changed = true
+					if r.remove() {
+						vv[i] = nil
+					} else {
+						vv[i] = r.replacement(fmt.Sprint(child))
+					}
+				}
+				continue
+			}
+			if s, ok := child.(string); ok {
+				if out, ok := r.scanValue(s, loc, true); ok {
+					vv[i] = out
+					changed = true
+				}
+				continue
+			}
+			changed = r.redactJSON(child, loc, childPath) || changed
 		}
-	default:
-		return
 	}
+	return changed
+}
+
+func (r *redactor) matchPath(path []any) bool {
+	for _, p := range r.opts.JSONPaths {
+		if p.match(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// looksLikeJSON catches JSON served with a generic mime type (e.g. text/plain).
+func looksLikeJSON(text string) bool {
+	t := strings.TrimSpace(text)
+	return strings.HasPrefix(t, "{") || strings.HasPrefix(t, "[")
 }
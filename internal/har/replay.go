@@ -0,0 +1,161 @@
+package har
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// replaySkipHeaders describe the recorded transfer, not the body being replayed
+// (which is stored decoded).
+var replaySkipHeaders = map[string]bool{
+	"connection":        true,
+	"content-encoding":  true,
+	"content-length":    true,
+	"keep-alive":        true,
+	"transfer-encoding": true,
+}
+
+type ReplayOptions struct {
+	Host string // only replay entries whose host contains this
+	// Logf, if set, is called once per request with what was served.
+	Logf func(format string, args ...any)
+}
+
+// Replayer is an http.Handler that answers requests with the responses recorded in
+// a HAR, as a local fake of the API. A request matches entries with the same
+// method, path, query and body (JSON compared after normalizing key order and
+// spacing); failing that, entries with the same method and path. Entries matching
+// the same way are replayed in capture order, so the nth call gets the nth
+// response, and the last one repeats.
+type Replayer struct {
+	logf   func(format string, args ...any)
+	exact  map[string][]replayResponse
+	byPath map[string][]replayResponse
+
+	mu     sync.Mutex
+	served map[string]int
+}
+
+type replayResponse struct {
+	entry   int
+	status  int
+	headers []Header
+	body    string
+}
+
+func ReplayFile(path string, opts ReplayOptions) (*Replayer, error) {
+	f, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayer(f, opts)
+}
+
+func NewReplayer(f File, opts ReplayOptions) (*Replayer, error) {
+	rp := &Replayer{
+		logf:   opts.Logf,
+		exact:  map[string][]replayResponse{},
+		byPath: map[string][]replayResponse{},
+		served: map[string]int{},
+	}
+	host := strings.ToLower(strings.TrimSpace(opts.Host))
+	for i, e := range f.Log.Entries {
+		u, err := url.Parse(strings.TrimSpace(e.Request.URL))
+		if err != nil || u.Host == "" || e.Response.Status == 0 {
+			// Status 0 is a request that never completed (blocked, cancelled).
+			continue
+		}
+		if host != "" && !strings.Contains(strings.ToLower(u.Hostname()), host) {
+			continue
+		}
+		body, err := e.Response.Content.DecodedText()
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i, err)
+		}
+		resp := replayResponse{entry: i, status: e.Response.Status, headers: e.Response.Headers, body: body}
+		reqBody := ""
+		if e.Request.PostData != nil {
+			reqBody = e.Request.PostData.Text
+		}
+		method := strings.ToUpper(strings.TrimSpace(e.Request.Method))
+		ek := exactKey(method, u, reqBody)
+		pk := method + " " + u.EscapedPath()
+		rp.exact[ek] = append(rp.exact[ek], resp)
+		rp.byPath[pk] = append(rp.byPath[pk], resp)
+	}
+	if len(rp.byPath) == 0 {
+		return nil, fmt.Errorf("no replayable entries")
+	}
+	return rp, nil
+}
+
+func exactKey(method string, u *url.URL, body string) string {
+	return method + " " + u.EscapedPath() + "?" + u.Query().Encode() + "\n" + normalizeBody(body)
+}
+
+// normalizeBody makes equivalent JSON bodies compare equal: json.Marshal sorts
+// object keys and drops insignificant whitespace. Other bodies are trimmed.
+func normalizeBody(body string) string {
+	body = strings.TrimSpace(body)
+	if !looksLikeJSON(body) {
+		return body
+	}
+	var v any
+	if err := json.Unmarshal([]byte(body), &v); err != nil {
+		return body
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return string(b)
+}
+
+func (rp *Replayer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	method := strings.ToUpper(r.Method)
+	key := exactKey(method, r.URL, string(body))
+	list, match := rp.exact[key], "exact"
+	if len(list) == 0 {
+		key = method + " " + r.URL.EscapedPath()
+		list, match = rp.byPath[key], "path"
+	}
+	if len(list) == 0 {
+		rp.log("%s %s -> 404 (no recorded entry)", method, r.URL.RequestURI())
+		http.Error(w, fmt.Sprintf("no recorded response for %s %s", method, r.URL.EscapedPath()), http.StatusNotFound)
+		return
+	}
+
+	// Exact and path-only matches keep separate sequences.
+	rp.mu.Lock()
+	n := rp.served[match+" "+key]
+	rp.served[match+" "+key]++
+	rp.mu.Unlock()
+	resp := list[min(n, len(list)-1)]
+
+	for _, h := range resp.headers {
+		name := strings.TrimSpace(h.Name)
+		if name == "" || strings.HasPrefix(name, ":") || replaySkipHeaders[strings.ToLower(name)] {
+			continue
+		}
+		w.Header().Add(name, h.Value)
+	}
+	w.WriteHeader(resp.status)
+	_, _ = io.WriteString(w, resp.body)
+	rp.log("%s %s -> %d (entry %d, call %d, %s match)", method, r.URL.RequestURI(), resp.status, resp.entry, n+1, match)
+}
+
+func (rp *Replayer) log(format string, args ...any) {
+	if rp.logf != nil {
+		rp.logf(format, args...)
+	}
+}
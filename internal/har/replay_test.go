@@ -0,0 +1,87 @@
+package har
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReplayer(t *testing.T) {
+	list := func(status int, body string) Entry {
+		e := schemaEntry("POST", "https://api.pocketcasts.com/up_next/list", status, `{"version":2, "model":"webplayer"}`, body)
+		e.Response.Headers = []Header{{Name: "Content-Type", Value: "application/json"}, {Name: "Content-Length", Value: "999"}}
+		return e
+	}
+	f := File{Log: Log{Entries: []Entry{
+		list(200, `{"n":1}`),
+		list(200, `{"n":2}`),
+		schemaEntry("POST", "https://api.pocketcasts.com/up_next/list", 200, `{"version":1}`, `{"old":true}`),
+		schemaEntry("GET", "https://api.pocketcasts.com/user/profile?page=2", 401, "", `{"error":"auth"}`),
+		schemaEntry("GET", "https://example.com/user/profile", 200, "", `{"other":true}`),
+		schemaEntry("GET", "https://api.pocketcasts.com/never", 0, "", ``),
+	}}}
+	var logs []string
+	rp, err := NewReplayer(f, ReplayOptions{Host: "pocketcasts.com", Logf: func(format string, args ...any) {
+		logs = append(logs, format)
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(rp)
+	defer srv.Close()
+
+	call := func(method, path, body string) (int, string, http.Header) {
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(b), resp.Header
+	}
+
+	// Key order and spacing don't matter; the nth call gets the nth response.
+	for i, want := range []string{`{"n":1}`, `{"n":2}`, `{"n":2}`} {
+		code, body, h := call("POST", "/up_next/list", `{"model":"webplayer","version":2}`)
+		if code != 200 || body != want {
+			t.Fatalf("call %d: %d %s, want %s", i, code, body, want)
+		}
+		if h.Get("Content-Type") != "application/json" || h.Get("Content-Length") == "999" {
+			t.Fatalf("call %d headers: %v", i, h)
+		}
+	}
+	if _, body, _ := call("POST", "/up_next/list", `{"version":1}`); body != `{"old":true}` {
+		t.Fatalf("other body: %s", body)
+	}
+	// No exact match falls back to method and path, with its own sequence.
+	if _, body, _ := call("POST", "/up_next/list", `{"version":9}`); body != `{"n":1}` {
+		t.Fatalf("fallback: %s", body)
+	}
+	if code, body, _ := call("GET", "/user/profile?page=2", ""); code != 401 || body != `{"error":"auth"}` {
+		t.Fatalf("profile: %d %s", code, body)
+	}
+	if code, _, _ := call("GET", "/never", ""); code != 404 {
+		t.Fatalf("incomplete entry replayed: %d", code)
+	}
+	if len(logs) != 7 {
+		t.Fatalf("logs=%d", len(logs))
+	}
+}
+
+func TestNormalizeBody(t *testing.T) {
+	if a, b := normalizeBody(`{"b":1,"a":[1, 2]}`), normalizeBody(" {\"a\":[1,2],\n\"b\":1}"); a != b {
+		t.Fatalf("%q != %q", a, b)
+	}
+	if got := normalizeBody(" a=1&b=2 \n"); got != "a=1&b=2" {
+		t.Fatalf("form body=%q", got)
+	}
+}
+
+func TestNewReplayerNoEntries(t *testing.T) {
+	if _, err := NewReplayer(schemaFixture(), ReplayOptions{Host: "nowhere"}); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,196 @@
+package har
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Rules extend DefaultRedactOptions from a JSON file:
+//
+//	{
+//	  "headers": ["x-session-id"],
+//	  "queryParams": ["sig"],
+//	  "jsonKeys": ["deviceId"],
+//	  "jsonPaths": ["$.user.email", "$.episodes[*].url"],
+//	  "patterns": ["acct_[0-9]{6,}"],
+//	  "mode": "hash",
+//	  "hashSalt": "per-capture secret"
+//	}
+//
+// Every field is optional; the defaults stay in effect underneath.
+type Rules struct {
+	Headers     []string `json:"headers"`
+	QueryParams []string `json:"queryParams"`
+	JSONKeys    []string `json:"jsonKeys"`
+	JSONPaths   []string `json:"jsonPaths"`
+	Patterns    []string `json:"patterns"`
+	Mode        string   `json:"mode"` // text|hash|remove
+	Replacement string   `json:"replacement"`
+	HashSalt    string   `json:"hashSalt"`
+}
+
+// LoadRules reads a rules file. Unknown fields are rejected so a typo doesn't
+// silently leave a secret in place.
+func LoadRules(path string) (Rules, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Rules{}, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	var r Rules
+	if err := dec.Decode(&r); err != nil {
+		return Rules{}, fmt.Errorf("parse rules %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// Apply layers the rules over opts and returns the result; opts is not modified.
+func (r Rules) Apply(opts RedactOptions) (RedactOptions, error) {
+	opts.RedactHeaders = addNames(opts.RedactHeaders, r.Headers, true)
+	opts.RedactQueryParms = addNames(opts.RedactQueryParms, r.QueryParams, true)
+	opts.RedactJSONKeys = addNames(opts.RedactJSONKeys, r.JSONKeys, false)
+
+	opts.JSONPaths = append([]JSONPath(nil), opts.JSONPaths...)
+	for _, s := range r.JSONPaths {
+		p, err := ParseJSONPath(s)
+		if err != nil {
+			return RedactOptions{}, err
+		}
+		opts.JSONPaths = append(opts.JSONPaths, p)
+	}
+	opts.Patterns = append([]*regexp.Regexp(nil), opts.Patterns...)
+	for _, s := range r.Patterns {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return RedactOptions{}, fmt.Errorf("invalid pattern %q: %w", s, err)
+		}
+		opts.Patterns = append(opts.Patterns, re)
+	}
+
+	switch ReplaceMode(r.Mode) {
+	case "":
+	case ReplaceText, ReplaceHash, ReplaceRemove:
+		opts.Mode = ReplaceMode(r.Mode)
+	default:
+		return RedactOptions{}, fmt.Errorf("invalid mode %q (want text, hash or remove)", r.Mode)
+	}
+	if r.Replacement != "" {
+		opts.Replacement = r.Replacement
+	}
+	if r.HashSalt != "" {
+		opts.HashSalt = r.HashSalt
+	}
+	return opts, nil
+}
+
+func addNames(base map[string]bool, names []string, lower bool) map[string]bool {
+	out := maps.Clone(base)
+	if out == nil {
+		out = map[string]bool{}
+	}
+	for _, n := range names {
+		n = strings.TrimSpace(n)
+		if lower {
+			n = strings.ToLower(n)
+		}
+		if n != "" {
+			out[n] = true
+		}
+	}
+	return out
+}
+
+// JSONPath addresses values in a JSON body: "$" followed by .key, ['key'], [n],
+// and the wildcards .* and [*], which match any key or index.
+type JSONPath struct {
+	raw  string
+	segs []pathSeg
+}
+
+type pathSeg struct {
+	key      string
+	index    int // -1 for keys and wildcards
+	wildcard bool
+}
+
+func ParseJSONPath(s string) (JSONPath, error) {
+	raw := strings.TrimSpace(s)
+	if !strings.HasPrefix(raw, "$") {
+		return JSONPath{}, fmt.Errorf("invalid JSON path %q: must start with $", s)
+	}
+	p := JSONPath{raw: raw}
+	rest := raw[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			key := rest[:end]
+			rest = rest[end:]
+			if key == "" {
+				return JSONPath{}, fmt.Errorf("invalid JSON path %q: empty key", s)
+			}
+			p.segs = append(p.segs, pathSeg{key: key, index: -1, wildcard: key == "*"})
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return JSONPath{}, fmt.Errorf("invalid JSON path %q: missing ]", s)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			switch {
+			case inner == "*":
+				p.segs = append(p.segs, pathSeg{index: -1, wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				p.segs = append(p.segs, pathSeg{key: inner[1 : len(inner)-1], index: -1})
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil || n < 0 {
+					return JSONPath{}, fmt.Errorf("invalid JSON path %q: bad index %q", s, inner)
+				}
+				p.segs = append(p.segs, pathSeg{index: n})
+			}
+		default:
+			return JSONPath{}, fmt.Errorf("invalid JSON path %q: unexpected %q", s, rest[0])
+		}
+	}
+	if len(p.segs) == 0 {
+		return JSONPath{}, fmt.Errorf("invalid JSON path %q: the whole body can't be redacted", s)
+	}
+	return p, nil
+}
+
+func (p JSONPath) String() string { return p.raw }
+
+// match reports whether path (keys as strings, indexes as ints) is addressed by p.
+func (p JSONPath) match(path []any) bool {
+	if len(path) != len(p.segs) {
+		return false
+	}
+	for i, seg := range p.segs {
+		if seg.wildcard {
+			continue
+		}
+		switch v := path[i].(type) {
+		case string:
+			if seg.index >= 0 || v != seg.key {
+				return false
+			}
+		case int:
+			if seg.index != v {
+				return false
+			}
+		}
+	}
+	return true
+}
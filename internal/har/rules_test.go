@@ -0,0 +1,151 @@
+package har
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	tests := []struct {
+		path  string
+		in    []any
+		match bool
+	}{
+		{path: "$.user.email", in: []any{"user", "email"}, match: true},
+		{path: "$.user.email", in: []any{"user", "name"}},
+		{path: "$.user.email", in: []any{"user"}},
+		{path: "$.episodes[*].url", in: []any{"episodes", 3, "url"}, match: true},
+		{path: "$.episodes[1].url", in: []any{"episodes", 0, "url"}},
+		{path: "$['odd.key'].*", in: []any{"odd.key", "x"}, match: true},
+	}
+	for _, tt := range tests {
+		p, err := ParseJSONPath(tt.path)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.path, err)
+		}
+		if got := p.match(tt.in); got != tt.match {
+			t.Errorf("%s match %v = %v", tt.path, tt.in, got)
+		}
+	}
+	for _, bad := range []string{"user.email", "$", "$.a[", "$.a[x]", "$..a"} {
+		if _, err := ParseJSONPath(bad); err == nil {
+			t.Errorf("ParseJSONPath(%q): expected error", bad)
+		}
+	}
+}
+
+func TestLoadRulesRejectsUnknownFields(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(p, []byte(`{"header":["x"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRules(p); err == nil || !strings.Contains(err.Error(), "unknown field") {
+		t.Fatalf("err=%v", err)
+	}
+	if _, err := (Rules{Mode: "shred"}).Apply(DefaultRedactOptions()); err == nil {
+		t.Fatal("expected error for unknown mode")
+	}
+	if _, err := (Rules{Patterns: []string{"("}}).Apply(DefaultRedactOptions()); err == nil {
+		t.Fatal("expected error for bad pattern")
+	}
+}
+
+// redactWithRules redacts a one-entry HAR and returns the entry.
+func redactWithRules(t *testing.T, rules Rules) map[string]any {
+	t.Helper()
+	opts, err := rules.Apply(DefaultRedactOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := `{"user":{"uuid":"u-1","name":"Listener"},"episodes":[{"url":"https://cdn.example.com/a.mp3"},{"url":"https://cdn.example.com/b.mp3"}],"note":"acct_1234567"}`
+	entry := map[string]any{
+		"request": map[string]any{
+			"method": "GET",
+			"url":    "https://api.pocketcasts.com/user?sig=abc&page=1",
+			"headers": []any{
+				map[string]any{"name": "X-Session-Id", "value": "s-1"},
+				map[string]any{"name": "Authorization", "value": "Bearer abcdefgh12345678"},
+				map[string]any{"name": "Accept", "value": "application/json"},
+			},
+			"queryString": []any{
+				map[string]any{"name": "sig", "value": "abc"},
+				map[string]any{"name": "page", "value": "1"},
+			},
+		},
+		"response": map[string]any{
+			"content": map[string]any{"mimeType": "application/json", "text": body},
+		},
+	}
+	root := map[string]any{"log": map[string]any{"entries": []any{entry}}}
+	redactHAR(root, opts)
+	return entry
+}
+
+func TestRulesExtendDefaults(t *testing.T) {
+	rules := Rules{
+		Headers:     []string{"X-Session-Id"},
+		QueryParams: []string{"sig"},
+		JSONPaths:   []string{"$.user.uuid", "$.episodes[*].url"},
+		Patterns:    []string{`acct_[0-9]{6,}`},
+		Replacement: "[x]",
+	}
+	entry := redactWithRules(t, rules)
+	b, _ := json.Marshal(entry)
+	s := string(b)
+	for _, secret := range []string{"s-1", "abcdefgh", "sig=abc", "u-1", "a.mp3", "acct_1234567"} {
+		if strings.Contains(s, secret) {
+			t.Errorf("%q not redacted: %s", secret, s)
+		}
+	}
+	for _, kept := range []string{"application/json", "page=1", "Listener"} {
+		if !strings.Contains(s, kept) {
+			t.Errorf("%q should be kept: %s", kept, s)
+		}
+	}
+}
+
+func TestRulesHashMode(t *testing.T) {
+	entry := redactWithRules(t, Rules{Mode: "hash", JSONPaths: []string{"$.episodes[*].url"}})
+	var body struct {
+		Episodes []struct {
+			URL string `json:"url"`
+		} `json:"episodes"`
+	}
+	text := entry["response"].(map[string]any)["content"].(map[string]any)["text"].(string)
+	if err := json.Unmarshal([]byte(text), &body); err != nil {
+		t.Fatal(err)
+	}
+	a, b := body.Episodes[0].URL, body.Episodes[1].URL
+	if !strings.HasPrefix(a, "<redacted>:") || a == b {
+		t.Fatalf("urls=%q %q", a, b)
+	}
+	// The same value hashes to the same placeholder; a salt changes it.
+	r := newRedactor(DefaultRedactOptions())
+	r.opts.Mode = ReplaceHash
+	same := r.replacement("https://cdn.example.com/a.mp3")
+	if same != a {
+		t.Fatalf("hash not stable: %q vs %q", same, a)
+	}
+	r.opts.HashSalt = "salt"
+	if r.replacement("https://cdn.example.com/a.mp3") == a {
+		t.Fatal("salt ignored")
+	}
+}
+
+func TestRulesRemoveMode(t *testing.T) {
+	entry := redactWithRules(t, Rules{Mode: "remove", Headers: []string{"x-session-id"}, QueryParams: []string{"sig"}, JSONPaths: []string{"$.user.uuid"}})
+	req := entry["request"].(map[string]any)
+	if n := len(req["headers"].([]any)); n != 1 {
+		t.Fatalf("headers=%v", req["headers"])
+	}
+	if n := len(req["queryString"].([]any)); n != 1 || req["url"] != "https://api.pocketcasts.com/user?page=1" {
+		t.Fatalf("query=%v url=%v", req["queryString"], req["url"])
+	}
+	text := entry["response"].(map[string]any)["content"].(map[string]any)["text"].(string)
+	if strings.Contains(text, "uuid") || !strings.Contains(text, `"name":"Listener"`) {
+		t.Fatalf("body=%s", text)
+	}
+}
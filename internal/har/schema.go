@@ -0,0 +1,655 @@
+package har
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"math"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Schema inference groups entries by endpoint and merges every JSON request and
+// response body seen for it, so fields that are sometimes missing show up as
+// optional and short strings that repeat show up as enums.
+
+type SchemaOptions struct {
+	Host string
+	Path string // keep endpoints whose templated path contains this
+}
+
+// EndpointSchema is the inferred shape of one endpoint's bodies. Path has UUID and
+// numeric segments replaced by {uuid} and {id}; GraphQL requests are further split
+// by operationName.
+type EndpointSchema struct {
+	Method       string  `json:"method"`
+	Host         string  `json:"host"`
+	Path         string  `json:"path"`
+	Operation    string  `json:"operation,omitempty"`
+	Count        int     `json:"count"`
+	Name         string  `json:"name"` // Go-style base name, e.g. "UpNextList"
+	Request      *Schema `json:"request,omitempty"`
+	Response     *Schema `json:"response,omitempty"`
+	requestSeen  int
+	responseSeen int
+}
+
+// Schema is the subset of JSON Schema that inference produces.
+type Schema struct {
+	Title       string             `json:"title,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Type        SchemaType         `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"` // uuid|date-time
+	Enum        []string           `json:"enum,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+}
+
+// SchemaType is one JSON type or, for mixed and nullable values, several.
+type SchemaType []string
+
+func (t SchemaType) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+func (t SchemaType) has(name string) bool {
+	for _, s := range t {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// nonNull returns the type without "null", or "" when the value is mixed or unknown.
+func (t SchemaType) nonNull() string {
+	var out string
+	for _, s := range t {
+		if s == "null" {
+			continue
+		}
+		if out != "" {
+			return ""
+		}
+		out = s
+	}
+	return out
+}
+
+const (
+	maxEnumValues = 8
+	maxEnumLen    = 32
+)
+
+var (
+	uuidValue    = regexp.MustCompile(`(?i)^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+	numericValue = regexp.MustCompile(`^[0-9]+$`)
+)
+
+func InferSchemasFile(path string, opts SchemaOptions) ([]EndpointSchema, error) {
+	si := newSchemaInferrer(opts)
+	if err := eachEntryFile(path, DefaultMaxBodySize, func(_ int, e Entry) error {
+		si.add(e)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return si.schemas(), nil
+}
+
+func InferSchemas(f File, opts SchemaOptions) []EndpointSchema {
+	si := newSchemaInferrer(opts)
+	for _, e := range f.Log.Entries {
+		si.add(e)
+	}
+	return si.schemas()
+}
+
+type schemaKey struct {
+	method, host, path, op string
+}
+
+type schemaGroup struct {
+	ep        *EndpointSchema
+	req, resp *inferNode
+}
+
+// schemaInferrer accumulates the schemas of InferSchemas one entry at a time.
+type schemaInferrer struct {
+	hostNeedle string
+	pathNeedle string
+	groups     map[schemaKey]*schemaGroup
+}
+
+func newSchemaInferrer(opts SchemaOptions) *schemaInferrer {
+	return &schemaInferrer{
+		hostNeedle: strings.ToLower(strings.TrimSpace(opts.Host)),
+		pathNeedle: strings.TrimSpace(opts.Path),
+		groups:     map[schemaKey]*schemaGroup{},
+	}
+}
+
+func (si *schemaInferrer) add(e Entry) {
+	u, err := url.Parse(strings.TrimSpace(e.Request.URL))
+	if err != nil || u.Host == "" {
+		return
+	}
+	h := u.Hostname()
+	if si.hostNeedle != "" && !strings.Contains(strings.ToLower(h), si.hostNeedle) {
+		return
+	}
+	p := templatePath(u.EscapedPath())
+	if si.pathNeedle != "" && !strings.Contains(p, si.pathNeedle) {
+		return
+	}
+
+	var reqBody any
+	hasReq := false
+	if e.Request.PostData != nil {
+		reqBody, hasReq = parseJSONBody(e.Request.PostData.MimeType, e.Request.PostData.Text)
+	}
+	op := ""
+	if m, ok := reqBody.(map[string]any); ok && looksLikeGraphQL(e.Request.PostData.Text) {
+		op, _ = m["operationName"].(string)
+	}
+
+	k := schemaKey{method: strings.ToUpper(strings.TrimSpace(e.Request.Method)), host: h, path: p, op: op}
+	g := si.groups[k]
+	if g == nil {
+		g = &schemaGroup{
+			ep:   &EndpointSchema{Method: k.method, Host: k.host, Path: k.path, Operation: k.op},
+			req:  newInferNode(),
+			resp: newInferNode(),
+		}
+		si.groups[k] = g
+	}
+	g.ep.Count++
+	if hasReq {
+		g.req.add(reqBody)
+		g.ep.requestSeen++
+	}
+	// Error bodies have their own shape; only successful responses describe the endpoint.
+	if e.Response.Status >= 200 && e.Response.Status < 300 {
+		if text, err := e.Response.Content.DecodedText(); err == nil {
+			if body, ok := parseJSONBody(e.Response.Content.MimeType, text); ok {
+				g.resp.add(body)
+				g.ep.responseSeen++
+			}
+		}
+	}
+}
+
+func (si *schemaInferrer) schemas() []EndpointSchema {
+	out := make([]EndpointSchema, 0, len(si.groups))
+	for _, g := range si.groups {
+		if g.ep.requestSeen > 0 {
+			g.ep.Request = g.req.schema()
+		}
+		if g.ep.responseSeen > 0 {
+			g.ep.Response = g.resp.schema()
+		}
+		out = append(out, *g.ep)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Method != b.Method {
+			return a.Method < b.Method
+		}
+		return a.Operation < b.Operation
+	})
+	nameEndpoints(out)
+	return out
+}
+
+// templatePath replaces path segments that identify a resource, so
+// /podcast/<uuid>/episodes and /podcast/<other uuid>/episodes are one endpoint.
+func templatePath(p string) string {
+	segs := strings.Split(p, "/")
+	for i, s := range segs {
+		switch {
+		case uuidValue.MatchString(s):
+			segs[i] = "{uuid}"
+		case numericValue.MatchString(s):
+			segs[i] = "{id}"
+		}
+	}
+	return strings.Join(segs, "/")
+}
+
+func parseJSONBody(mimeType, text string) (any, bool) {
+	if !strings.Contains(strings.ToLower(mimeType), "json") && !looksLikeJSON(text) {
+		return nil, false
+	}
+	var v any
+	if err := json.Unmarshal([]byte(text), &v); err != nil {
+		return nil, false
+	}
+	return v, true
+}
+
+// nameEndpoints sets Name from the operation or the literal path segments,
+// prefixing the method where two endpoints would otherwise share a name.
+func nameEndpoints(eps []EndpointSchema) {
+	counts := map[string]int{}
+	for i := range eps {
+		if eps[i].Operation != "" {
+			eps[i].Name = goName(eps[i].Operation)
+		} else {
+			var words []string
+			for _, s := range strings.Split(eps[i].Path, "/") {
+				if s != "" && !strings.HasPrefix(s, "{") {
+					words = append(words, s)
+				}
+			}
+			eps[i].Name = goName(strings.Join(words, "_"))
+			if len(words) == 0 {
+				eps[i].Name = "Root"
+			}
+		}
+		counts[eps[i].Name]++
+	}
+	for i := range eps {
+		if counts[eps[i].Name] > 1 {
+			eps[i].Name = goName(strings.ToLower(eps[i].Method)) + eps[i].Name
+		}
+	}
+}
+
+// inferNode accumulates every value seen at one position in the bodies.
+type inferNode struct {
+	seen    int
+	types   map[string]int
+	objects int
+	props   map[string]*inferNode
+	items   *inferNode
+
+	strings, uuids, times int
+	values                map[string]int // distinct strings; nil once there are too many
+}
+
+func newInferNode() *inferNode {
+	return &inferNode{types: map[string]int{}, values: map[string]int{}}
+}
+
+func (n *inferNode) add(v any) {
+	n.seen++
+	switch v := v.(type) {
+	case nil:
+		n.types["null"]++
+	case bool:
+		n.types["boolean"]++
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			n.types["integer"]++
+		} else {
+			n.types["number"]++
+		}
+	case string:
+		n.types["string"]++
+		n.strings++
+		switch {
+		case uuidValue.MatchString(v):
+			n.uuids++
+		case isTimestamp(v):
+			n.times++
+		}
+		if n.values != nil {
+			n.values[v]++
+			if len(n.values) > maxEnumValues || len(v) > maxEnumLen {
+				n.values = nil
+			}
+		}
+	case []any:
+		n.types["array"]++
+		if n.items == nil {
+			n.items = newInferNode()
+		}
+		for _, x := range v {
+			n.items.add(x)
+		}
+	case map[string]any:
+		n.types["object"]++
+		n.objects++
+		if n.props == nil {
+			n.props = map[string]*inferNode{}
+		}
+		for k, x := range v {
+			p := n.props[k]
+			if p == nil {
+				p = newInferNode()
+				n.props[k] = p
+			}
+			p.add(x)
+		}
+	}
+}
+
+func isTimestamp(s string) bool {
+	if len(s) < len("2006-01-02T15:04:05Z") {
+		return false
+	}
+	_, err := time.Parse(time.RFC3339Nano, s)
+	return err == nil
+}
+
+var schemaTypeOrder = []string{"object", "array", "string", "integer", "number", "boolean", "null"}
+
+func (n *inferNode) schema() *Schema {
+	s := &Schema{}
+	for _, t := range schemaTypeOrder {
+		if n.types[t] == 0 {
+			continue
+		}
+		// A field that is sometimes fractional is a number throughout.
+		if t == "integer" && n.types["number"] > 0 {
+			continue
+		}
+		s.Type = append(s.Type, t)
+	}
+	if n.objects > 0 && len(n.props) > 0 {
+		s.Properties = make(map[string]*Schema, len(n.props))
+		for k, p := range n.props {
+			s.Properties[k] = p.schema()
+			if p.seen == n.objects {
+				s.Required = append(s.Required, k)
+			}
+		}
+		sort.Strings(s.Required)
+	}
+	if n.items != nil && n.items.seen > 0 {
+		s.Items = n.items.schema()
+	}
+	if n.strings > 0 {
+		switch {
+		case n.uuids == n.strings:
+			s.Format = "uuid"
+		case n.times == n.strings:
+			s.Format = "date-time"
+		case n.enumLike():
+			for v := range n.values {
+				s.Enum = append(s.Enum, v)
+			}
+			sort.Strings(s.Enum)
+		}
+	}
+	return s
+}
+
+// enumLike reports whether the strings repeat a small set of values, as status and
+// type fields do. Values must repeat about twice on average, so a handful of
+// distinct titles isn't mistaken for an enum.
+func (n *inferNode) enumLike() bool {
+	if n.values == nil || len(n.values) == 0 || n.uuids+n.times > 0 {
+		return false
+	}
+	return n.strings >= 3 && 2*len(n.values) <= n.strings+1
+}
+
+// FormatSchemasJSON renders the endpoints as one JSON Schema document with a
+// definition per request and response body, named like the Go types.
+func FormatSchemasJSON(eps []EndpointSchema) ([]byte, error) {
+	defs := map[string]*Schema{}
+	for _, ep := range eps {
+		if ep.Request != nil {
+			defs[ep.Name+"Request"] = describe(ep.Request, ep, "request")
+		}
+		if ep.Response != nil {
+			defs[ep.Name+"Response"] = describe(ep.Response, ep, "response")
+		}
+	}
+	doc := map[string]any{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$defs":   defs,
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func describe(s *Schema, ep EndpointSchema, body string) *Schema {
+	c := *s
+	c.Title = ep.Name + strings.ToUpper(body[:1]) + body[1:]
+	c.Description = endpointLabel(ep, body)
+	return &c
+}
+
+func endpointLabel(ep EndpointSchema, body string) string {
+	seen := ep.requestSeen
+	if body == "response" {
+		seen = ep.responseSeen
+	}
+	op := ""
+	if ep.Operation != "" {
+		op = " (" + ep.Operation + ")"
+	}
+	return fmt.Sprintf("%s body of %s %s%s%s, inferred from %s", body, ep.Method, ep.Host, ep.Path, op, plural(seen, "sample"))
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return "1 " + word
+	}
+	return strconv.Itoa(n) + " " + word + "s"
+}
+
+// FormatSchemasGo renders the endpoints as Go struct definitions in package pkg.
+// Optional fields get omitempty, nullable ones become pointers, and nested objects
+// become their own types named after the parent and field.
+func FormatSchemasGo(eps []EndpointSchema, pkg string) ([]byte, error) {
+	g := &goGen{used: map[string]bool{}}
+	fmt.Fprintf(&g.buf, "// Inferred by pocketcastsctl har schema; review field types before use.\n\npackage %s\n", pkg)
+	for _, ep := range eps {
+		if ep.Request != nil {
+			g.emit(ep.Name+"Request", ep.Request, endpointLabel(ep, "request"))
+		}
+		if ep.Response != nil {
+			g.emit(ep.Name+"Response", ep.Response, endpointLabel(ep, "response"))
+		}
+	}
+	out, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated Go: %w", err)
+	}
+	return out, nil
+}
+
+type goGen struct {
+	buf  bytes.Buffer
+	used map[string]bool
+}
+
+type goStruct struct {
+	name string
+	s    *Schema
+}
+
+// emit writes a top-level type and then, breadth first, the structs it refers to.
+func (g *goGen) emit(name string, s *Schema, doc string) {
+	name = g.unique(name)
+	var queue []goStruct
+	fmt.Fprintf(&g.buf, "\n// %s is the %s.\n", name, doc)
+	if s.Type.nonNull() == "object" && len(s.Properties) > 0 {
+		queue = append(queue, goStruct{name, s})
+	} else {
+		// Arrays of objects still queue their element struct.
+		fmt.Fprintf(&g.buf, "type %s %s\n", name, g.goType(name, s, &queue, false))
+	}
+	first := len(queue) == 0 || queue[0].s == s
+	for len(queue) > 0 {
+		st := queue[0]
+		queue = queue[1:]
+		if !first {
+			g.buf.WriteString("\n")
+		}
+		first = false
+		fmt.Fprintf(&g.buf, "type %s struct {\n", st.name)
+		keys := make([]string, 0, len(st.s.Properties))
+		for k := range st.s.Properties {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		required := map[string]bool{}
+		for _, k := range st.s.Required {
+			required[k] = true
+		}
+		fields := map[string]bool{}
+		for _, k := range keys {
+			p := st.s.Properties[k]
+			field := goName(k)
+			for fields[field] {
+				field += "_"
+			}
+			fields[field] = true
+			tag := k
+			if !required[k] {
+				tag += ",omitempty"
+			}
+			typ := g.goType(st.name+field, p, &queue, true)
+			fmt.Fprintf(&g.buf, "\t%s %s `json:%q`%s\n", field, typ, tag, goComment(p))
+		}
+		g.buf.WriteString("}\n")
+	}
+}
+
+// goType returns the Go type for s, queueing a struct named name if s is an object.
+func (g *goGen) goType(name string, s *Schema, queue *[]goStruct, field bool) string {
+	var t string
+	switch s.Type.nonNull() {
+	case "object":
+		if len(s.Properties) == 0 {
+			return "map[string]any"
+		}
+		if field {
+			name = g.unique(name)
+			*queue = append(*queue, goStruct{name, s})
+		}
+		t = name
+	case "array":
+		if s.Items == nil {
+			return "[]any"
+		}
+		return "[]" + g.goType(singular(name), s.Items, queue, true)
+	case "string":
+		t = "string"
+	case "integer":
+		t = "int"
+	case "number":
+		t = "float64"
+	case "boolean":
+		t = "bool"
+	default:
+		return "any"
+	}
+	if s.Type.has("null") {
+		return "*" + t
+	}
+	return t
+}
+
+func (g *goGen) unique(name string) string {
+	n := name
+	for i := 2; g.used[n]; i++ {
+		n = name + strconv.Itoa(i)
+	}
+	g.used[n] = true
+	return n
+}
+
+func goComment(s *Schema) string {
+	switch {
+	case s.Format != "":
+		return " // " + s.Format
+	case len(s.Enum) > 0:
+		return " // one of " + strings.Join(quoteAll(s.Enum), ", ")
+	case s.Type.nonNull() == "array" && s.Items != nil && s.Items.Format != "":
+		return " // " + s.Items.Format + "s"
+	}
+	return ""
+}
+
+func quoteAll(ss []string) []string {
+	out := make([]string, len(ss))
+	for i, s := range ss {
+		out[i] = strconv.Quote(s)
+	}
+	return out
+}
+
+func singular(name string) string {
+	if len(name) > 3 && strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") {
+		return name[:len(name)-1]
+	}
+	return name + "Item"
+}
+
+// goInitialisms are the words the Go style guide (and internal/pocketcasts) spells
+// in capitals.
+var goInitialisms = map[string]bool{
+	"api": true, "html": true, "http": true, "https": true, "id": true, "ids": true,
+	"json": true, "uri": true, "url": true, "urls": true, "uuid": true, "uuids": true,
+}
+
+// goName turns a JSON key or path into an exported Go identifier:
+// "podcastUuid" -> "PodcastUUID", "up_next" -> "UpNext".
+func goName(s string) string {
+	var words []string
+	var cur []rune
+	flush := func() {
+		if len(cur) > 0 {
+			words = append(words, string(cur))
+			cur = cur[:0]
+		}
+	}
+	rs := []rune(s)
+	for i, r := range rs {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+			continue
+		case unicode.IsUpper(r) && i > 0 && (unicode.IsLower(rs[i-1]) ||
+			(unicode.IsUpper(rs[i-1]) && i+1 < len(rs) && unicode.IsLower(rs[i+1]))):
+			flush()
+		}
+		cur = append(cur, r)
+	}
+	flush()
+
+	var b strings.Builder
+	for _, w := range words {
+		lw := strings.ToLower(w)
+		switch {
+		case goInitialisms[lw]:
+			up := strings.ToUpper(lw)
+			if strings.HasSuffix(lw, "s") {
+				up = strings.ToUpper(lw[:len(lw)-1]) + "s"
+			}
+			b.WriteString(up)
+		default:
+			rw := []rune(w)
+			b.WriteRune(unicode.ToUpper(rw[0]))
+			b.WriteString(string(rw[1:]))
+		}
+	}
+	out := b.String()
+	if out == "" {
+		return "Field"
+	}
+	if unicode.IsDigit([]rune(out)[0]) {
+		out = "F" + out
+	}
+	return out
+}
@@ -0,0 +1,166 @@
+package har
+
+import (
+	"encoding/json"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func schemaEntry(method, rawURL string, status int, reqBody, respBody string) Entry {
+	e := Entry{
+		Request:  Request{Method: method, URL: rawURL},
+		Response: Response{Status: status, Content: Content{MimeType: "application/json", Text: respBody}},
+	}
+	if reqBody != "" {
+		e.Request.PostData = &PostData{MimeType: "application/json", Text: reqBody}
+	}
+	return e
+}
+
+func schemaFixture() File {
+	return File{Log: Log{Entries: []Entry{
+		schemaEntry("POST", "https://api.pocketcasts.com/up_next/list", 200,
+			`{"version":1,"model":"webplayer"}`,
+			`{"serverModified":1700000000000,"episodes":[{"uuid":"11111111-2222-3333-4444-555555555555","title":"One","published":"2024-01-02T03:04:05Z","playingStatus":"played","duration":123.5}]}`),
+		schemaEntry("POST", "https://api.pocketcasts.com/up_next/list", 200,
+			`{"version":2,"model":"webplayer","showPlayStatus":true}`,
+			`{"serverModified":1700000000001,"episodes":[{"uuid":"21111111-2222-3333-4444-555555555555","title":"Two","published":"2024-01-03T03:04:05Z","playingStatus":"unplayed","duration":60,"podcast":null},{"uuid":"31111111-2222-3333-4444-555555555555","title":"Three","published":"2024-01-04T03:04:05Z","playingStatus":"played","duration":61,"podcast":"x"}]}`),
+		// Error responses and other hosts are ignored.
+		schemaEntry("POST", "https://api.pocketcasts.com/up_next/list", 401, `{"version":3,"model":"webplayer"}`, `{"errorMessage":"nope"}`),
+		schemaEntry("GET", "https://example.com/up_next/list", 200, "", `{"other":true}`),
+		schemaEntry("GET", "https://api.pocketcasts.com/podcast/11111111-2222-3333-4444-555555555555/episodes/42", 200, "", `[{"uuid":"11111111-2222-3333-4444-555555555555"}]`),
+		schemaEntry("GET", "https://api.pocketcasts.com/podcast/21111111-2222-3333-4444-555555555555/episodes/43", 200, "", `[]`),
+	}}}
+}
+
+func TestInferSchemas(t *testing.T) {
+	eps := InferSchemas(schemaFixture(), SchemaOptions{Host: "api.pocketcasts.com"})
+	if len(eps) != 2 {
+		t.Fatalf("endpoints=%d: %+v", len(eps), eps)
+	}
+	podcast, upNext := eps[0], eps[1]
+	if podcast.Path != "/podcast/{uuid}/episodes/{id}" || podcast.Count != 2 || podcast.Name != "PodcastEpisodes" {
+		t.Fatalf("podcast endpoint=%+v", podcast)
+	}
+	if podcast.Request != nil || podcast.Response.Items.Properties["uuid"].Format != "uuid" {
+		t.Fatalf("podcast schema=%+v", podcast)
+	}
+	if upNext.Path != "/up_next/list" || upNext.Count != 3 || upNext.Name != "UpNextList" {
+		t.Fatalf("up next endpoint=%+v", upNext)
+	}
+
+	req := upNext.Request
+	if want := []string{"model", "version"}; !reflect.DeepEqual(req.Required, want) {
+		t.Fatalf("request required=%v, want %v", req.Required, want)
+	}
+	if got := req.Properties["model"].Enum; !reflect.DeepEqual(got, []string{"webplayer"}) {
+		t.Fatalf("model enum=%v", got)
+	}
+
+	ep := upNext.Response.Properties["episodes"].Items
+	checks := map[string]struct {
+		typ    []string
+		format string
+	}{
+		"uuid":          {[]string{"string"}, "uuid"},
+		"published":     {[]string{"string"}, "date-time"},
+		"title":         {[]string{"string"}, ""},
+		"duration":      {[]string{"number"}, ""},
+		"podcast":       {[]string{"string", "null"}, ""},
+		"playingStatus": {[]string{"string"}, ""},
+	}
+	for name, want := range checks {
+		p := ep.Properties[name]
+		if p == nil || !reflect.DeepEqual([]string(p.Type), want.typ) || p.Format != want.format {
+			t.Fatalf("%s=%+v, want %+v", name, p, want)
+		}
+	}
+	if got := ep.Properties["playingStatus"].Enum; !reflect.DeepEqual(got, []string{"played", "unplayed"}) {
+		t.Fatalf("playingStatus enum=%v", got)
+	}
+	if ep.Properties["title"].Enum != nil {
+		t.Fatalf("distinct titles treated as enum: %v", ep.Properties["title"].Enum)
+	}
+	if want := []string{"duration", "playingStatus", "published", "title", "uuid"}; !reflect.DeepEqual(ep.Required, want) {
+		t.Fatalf("episode required=%v, want %v", ep.Required, want)
+	}
+}
+
+func TestInferSchemasGraphQLOperations(t *testing.T) {
+	f := File{Log: Log{Entries: []Entry{
+		schemaEntry("POST", "https://play.pocketcasts.com/graphql", 200, `{"operationName":"Login","variables":{}}`, `{"data":{"token":"x"}}`),
+		schemaEntry("POST", "https://play.pocketcasts.com/graphql", 200, `{"operationName":"Profile","variables":{}}`, `{"data":{"email":"x"}}`),
+	}}}
+	eps := InferSchemas(f, SchemaOptions{})
+	if len(eps) != 2 || eps[0].Name != "Login" || eps[1].Name != "Profile" {
+		t.Fatalf("endpoints=%+v", eps)
+	}
+}
+
+func TestFormatSchemasJSON(t *testing.T) {
+	b, err := FormatSchemasJSON(InferSchemas(schemaFixture(), SchemaOptions{Host: "api.pocketcasts.com"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Schema string                     `json:"$schema"`
+		Defs   map[string]json.RawMessage `json:"$defs"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Schema == "" || len(doc.Defs) != 3 {
+		t.Fatalf("doc=%s", b)
+	}
+	var podcast map[string]any
+	_ = json.Unmarshal(doc.Defs["UpNextListResponse"], &podcast)
+	if podcast["type"] != "object" || !strings.Contains(podcast["description"].(string), "2 samples") {
+		t.Fatalf("UpNextListResponse=%s", doc.Defs["UpNextListResponse"])
+	}
+}
+
+func TestFormatSchemasGo(t *testing.T) {
+	b, err := FormatSchemasGo(InferSchemas(schemaFixture(), SchemaOptions{Host: "api.pocketcasts.com"}), "pocketcasts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(b)
+	if _, err := parser.ParseFile(token.NewFileSet(), "schema.go", b, 0); err != nil {
+		t.Fatalf("generated Go does not parse: %v\n%s", err, src)
+	}
+	for _, want := range []string{
+		"type PodcastEpisodesResponse []PodcastEpisodesResponseItem",
+		"type UpNextListRequest struct",
+		"ShowPlayStatus bool   `json:\"showPlayStatus,omitempty\"`",
+		"Episodes       []UpNextListResponseEpisode `json:\"episodes\"`",
+		"type UpNextListResponseEpisode struct",
+		"Podcast       *string `json:\"podcast,omitempty\"`",
+		"UUID          string  `json:\"uuid\"` // uuid",
+		`// one of "played", "unplayed"`,
+		"Duration      float64",
+		"ServerModified int",
+	} {
+		if !strings.Contains(src, want) {
+			t.Fatalf("missing %q in:\n%s", want, src)
+		}
+	}
+}
+
+func TestGoName(t *testing.T) {
+	for in, want := range map[string]string{
+		"podcastUuid":     "PodcastUUID",
+		"up_next_list":    "UpNextList",
+		"episodeUuids":    "EpisodeUUIDs",
+		"URLPath":         "URLPath",
+		"show-play-state": "ShowPlayState",
+		"2fa":             "F2fa",
+		"":                "Field",
+	} {
+		if got := goName(in); got != want {
+			t.Errorf("goName(%q)=%q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package har
+
+import (
+	"math"
+	"regexp"
+)
+
+// Finding is a location in a HAR that looks sensitive. It never carries the value.
+type Finding struct {
+	Entry    int    `json:"entry"`    // index into log.entries
+	Location string `json:"location"` // e.g. "request.headers[X-Session]" or "response.content.text.user.email"
+	Kind     string `json:"kind"`     // name|cookie|jwt|bearer|email|uuid|entropy|pattern
+}
+
+// Value detectors. The JWT pattern has the shape browsercontrol uses to score token
+// candidates (three base64url segments) but is unanchored, so it finds tokens inside
+// longer strings, and requires the "eyJ" that starts every base64-encoded JSON header
+// so host names like "api.pocketcasts.com" don't match.
+var (
+	jwtPattern    = regexp.MustCompile(`eyJ[A-Za-z0-9_-]{4,}\.[A-Za-z0-9_-]{4,}\.[A-Za-z0-9_-]+`)
+	bearerPattern = regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/=-]{8,}`)
+	emailPattern  = regexp.MustCompile(`[A-Za-z0-9._+-]+(?:@|%40)[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+	uuidPattern   = regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`)
+	// entropyCandidate finds opaque runs long enough to be API keys or session ids.
+	entropyCandidate = regexp.MustCompile(`[A-Za-z0-9+/_=-]{32,}`)
+)
+
+// minEntropy is in bits per character. Hex digests top out at 4, so only strings
+// drawn from a larger alphabet (base64 keys, random session ids) exceed it.
+const minEntropy = 4.0
+
+// scanner replaces sensitive substrings and reports their kinds.
+type scanner struct {
+	uuids   bool
+	extra   []*regexp.Regexp // user-defined, reported as "pattern"
+	replace func(match string) string
+}
+
+// scan replaces every match in s. value is true when s is a whole field value
+// (header, cookie, query parameter, JSON string): only then are high-entropy runs
+// treated as secrets, since free text such as scripts is full of them.
+func (sc scanner) scan(s string, value bool) (string, []string) {
+	var kinds []string
+	replace := func(kind string, re *regexp.Regexp, keep func(string) bool) {
+		s = re.ReplaceAllStringFunc(s, func(m string) string {
+			if keep != nil && keep(m) {
+				return m
+			}
+			kinds = addHint(kinds, kind)
+			return sc.replace(m)
+		})
+	}
+	replace("jwt", jwtPattern, nil)
+	replace("bearer", bearerPattern, nil)
+	replace("email", emailPattern, nil)
+	if sc.uuids {
+		replace("uuid", uuidPattern, nil)
+	}
+	for _, re := range sc.extra {
+		replace("pattern", re, nil)
+	}
+	if value {
+		replace("entropy", entropyCandidate, func(m string) bool { return !highEntropy(m) })
+	}
+	return s, kinds
+}
+
+func highEntropy(s string) bool {
+	hasLetter, hasDigit := false, false
+	counts := map[rune]int{}
+	for _, r := range s {
+		counts[r]++
+		switch {
+		case r >= '0' && r <= '9':
+			hasDigit = true
+		case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z':
+			hasLetter = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return false
+	}
+	n := float64(len(s))
+	h := 0.0
+	for _, c := range counts {
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h > minEntropy
+}
@@ -0,0 +1,333 @@
+package har
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Captures with audio or video responses run to hundreds of megabytes, so files
+// are read as a stream: the document outside log.entries is copied through
+// byte for byte, and each entry is decoded on its own.
+
+// DefaultMaxBodySize is the largest response body kept when reading a capture.
+// Larger bodies are dropped while scanning, so they are never held in memory, and
+// the entry's content.comment records their size.
+const DefaultMaxBodySize = 16 << 20
+
+// entryFunc receives one element of log.entries as raw JSON, with the response
+// body emptied if it was skipped (skipped is then its size in bytes). indent is
+// the whitespace the entry starts after on its line, or "" if the file isn't
+// indented. It returns what to write in the entry's place.
+type entryFunc func(i int, raw []byte, skipped int64, indent string) ([]byte, error)
+
+// streamEntries copies the HAR document from r to w (w may be nil), handing each
+// element of log.entries to fn. Response bodies over maxBody bytes are skipped;
+// maxBody < 0 keeps every body.
+func streamEntries(r io.Reader, w io.Writer, maxBody int64, fn entryFunc) error {
+	s := &harScanner{r: bufio.NewReaderSize(r, 64<<10), maxBody: maxBody, fn: fn}
+	if w != nil {
+		bw := bufio.NewWriterSize(w, 64<<10)
+		s.w = bw
+		if err := s.run(); err != nil {
+			return err
+		}
+		return bw.Flush()
+	}
+	return s.run()
+}
+
+// EachEntry calls fn with each entry of the HAR read from r, in order. Response
+// bodies over maxBody bytes are skipped (maxBody < 0 keeps all), with
+// Content.Comment saying so.
+func EachEntry(r io.Reader, maxBody int64, fn func(i int, e Entry) error) error {
+	return streamEntries(r, nil, maxBody, func(i int, raw []byte, skipped int64, _ string) ([]byte, error) {
+		var e Entry
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return nil, fmt.Errorf("parse HAR: entry %d: %w", i, err)
+		}
+		if skipped > 0 {
+			e.Response.Content.Comment = skippedComment(skipped)
+		}
+		return nil, fn(i, e)
+	})
+}
+
+func eachEntryFile(path string, maxBody int64, fn func(i int, e Entry) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return EachEntry(f, maxBody, fn)
+}
+
+func skippedComment(n int64) string {
+	return fmt.Sprintf("pocketcastsctl: %d-byte body skipped", n)
+}
+
+type harScanner struct {
+	r       *bufio.Reader
+	w       *bufio.Writer // nil discards the document
+	maxBody int64
+	fn      entryFunc
+
+	stack []scanFrame
+	root  bool // the top-level object has started
+
+	// The entry being read.
+	inEntry    bool
+	entryDepth int
+	entry      bytes.Buffer
+	skipped    int64
+	n          int
+
+	// indent is the whitespace since the last newline outside entries; indented
+	// is false until a newline has been seen.
+	indent   []byte
+	indented bool
+
+	key  bytes.Buffer
+	body bytes.Buffer
+}
+
+type scanFrame struct {
+	object  bool
+	wantKey bool   // in an object, before a key
+	key     string // in an object, the key whose value is being read
+}
+
+var errUnexpectedEOF = errors.New("parse HAR: unexpected end of file")
+
+func (s *harScanner) write(b ...byte) {
+	switch {
+	case s.inEntry:
+		s.entry.Write(b)
+	case s.w != nil:
+		s.w.Write(b)
+	}
+}
+
+func (s *harScanner) run() error {
+	for {
+		c, err := s.r.ReadByte()
+		if err == io.EOF {
+			if !s.root || len(s.stack) > 0 {
+				return errUnexpectedEOF
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(s.stack) == 0 && !isSpace(c) {
+			if s.root || c != '{' {
+				return errors.New("parse HAR: not a JSON object")
+			}
+			s.root = true
+		}
+
+		switch c {
+		case '{', '[':
+			if s.atEntries() {
+				s.startEntry()
+			}
+			s.write(c)
+			s.stack = append(s.stack, scanFrame{object: c == '{', wantKey: c == '{'})
+		case '}', ']':
+			if len(s.stack) == 0 {
+				return errors.New("parse HAR: unbalanced " + string(c))
+			}
+			s.stack = s.stack[:len(s.stack)-1]
+			s.write(c)
+			if s.inEntry && len(s.stack) == s.entryDepth {
+				if err := s.endEntry(); err != nil {
+					return err
+				}
+			}
+		case ',':
+			s.write(c)
+			if top := s.top(); top != nil && top.object {
+				top.wantKey = true
+			}
+		case '"':
+			top := s.top()
+			switch {
+			case top != nil && top.object && top.wantKey:
+				if err := s.readKey(top); err != nil {
+					return err
+				}
+			case s.atBody():
+				if err := s.readBody(); err != nil {
+					return err
+				}
+			default:
+				if s.atEntries() {
+					return fmt.Errorf("parse HAR: log.entries[%d] is not an object", s.n)
+				}
+				s.write(c)
+				if err := s.readString(func(b []byte) { s.write(b...) }); err != nil {
+					return err
+				}
+			}
+		default:
+			if s.atEntries() && !isSpace(c) {
+				return fmt.Errorf("parse HAR: log.entries[%d] is not an object", s.n)
+			}
+			s.write(c)
+		}
+
+		if !s.inEntry {
+			switch c {
+			case '\n':
+				s.indent, s.indented = s.indent[:0], true
+			case ' ', '\t', '\r':
+				s.indent = append(s.indent, c)
+			default:
+				s.indent = s.indent[:0]
+			}
+		}
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func (s *harScanner) top() *scanFrame {
+	if len(s.stack) == 0 {
+		return nil
+	}
+	return &s.stack[len(s.stack)-1]
+}
+
+// atEntries reports whether the next value is an element of log.entries.
+func (s *harScanner) atEntries() bool {
+	return !s.inEntry && len(s.stack) == 3 &&
+		s.stack[0].object && s.stack[0].key == "log" &&
+		s.stack[1].object && s.stack[1].key == "entries" &&
+		!s.stack[2].object
+}
+
+// atBody reports whether the next string is the entry's response.content.text.
+func (s *harScanner) atBody() bool {
+	d := s.entryDepth
+	if !s.inEntry || s.maxBody < 0 || len(s.stack) != d+3 {
+		return false
+	}
+	for i, key := range []string{"response", "content", "text"} {
+		if f := s.stack[d+i]; !f.object || f.key != key {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *harScanner) startEntry() {
+	s.inEntry = true
+	s.entryDepth = len(s.stack)
+	s.entry.Reset()
+	s.skipped = 0
+}
+
+func (s *harScanner) endEntry() error {
+	s.inEntry = false
+	indent := ""
+	if s.indented {
+		indent = string(s.indent)
+	}
+	out, err := s.fn(s.n, s.entry.Bytes(), s.skipped, indent)
+	s.n++
+	if err != nil {
+		return err
+	}
+	if s.w != nil {
+		s.w.Write(out)
+	}
+	return nil
+}
+
+func (s *harScanner) readKey(top *scanFrame) error {
+	s.write('"')
+	s.key.Reset()
+	if err := s.readString(func(b []byte) { s.write(b...); s.key.Write(b) }); err != nil {
+		return err
+	}
+	raw := s.key.Bytes()[:s.key.Len()-1] // without the closing quote
+	top.key = string(raw)
+	if bytes.IndexByte(raw, '\\') >= 0 {
+		var k string
+		if err := json.Unmarshal(append([]byte{'"'}, s.key.Bytes()...), &k); err == nil {
+			top.key = k
+		}
+	}
+	top.wantKey = false
+	return nil
+}
+
+// readBody reads the response body string, keeping at most maxBody bytes of it.
+func (s *harScanner) readBody() error {
+	s.body.Reset()
+	var size int64
+	err := s.readString(func(b []byte) {
+		size += int64(len(b))
+		if size-1 <= s.maxBody {
+			s.body.Write(b)
+		} else if s.body.Len() > 0 {
+			s.body.Reset()
+		}
+	})
+	if err != nil {
+		return err
+	}
+	size-- // the closing quote
+	if size > s.maxBody {
+		s.skipped = size
+		s.write('"', '"')
+		return nil
+	}
+	s.write('"')
+	s.write(s.body.Bytes()...)
+	return nil
+}
+
+// readString consumes a string after its opening quote, passing emit the raw
+// bytes up to and including the closing quote. emit must copy what it keeps.
+func (s *harScanner) readString(emit func([]byte)) error {
+	backslashes := 0 // trailing run of backslashes before the current chunk
+	for {
+		chunk, err := s.r.ReadSlice('"')
+		if err != nil && !errors.Is(err, bufio.ErrBufferFull) {
+			if err == io.EOF {
+				return errUnexpectedEOF
+			}
+			return err
+		}
+		emit(chunk)
+		if err != nil { // buffer full: the string continues
+			backslashes = trailingBackslashes(chunk, backslashes)
+			continue
+		}
+		// A quote preceded by an odd number of backslashes is escaped.
+		if trailingBackslashes(chunk[:len(chunk)-1], backslashes)%2 == 0 {
+			return nil
+		}
+		backslashes = 0
+	}
+}
+
+func trailingBackslashes(b []byte, before int) int {
+	n := 0
+	for i := len(b) - 1; i >= 0 && b[i] == '\\'; i-- {
+		n++
+	}
+	if n == len(b) {
+		return before + n
+	}
+	return n
+}
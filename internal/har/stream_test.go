@@ -0,0 +1,193 @@
+package har
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// streamHAR wraps entries (raw JSON) in a minimal HAR with fields around them.
+func streamHAR(entries ...string) string {
+	return `{"log": {"version": "1.2", "entries": [` + strings.Join(entries, ", ") + `], "pages": [{"id": "p\"1"}]}, "x": [1]}`
+}
+
+func TestStreamEntriesCopiesDocument(t *testing.T) {
+	in := streamHAR(
+		`{"request": {"url": "https://a/\"quoted\\\\"}, "response": {"status": 200, "content": {"text": "small"}}}`,
+		`{"response": {"content": {"mimeType": "audio/mpeg", "text": "0123456789abcdef", "encoding": "base64"}}}`,
+	)
+	var out strings.Builder
+	var got []string
+	var skipped []int64
+	err := streamEntries(strings.NewReader(in), &out, 8, func(i int, raw []byte, n int64, indent string) ([]byte, error) {
+		got = append(got, string(raw))
+		skipped = append(skipped, n)
+		if indent != "" {
+			t.Fatalf("indent=%q for a one-line file", indent)
+		}
+		return []byte(strconv.Itoa(i)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := streamHAR("0", "1"); out.String() != want {
+		t.Fatalf("document:\n got %s\nwant %s", out.String(), want)
+	}
+	if !strings.Contains(got[0], `"text": "small"`) || !strings.Contains(got[0], `\"quoted\\\\"`) || skipped[0] != 0 {
+		t.Fatalf("entry 0=%s skipped=%d", got[0], skipped[0])
+	}
+	if !strings.Contains(got[1], `"text": ""`) || skipped[1] != 16 {
+		t.Fatalf("entry 1=%s skipped=%d", got[1], skipped[1])
+	}
+}
+
+func TestEachEntrySkipsLargeBodies(t *testing.T) {
+	in := streamHAR(
+		`{"request": {"method": "GET", "postData": {"text": "request bodies are kept"}}, "response": {"content": {"text": "12345678"}}}`,
+		`{"response": {"content": {"text": "123456789"}}}`,
+	)
+	var entries []Entry
+	if err := EachEntry(strings.NewReader(in), 8, func(_ int, e Entry) error {
+		entries = append(entries, e)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("entries=%d", len(entries))
+	}
+	if c := entries[0].Response.Content; c.Text != "12345678" || c.Comment != "" || entries[0].Request.PostData.Text != "request bodies are kept" {
+		t.Fatalf("entry 0=%+v", entries[0])
+	}
+	if c := entries[1].Response.Content; c.Text != "" || c.Comment != "pocketcastsctl: 9-byte body skipped" {
+		t.Fatalf("entry 1 content=%+v", c)
+	}
+}
+
+func TestStreamEntriesErrors(t *testing.T) {
+	for name, in := range map[string]string{
+		"truncated":     `{"log": {"entries": [{"request": {"url": "https://a`,
+		"not an object": `[1, 2]`,
+		"bad entry":     `{"log": {"entries": ["x"]}}`,
+		"empty":         ``,
+	} {
+		err := streamEntries(strings.NewReader(in), nil, -1, func(int, []byte, int64, string) ([]byte, error) { return nil, nil })
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestRedactFileStreamsLargeBodies(t *testing.T) {
+	tmp := t.TempDir()
+	in := filepath.Join(tmp, "in.har")
+	out := filepath.Join(tmp, "out.har")
+	audio := base64.StdEncoding.EncodeToString(make([]byte, 3000))
+	har := "{\n  \"log\": {\n    \"entries\": [\n      " +
+		`{"request": {"url": "https://api.pocketcasts.com/x", "headers": [{"name": "Authorization", "value": "Bearer abcdefghijk"}]},` +
+		` "response": {"content": {"mimeType": "audio/mpeg", "encoding": "base64", "text": "` + audio + `"}}}` +
+		"\n    ]\n  }\n}\n"
+	if err := os.WriteFile(in, []byte(har), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultRedactOptions()
+	opts.MaxBodySize = 1000
+	if err := RedactFile(in, out, opts); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(out)
+	s := string(b)
+	if strings.Contains(s, "abcdefghijk") || strings.Contains(s, audio[:100]) {
+		t.Fatalf("output keeps secrets or the skipped body:\n%s", s)
+	}
+	// Entries are re-indented to their position in the document.
+	if !strings.Contains(s, "\n      {\n        \"request\": {") || !strings.HasSuffix(s, "\n    ]\n  }\n}\n") {
+		t.Fatalf("layout:\n%s", s)
+	}
+	var f File
+	if err := json.Unmarshal(b, &f); err != nil {
+		t.Fatal(err)
+	}
+	c := f.Log.Entries[0].Response.Content
+	if c.Text != "" || c.Encoding != "" || !strings.Contains(c.Comment, "4000-byte body skipped") {
+		t.Fatalf("content=%+v", c)
+	}
+}
+
+// benchHAR writes a capture of about mb megabytes: API calls interleaved with
+// base64 audio responses larger than DefaultMaxBodySize.
+func benchHAR(b *testing.B, mb int) string {
+	b.Helper()
+	path := filepath.Join(b.TempDir(), "large.har")
+	f, err := os.Create(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	w := bufio.NewWriterSize(f, 1<<20)
+	audio := strings.Repeat("SUQzBAAAAAAAI1RTU0UAAAAPAAADTGF2ZjU4Ljc2LjEwMAAAAAAAAAAAAAAA", (20<<20)/60)
+	api := `{"request": {"method": "POST", "url": "https://api.pocketcasts.com/up_next/list", "headers": [{"name": "Authorization", "value": "Bearer eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiJ4In0.sig"}], "postData": {"mimeType": "application/json", "text": "{\"version\":2}"}}, "response": {"status": 200, "content": {"mimeType": "application/json", "text": "{\"episodes\":[{\"uuid\":\"11111111-2222-3333-4444-555555555555\",\"title\":\"t\"}]}"}}}`
+	fmt.Fprint(w, `{"log": {"version": "1.2", "entries": [`)
+	written := 0
+	for i := 0; written < mb<<20; i++ {
+		if i > 0 {
+			w.WriteString(", ")
+		}
+		if i%50 == 49 {
+			fmt.Fprintf(w, `{"request": {"method": "GET", "url": "https://cdn.example.com/%d.mp3"}, "response": {"status": 200, "content": {"mimeType": "audio/mpeg", "encoding": "base64", "text": "%s"}}}`, i, audio)
+			written += len(audio)
+		} else {
+			w.WriteString(api)
+			written += len(api)
+		}
+	}
+	w.WriteString("]}}\n")
+	if err := w.Flush(); err != nil {
+		b.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		b.Fatal(err)
+	}
+	return path
+}
+
+func benchSize() int {
+	if n, err := strconv.Atoi(os.Getenv("HAR_BENCH_MB")); err == nil && n > 0 {
+		return n
+	}
+	return 500
+}
+
+// Run with: go test ./internal/har -run '^$' -bench Large -benchmem (HAR_BENCH_MB
+// changes the 500MB default).
+func BenchmarkSummarizeFileLarge(b *testing.B) {
+	path := benchHAR(b, benchSize())
+	st, _ := os.Stat(path)
+	b.SetBytes(st.Size())
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := SummarizeFile(path, SummarizeOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRedactFileLarge(b *testing.B) {
+	path := benchHAR(b, benchSize())
+	out := filepath.Join(b.TempDir(), "out.har")
+	st, _ := os.Stat(path)
+	b.SetBytes(st.Size())
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := RedactFile(path, out, DefaultRedactOptions()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package har
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Writer records entries to a HAR file as they happen, redacting each one before
+// it reaches the disk. The closing brackets are rewritten after every entry, so
+// the file is a complete HAR at all times and an interrupted capture still opens.
+type Writer struct {
+	mu        sync.Mutex
+	f         *os.File
+	redact    entryFunc
+	n         int
+	trailerAt int64 // offset of the closing brackets
+}
+
+const (
+	writerIndent  = "      " // entries sit in log.entries, three levels down
+	writerTrailer = "\n    ]\n  }\n}\n"
+)
+
+// CreateWriter creates (or truncates) the HAR file at path, readable by the owner
+// only. creator names the program in log.creator.
+func CreateWriter(path, creator, version string, opts RedactOptions) (*Writer, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	c, err := json.Marshal(map[string]string{"name": creator, "version": version})
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	head := fmt.Sprintf("{\n  \"log\": {\n    \"version\": \"1.2\",\n    \"creator\": %s,\n    \"entries\": [", c)
+	w := &Writer{f: f, redact: newRedactor(opts).entryFunc(true), trailerAt: int64(len(head))}
+	if _, err := f.WriteString(head + writerTrailer); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// WriteEntry redacts e (anything that marshals to a HAR entry) and appends it.
+// skipped is the size of a response body left out of e, if any; content.comment
+// records it, as when reading a capture.
+func (w *Writer) WriteEntry(e any, skipped int64) error {
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return os.ErrClosed
+	}
+	out, err := w.redact(w.n, raw, skipped, writerIndent)
+	if err != nil {
+		return err
+	}
+	sep := ",\n"
+	if w.n == 0 {
+		sep = "\n"
+	}
+	buf := append([]byte(sep+writerIndent), out...)
+	if _, err := w.f.WriteAt(append(buf, writerTrailer...), w.trailerAt); err != nil {
+		return err
+	}
+	w.trailerAt += int64(len(buf))
+	w.n++
+	return nil
+}
+
+// Len returns the number of entries written.
+func (w *Writer) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.n
+}
+
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Sync()
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	w.f = nil
+	return err
+}
@@ -0,0 +1,85 @@
+package har
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.har")
+	w, err := CreateWriter(path, "pocketcastsctl", "test", DefaultRedactOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	read := func() (File, string) {
+		t.Helper()
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var f File
+		if err := json.Unmarshal(b, &f); err != nil {
+			t.Fatalf("not a complete HAR: %v\n%s", err, b)
+		}
+		return f, string(b)
+	}
+	if f, _ := read(); len(f.Log.Entries) != 0 {
+		t.Fatalf("new file has %d entries", len(f.Log.Entries))
+	}
+
+	entry := func(path string) map[string]any {
+		return map[string]any{
+			"request": map[string]any{
+				"method":  "POST",
+				"url":     "https://api.pocketcasts.com" + path,
+				"headers": []any{map[string]any{"name": "Authorization", "value": "Bearer secret-token"}},
+				"postData": map[string]any{
+					"mimeType": "application/json",
+					"text":     `{"email":"me@example.com","uuid":"<keep>"}`,
+				},
+			},
+			"response": map[string]any{"status": 200, "content": map[string]any{"mimeType": "application/json", "text": "{}"}},
+		}
+	}
+	if err := w.WriteEntry(entry("/user/login"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if f, _ := read(); len(f.Log.Entries) != 1 {
+		t.Fatalf("after one entry the file has %d", len(f.Log.Entries))
+	}
+	big := entry("/up_next/list")
+	big["response"].(map[string]any)["content"].(map[string]any)["text"] = ""
+	if err := w.WriteEntry(big, 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, text := read()
+	if w.Len() != 2 || len(f.Log.Entries) != 2 || f.Log.Entries[1].Request.URL != "https://api.pocketcasts.com/up_next/list" {
+		t.Fatalf("entries: %+v", f.Log.Entries)
+	}
+	if c := f.Log.Entries[1].Response.Content; c.Text != "" || c.Comment != skippedComment(1<<20) {
+		t.Errorf("skipped body recorded as %+v", c)
+	}
+	for _, secret := range []string{"secret-token", "me@example.com"} {
+		if strings.Contains(text, secret) {
+			t.Errorf("written HAR contains %q", secret)
+		}
+	}
+	if !strings.Contains(text, `<keep>`) {
+		t.Errorf("body was HTML-escaped:\n%s", text)
+	}
+	if !strings.HasPrefix(text, "{\n  \"log\": {\n    \"version\": \"1.2\",\n    \"creator\": {\"name\":\"pocketcastsctl\",\"version\":\"test\"},\n    \"entries\": [\n      {\n") {
+		t.Errorf("unexpected layout:\n%s", text)
+	}
+	if err := w.WriteEntry(entry("/late"), 0); err == nil {
+		t.Error("WriteEntry after Close succeeded")
+	}
+}
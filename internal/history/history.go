@@ -0,0 +1,300 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"pocketcastsctl/internal/fsutil"
+)
+
+type EventType string
+
+const (
+	EventStart  EventType = "start"
+	EventPause  EventType = "pause"
+	EventResume EventType = "resume"
+	EventSeek   EventType = "seek"
+	EventStop   EventType = "stop"
+	EventFinish EventType = "finish"
+	// EventLost marks a player that went away without being stopped (killed,
+	// crashed, or a reboot) before the episode could have ended. Position is
+	// the last one observed.
+	EventLost EventType = "lost"
+)
+
+// Event is one line of the playback history log. Position is in seconds.
+// PodcastUUID identifies the podcast; PodcastTitle is only for display and may be
+// missing, depending on how playback started.
+type Event struct {
+	Time         time.Time `json:"time"`
+	Type         EventType `json:"type"`
+	EpisodeUUID  string    `json:"episode_uuid,omitempty"`
+	Title        string    `json:"title,omitempty"`
+	PodcastUUID  string    `json:"podcast_uuid,omitempty"`
+	PodcastTitle string    `json:"podcast_title,omitempty"`
+	Position     float64   `json:"position"`
+	From         float64   `json:"from,omitempty"` // seek only
+	Backend      string    `json:"backend,omitempty"`
+}
+
+var uuidLike = regexp.MustCompile(`(?i)^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// UnmarshalJSON also reads logs written before the podcast was split in two,
+// whose single "podcast" field held either a UUID or a name.
+func (ev *Event) UnmarshalJSON(b []byte) error {
+	type plain Event
+	var v struct {
+		plain
+		Podcast string `json:"podcast"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*ev = Event(v.plain)
+	if p := strings.TrimSpace(v.Podcast); p != "" && ev.PodcastUUID == "" && ev.PodcastTitle == "" {
+		if uuidLike.MatchString(p) {
+			ev.PodcastUUID = p
+		} else {
+			ev.PodcastTitle = p
+		}
+	}
+	return nil
+}
+
+const (
+	DefaultMaxSize  = 1 << 20
+	DefaultMaxFiles = 3
+)
+
+// Log is an append-only JSONL event log rotated by size (path, path.1, ... path.N).
+type Log struct {
+	Path     string
+	MaxSize  int64
+	MaxFiles int
+}
+
+func New(path string) *Log {
+	return &Log{Path: path, MaxSize: DefaultMaxSize, MaxFiles: DefaultMaxFiles}
+}
+
+// Append writes ev as one line, rotating first if the log has reached MaxSize.
+func (l *Log) Append(ev Event) error {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	unlock, err := fsutil.Lock(l.Path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if fi, err := os.Stat(l.Path); err == nil && l.MaxSize > 0 && fi.Size()+int64(len(b)) > l.MaxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(l.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (l *Log) rotate() error {
+	n := l.MaxFiles
+	if n <= 0 {
+		return os.Remove(l.Path)
+	}
+	_ = os.Remove(l.rotated(n))
+	for i := n - 1; i >= 1; i-- {
+		if err := os.Rename(l.rotated(i), l.rotated(i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return os.Rename(l.Path, l.rotated(1))
+}
+
+func (l *Log) rotated(i int) string {
+	return l.Path + "." + strconv.Itoa(i)
+}
+
+// Read returns events at or after since (zero = all), oldest first, across rotated files.
+// Malformed lines (e.g. from a crash mid-write) are skipped.
+func (l *Log) Read(since time.Time) ([]Event, error) {
+	paths := []string{}
+	for i := l.MaxFiles; i >= 1; i-- {
+		paths = append(paths, l.rotated(i))
+	}
+	paths = append(paths, l.Path)
+
+	var out []Event
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		sc := bufio.NewScanner(f)
+		sc.Buffer(make([]byte, 64*1024), 1<<20)
+		for sc.Scan() {
+			var ev Event
+			if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+				continue
+			}
+			if !since.IsZero() && ev.Time.Before(since) {
+				continue
+			}
+			out = append(out, ev)
+		}
+		err = sc.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", filepath.Base(p), err)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Time.Before(out[j].Time) })
+	return out, nil
+}
+
+// WeekSummary is the listening time for one podcast in one ISO week. Podcast is
+// its title when any event recorded one, else its UUID.
+type WeekSummary struct {
+	Week        string  `json:"week"` // e.g. 2026-W42
+	PodcastUUID string  `json:"podcast_uuid,omitempty"`
+	Podcast     string  `json:"podcast"`
+	Minutes     float64 `json:"minutes"`
+}
+
+// Summarize totals wall-clock listening time per podcast per ISO week, grouping
+// by PodcastUUID (or the title for events without one). A listening segment runs
+// from start/resume to the next pause/stop/finish, or to the next start if the
+// end was never recorded. A lost player is only credited the progress observed.
+// Each segment counts towards the week in which it began.
+func Summarize(events []Event) []WeekSummary {
+	type key struct{ week, podcast string }
+	totals := map[key]float64{}
+	uuids := map[string]string{}  // podcast key -> UUID
+	titles := map[string]string{} // podcast key -> latest title
+	podcastKey := func(ev Event) string {
+		if u := strings.TrimSpace(ev.PodcastUUID); u != "" {
+			return u
+		}
+		return strings.TrimSpace(ev.PodcastTitle)
+	}
+	for _, ev := range events {
+		k := podcastKey(ev)
+		if u := strings.TrimSpace(ev.PodcastUUID); u != "" {
+			uuids[k] = u
+		}
+		if t := strings.TrimSpace(ev.PodcastTitle); t != "" {
+			titles[k] = t
+		}
+	}
+
+	var open *Event
+	closeAt := func(t time.Time) {
+		if open == nil {
+			return
+		}
+		if d := t.Sub(open.Time); d > 0 {
+			y, w := open.Time.ISOWeek()
+			totals[key{week: fmt.Sprintf("%04d-W%02d", y, w), podcast: podcastKey(*open)}] += d.Minutes()
+		}
+		open = nil
+	}
+
+	for i := range events {
+		ev := events[i]
+		switch ev.Type {
+		case EventStart, EventResume:
+			closeAt(ev.Time)
+			open = &ev
+		case EventPause, EventStop, EventFinish:
+			closeAt(ev.Time)
+		case EventLost:
+			// When the player died is unknown; credit only the progress observed.
+			if open != nil {
+				end := open.Time.Add(time.Duration(max(ev.Position-open.Position, 0) * float64(time.Second)))
+				if end.After(ev.Time) {
+					end = ev.Time
+				}
+				closeAt(end)
+			}
+		}
+	}
+
+	out := make([]WeekSummary, 0, len(totals))
+	for k, v := range totals {
+		name := titles[k.podcast]
+		if name == "" {
+			name = k.podcast
+		}
+		if name == "" {
+			name = "(unknown)"
+		}
+		out = append(out, WeekSummary{Week: k.week, PodcastUUID: uuids[k.podcast], Podcast: name, Minutes: v})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Week != out[j].Week {
+			return out[i].Week < out[j].Week
+		}
+		if out[i].Minutes != out[j].Minutes {
+			return out[i].Minutes > out[j].Minutes
+		}
+		return out[i].Podcast < out[j].Podcast
+	})
+	return out
+}
+
+// ParseSince accepts a duration relative to now (90m, 36h, 7d, 2w), a date
+// (2006-01-02, local time) or an RFC3339 timestamp.
+func ParseSince(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, now.Location()); err == nil {
+		return t, nil
+	}
+	unit := s[len(s)-1]
+	if unit == 'd' || unit == 'w' {
+		n, err := strconv.Atoi(s[:len(s)-1])
+		if err != nil || n < 0 {
+			return time.Time{}, fmt.Errorf("invalid --since %q", s)
+		}
+		days := n
+		if unit == 'w' {
+			days = n * 7
+		}
+		return now.AddDate(0, 0, -days), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("invalid --since %q (use e.g. 7d, 36h, 2026-10-01)", s)
+	}
+	return now.Add(-d), nil
+}
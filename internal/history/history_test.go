@@ -0,0 +1,128 @@
+package history
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAppendRotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	l := &Log{Path: path, MaxSize: 300, MaxFiles: 2}
+
+	base := time.Date(2026, 10, 12, 8, 0, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		ev := Event{Time: base.Add(time.Duration(i) * time.Minute), Type: EventSeek, Title: "Episode", Position: float64(i)}
+		if err := l.Append(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, p := range []string{path, path + ".1", path + ".2"} {
+		fi, err := os.Stat(p)
+		if err != nil {
+			t.Fatalf("missing %s: %v", filepath.Base(p), err)
+		}
+		if fi.Size() > l.MaxSize {
+			t.Fatalf("%s is %d bytes, over MaxSize", filepath.Base(p), fi.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("expected at most %d rotated files", l.MaxFiles)
+	}
+
+	events, err := l.Read(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) == 0 || len(events) >= 20 {
+		t.Fatalf("expected oldest events to be dropped, got %d", len(events))
+	}
+	last := events[len(events)-1]
+	if last.Position != 19 {
+		t.Fatalf("newest event missing: %+v", last)
+	}
+	for i := 1; i < len(events); i++ {
+		if events[i].Time.Before(events[i-1].Time) {
+			t.Fatal("events not in chronological order")
+		}
+	}
+
+	since, err := l.Read(base.Add(18 * time.Minute))
+	if err != nil || len(since) != 2 {
+		t.Fatalf("Read(since) = %d events, %v", len(since), err)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	mon := time.Date(2026, 10, 12, 8, 0, 0, 0, time.UTC) // ISO week 42
+	next := mon.AddDate(0, 0, 7)
+	events := []Event{
+		{Time: mon, Type: EventStart, PodcastUUID: "a"},
+		{Time: mon.Add(10 * time.Minute), Type: EventSeek, PodcastUUID: "a"},
+		{Time: mon.Add(20 * time.Minute), Type: EventPause, PodcastUUID: "a"},
+		{Time: mon.Add(60 * time.Minute), Type: EventResume, PodcastUUID: "a"},
+		{Time: mon.Add(65 * time.Minute), Type: EventStop, PodcastUUID: "a", PodcastTitle: "Show A"},
+		{Time: mon.Add(70 * time.Minute), Type: EventStart, PodcastUUID: "b"},
+		// start without a recorded end closes the previous segment
+		{Time: mon.Add(100 * time.Minute), Type: EventStart, PodcastUUID: "a"},
+		{Time: mon.Add(103 * time.Minute), Type: EventFinish, PodcastUUID: "a"},
+		{Time: next, Type: EventStart},
+		{Time: next.Add(time.Minute), Type: EventStop},
+		// a lost player is credited the 5 minutes of progress observed, not the 3 days until noticed
+		{Time: next.Add(time.Hour), Type: EventStart, PodcastUUID: "c", Position: 100},
+		{Time: next.Add(72 * time.Hour), Type: EventLost, PodcastUUID: "c", Position: 400},
+	}
+	got := Summarize(events)
+	want := []WeekSummary{
+		{Week: "2026-W42", PodcastUUID: "b", Podcast: "b", Minutes: 30},
+		{Week: "2026-W42", PodcastUUID: "a", Podcast: "Show A", Minutes: 28},
+		{Week: "2026-W43", PodcastUUID: "c", Podcast: "c", Minutes: 5},
+		{Week: "2026-W43", Podcast: "(unknown)", Minutes: 1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("row %d: got %+v want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestEventReadsLegacyPodcast(t *testing.T) {
+	const uuid = "11111111-1111-1111-1111-111111111111"
+	for in, want := range map[string]Event{
+		`{"type":"start","podcast":"` + uuid + `"}`:                  {Type: EventStart, PodcastUUID: uuid},
+		`{"type":"start","podcast":"Show"}`:                          {Type: EventStart, PodcastTitle: "Show"},
+		`{"type":"start","podcast_uuid":"p","podcast_title":"Show"}`: {Type: EventStart, PodcastUUID: "p", PodcastTitle: "Show"},
+	} {
+		var ev Event
+		if err := json.Unmarshal([]byte(in), &ev); err != nil || ev != want {
+			t.Errorf("%s: got %+v err=%v", in, ev, err)
+		}
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	tests := map[string]time.Time{
+		"":                     {},
+		"7d":                   now.AddDate(0, 0, -7),
+		"2w":                   now.AddDate(0, 0, -14),
+		"36h":                  now.Add(-36 * time.Hour),
+		"2026-10-01":           time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+		"2026-10-01T10:00:00Z": time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC),
+	}
+	for in, want := range tests {
+		got, err := ParseSince(in, now)
+		if err != nil || !got.Equal(want) {
+			t.Fatalf("ParseSince(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseSince("yesterday", now); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,92 @@
+package player
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// ErrNoIPC is returned when seeking or querying a player that has no IPC socket (e.g. afplay).
+var ErrNoIPC = errors.New("player has no IPC socket (position and seeking require mpv)")
+
+// Position asks mpv for the current playback position in seconds.
+func Position(ipcPath string) (float64, error) {
+	var pos float64
+	if err := mpvCommand(ipcPath, &pos, "get_property", "time-pos"); err != nil {
+		return 0, err
+	}
+	return pos, nil
+}
+
+// Duration asks mpv for the length of the current file in seconds.
+func Duration(ipcPath string) (float64, error) {
+	var d float64
+	if err := mpvCommand(ipcPath, &d, "get_property", "duration"); err != nil {
+		return 0, err
+	}
+	return d, nil
+}
+
+// Seek moves mpv to an absolute position in seconds.
+func Seek(ipcPath string, seconds float64) error {
+	if seconds < 0 {
+		seconds = 0
+	}
+	return mpvCommand(ipcPath, nil, "set_property", "time-pos", seconds)
+}
+
+// mpvCommand sends one JSON IPC command and decodes its "data" field into out (if non-nil).
+// A stopped (SIGSTOP) mpv can't answer, so the round trip is bounded by a short deadline.
+func mpvCommand(ipcPath string, out any, args ...any) error {
+	if strings.TrimSpace(ipcPath) == "" {
+		return ErrNoIPC
+	}
+	conn, err := net.DialTimeout("unix", ipcPath, time.Second)
+	if err != nil {
+		return fmt.Errorf("mpv ipc: %w", err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(time.Second))
+
+	const requestID = 1
+	req, err := json.Marshal(map[string]any{"command": args, "request_id": requestID})
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Write(append(req, '\n')); err != nil {
+		return fmt.Errorf("mpv ipc: %w", err)
+	}
+
+	sc := bufio.NewScanner(conn)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for sc.Scan() {
+		var resp struct {
+			RequestID int             `json:"request_id"`
+			Error     string          `json:"error"`
+			Data      json.RawMessage `json:"data"`
+			Event     string          `json:"event"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &resp); err != nil {
+			continue
+		}
+		// mpv interleaves async events with replies.
+		if resp.Event != "" || resp.RequestID != requestID {
+			continue
+		}
+		if resp.Error != "" && resp.Error != "success" {
+			return fmt.Errorf("mpv ipc: %s", resp.Error)
+		}
+		if out != nil && len(resp.Data) > 0 {
+			return json.Unmarshal(resp.Data, out)
+		}
+		return nil
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("mpv ipc: %w", err)
+	}
+	return errors.New("mpv ipc: connection closed without reply")
+}
@@ -19,11 +19,20 @@ type StartOptions struct {
 	Title     string
 	CacheDir  string
 	UserAgent string
+	// Start is the initial position in seconds. Only mpv supports it; check
+	// Started.IPCPath to see whether it was honoured.
+	Start float64
 }
 
 type Started struct {
 	PID     int
 	Command []string
+	// File is the downloaded audio file (afplay fallback only).
+	File string
+	// IPCPath is the mpv JSON IPC socket (mpv only).
+	IPCPath string
+	// Process fingerprints the player so later commands can detect PID reuse.
+	Process ProcessInfo
 }
 
 func Start(ctx context.Context, opts StartOptions) (Started, error) {
@@ -32,14 +41,30 @@ func Start(ctx context.Context, opts StartOptions) (Started, error) {
 		return Started{}, errors.New("missing audio URL")
 	}
 
+	cacheDir := strings.TrimSpace(opts.CacheDir)
+	if cacheDir == "" {
+		cacheDir = os.TempDir()
+	}
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return Started{}, err
+	}
+
 	if mpv, _ := exec.LookPath("mpv"); mpv != "" {
-		cmd := exec.CommandContext(ctx, mpv, "--no-video", "--force-window=no", "--quiet", urlStr)
+		// Only one local playback runs at a time, so a fixed socket name is enough.
+		ipcPath := filepath.Join(cacheDir, "mpv.sock")
+		_ = os.Remove(ipcPath)
+		args := []string{"--no-video", "--force-window=no", "--quiet", "--input-ipc-server=" + ipcPath}
+		if opts.Start > 0 {
+			args = append(args, fmt.Sprintf("--start=%.3f", opts.Start))
+		}
+		cmd := exec.CommandContext(ctx, mpv, append(args, urlStr)...)
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
 		if err := cmd.Start(); err != nil {
 			return Started{}, err
 		}
-		return Started{PID: cmd.Process.Pid, Command: cmd.Args}, nil
+		info, _ := Processes.Lookup(cmd.Process.Pid)
+		return Started{PID: cmd.Process.Pid, Command: cmd.Args, IPCPath: ipcPath, Process: info}, nil
 	}
 
 	// Fallback: download and use afplay (present on macOS).
@@ -48,14 +73,6 @@ func Start(ctx context.Context, opts StartOptions) (Started, error) {
 		return Started{}, errors.New("no supported player found (install mpv or ensure afplay exists)")
 	}
 
-	cacheDir := strings.TrimSpace(opts.CacheDir)
-	if cacheDir == "" {
-		cacheDir = os.TempDir()
-	}
-	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
-		return Started{}, err
-	}
-
 	filePath, err := downloadToFile(ctx, urlStr, cacheDir, opts.UserAgent)
 	if err != nil {
 		return Started{}, err
@@ -67,7 +84,8 @@ func Start(ctx context.Context, opts StartOptions) (Started, error) {
 	if err := cmd.Start(); err != nil {
 		return Started{}, err
 	}
-	return Started{PID: cmd.Process.Pid, Command: cmd.Args}, nil
+	info, _ := Processes.Lookup(cmd.Process.Pid)
+	return Started{PID: cmd.Process.Pid, Command: cmd.Args, File: filePath, Process: info}, nil
 }
 
 func Pause(pid int) error  { return signal(pid, syscall.SIGSTOP) }
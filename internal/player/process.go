@@ -0,0 +1,125 @@
+package player
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrNoProcess is returned by a ProcessTable when the PID does not exist.
+var ErrNoProcess = errors.New("no such process")
+
+// ProcessInfo fingerprints a process so a reused PID can be told apart from our player.
+// StartTime is opaque: clock ticks since boot on Linux, ps(1) lstart elsewhere.
+type ProcessInfo struct {
+	StartTime string `json:"start_time,omitempty"`
+	Exe       string `json:"exe,omitempty"`
+}
+
+// ProcessTable looks up running processes.
+type ProcessTable interface {
+	Lookup(pid int) (ProcessInfo, error)
+}
+
+// Processes is the process table of the running system.
+var Processes ProcessTable = systemProcesses{}
+
+// Owned reports whether pid is still the process described by want.
+// An empty fingerprint (state written by older versions) falls back to matching the
+// executable name against the command we started.
+func Owned(procs ProcessTable, pid int, want ProcessInfo, command []string) bool {
+	if pid <= 0 {
+		return false
+	}
+	got, err := procs.Lookup(pid)
+	if err != nil {
+		return false
+	}
+	if want.StartTime != "" || want.Exe != "" {
+		return got.StartTime == want.StartTime && got.Exe == want.Exe
+	}
+	if len(command) == 0 || got.Exe == "" {
+		return false
+	}
+	return filepath.Base(got.Exe) == filepath.Base(command[0])
+}
+
+type systemProcesses struct{}
+
+func (systemProcesses) Lookup(pid int) (ProcessInfo, error) {
+	if pid <= 0 {
+		return ProcessInfo{}, ErrNoProcess
+	}
+	if _, err := os.Stat("/proc/self/stat"); err == nil {
+		return lookupProc(pid)
+	}
+	return lookupPS(pid)
+}
+
+func lookupProc(pid int) (ProcessInfo, error) {
+	dir := filepath.Join("/proc", strconv.Itoa(pid))
+	b, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ProcessInfo{}, ErrNoProcess
+		}
+		return ProcessInfo{}, err
+	}
+	start, err := parseProcStatStartTime(string(b))
+	if err != nil {
+		return ProcessInfo{}, err
+	}
+	exe, err := os.Readlink(filepath.Join(dir, "exe"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ProcessInfo{}, ErrNoProcess
+		}
+		return ProcessInfo{}, err
+	}
+	return ProcessInfo{StartTime: start, Exe: exe}, nil
+}
+
+// parseProcStatStartTime extracts field 22 (starttime) from /proc/<pid>/stat.
+// The command name (field 2) may contain spaces and parentheses, so fields are
+// counted from the last ')'.
+func parseProcStatStartTime(stat string) (string, error) {
+	i := strings.LastIndexByte(stat, ')')
+	if i < 0 {
+		return "", fmt.Errorf("unexpected /proc stat format")
+	}
+	fields := strings.Fields(stat[i+1:])
+	// fields[0] is field 3 (state), so starttime (field 22) is fields[19].
+	if len(fields) < 20 {
+		return "", fmt.Errorf("unexpected /proc stat format")
+	}
+	return fields[19], nil
+}
+
+func lookupPS(pid int) (ProcessInfo, error) {
+	out, err := exec.Command("ps", "-o", "lstart=", "-o", "comm=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return ProcessInfo{}, ErrNoProcess
+		}
+		return ProcessInfo{}, err
+	}
+	return parsePSLine(string(bytes.TrimSpace(out)))
+}
+
+// parsePSLine splits "Sat Oct 18 10:00:00 2026 /usr/local/bin/mpv" into lstart and comm.
+func parsePSLine(line string) (ProcessInfo, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 6 {
+		return ProcessInfo{}, ErrNoProcess
+	}
+	return ProcessInfo{
+		StartTime: strings.Join(fields[:5], " "),
+		Exe:       strings.Join(fields[5:], " "),
+	}, nil
+}
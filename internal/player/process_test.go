@@ -0,0 +1,87 @@
+package player
+
+import (
+	"os"
+	"testing"
+)
+
+type fakeProcesses map[int]ProcessInfo
+
+func (f fakeProcesses) Lookup(pid int) (ProcessInfo, error) {
+	info, ok := f[pid]
+	if !ok {
+		return ProcessInfo{}, ErrNoProcess
+	}
+	return info, nil
+}
+
+func TestOwned(t *testing.T) {
+	mpv := ProcessInfo{StartTime: "12345", Exe: "/usr/bin/mpv"}
+	procs := fakeProcesses{
+		100: mpv,
+		200: {StartTime: "99999", Exe: "/usr/bin/mpv"},
+		300: {StartTime: "12345", Exe: "/usr/bin/sshd"},
+	}
+	tests := []struct {
+		name    string
+		pid     int
+		want    ProcessInfo
+		command []string
+		owned   bool
+	}{
+		{name: "same process", pid: 100, want: mpv, owned: true},
+		{name: "process gone", pid: 101, want: mpv, owned: false},
+		{name: "pid reused by a later mpv", pid: 200, want: mpv, owned: false},
+		{name: "pid reused by another program", pid: 300, want: mpv, owned: false},
+		{name: "legacy state matches command", pid: 100, command: []string{"/opt/homebrew/bin/mpv", "--quiet"}, owned: true},
+		{name: "legacy state other program", pid: 300, command: []string{"/usr/bin/mpv"}, owned: false},
+		{name: "legacy state without command", pid: 100, owned: false},
+		{name: "invalid pid", pid: 0, want: mpv, owned: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Owned(procs, tt.pid, tt.want, tt.command); got != tt.owned {
+				t.Fatalf("Owned() = %v, want %v", got, tt.owned)
+			}
+		})
+	}
+}
+
+func TestParseProcStatStartTime(t *testing.T) {
+	stat := "4242 (mpv (audio) x) S 1 4242 4242 0 -1 4194560 1000 0 0 0 10 5 0 0 20 0 3 0 987654 123456 789 18446744073709551615"
+	got, err := parseProcStatStartTime(stat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "987654" {
+		t.Fatalf("starttime=%q", got)
+	}
+	if _, err := parseProcStatStartTime("garbage"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestParsePSLine(t *testing.T) {
+	got, err := parsePSLine("Sat Oct 18 10:00:00 2026 /opt/homebrew/bin/mpv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ProcessInfo{StartTime: "Sat Oct 18 10:00:00 2026", Exe: "/opt/homebrew/bin/mpv"}
+	if got != want {
+		t.Fatalf("got %+v want %+v", got, want)
+	}
+}
+
+func TestSystemProcessesSelf(t *testing.T) {
+	info, err := Processes.Lookup(os.Getpid())
+	if err != nil {
+		t.Skipf("process table unavailable: %v", err)
+	}
+	if info.StartTime == "" || info.Exe == "" {
+		t.Fatalf("incomplete fingerprint: %+v", info)
+	}
+	again, err := Processes.Lookup(os.Getpid())
+	if err != nil || again != info {
+		t.Fatalf("fingerprint not stable: %+v vs %+v (%v)", info, again, err)
+	}
+}
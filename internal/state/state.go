@@ -6,15 +6,70 @@ import (
 	"os"
 	"path/filepath"
 	"time"
+
+	"pocketcastsctl/internal/chapters"
+	"pocketcastsctl/internal/fsutil"
+	"pocketcastsctl/internal/player"
 )
 
 type PlaybackState struct {
-	PID         int       `json:"pid"`
-	Command     []string  `json:"command,omitempty"`
-	EpisodeUUID string    `json:"episode_uuid,omitempty"`
-	Title       string    `json:"title,omitempty"`
-	StartedAt   time.Time `json:"started_at"`
-	Paused      bool      `json:"paused"`
+	PID         int      `json:"pid"`
+	Command     []string `json:"command,omitempty"`
+	EpisodeUUID string   `json:"episode_uuid,omitempty"`
+	Title       string   `json:"title,omitempty"`
+	// PodcastUUID identifies the podcast; PodcastTitle is its display name, when known.
+	PodcastUUID  string    `json:"podcast_uuid,omitempty"`
+	PodcastTitle string    `json:"podcast_title,omitempty"`
+	StartedAt    time.Time `json:"started_at"`
+	Paused       bool      `json:"paused"`
+
+	// Process fingerprints PID so a reused PID is never signalled.
+	Process player.ProcessInfo `json:"process"`
+
+	AudioURL string `json:"audio_url,omitempty"`
+	File     string `json:"file,omitempty"`
+	IPCPath  string `json:"ipc_path,omitempty"`
+
+	// Position is the last known position in seconds, observed at PositionAt.
+	Position   float64   `json:"position"`
+	PositionAt time.Time `json:"position_at"`
+	// Duration is the episode length in seconds, once the player has reported it.
+	Duration float64 `json:"duration,omitempty"`
+
+	Chapters []chapters.Chapter `json:"chapters,omitempty"`
+}
+
+// EstimatedPosition extrapolates the playback position from the last observation.
+// It is used when the player can't be asked directly (afplay, or mpv while stopped).
+func (st PlaybackState) EstimatedPosition(now time.Time) float64 {
+	pos := st.Position
+	if !st.Paused && !st.PositionAt.IsZero() {
+		pos += now.Sub(st.PositionAt).Seconds()
+	}
+	if st.Duration > 0 && pos > st.Duration {
+		pos = st.Duration
+	}
+	return pos
+}
+
+// EstimatedEnd is when playback reached the end of the episode, if the duration is known.
+func (st PlaybackState) EstimatedEnd() (time.Time, bool) {
+	if st.Paused || st.Duration <= 0 || st.PositionAt.IsZero() {
+		return time.Time{}, false
+	}
+	left := st.Duration - st.Position
+	if left < 0 {
+		left = 0
+	}
+	return st.PositionAt.Add(time.Duration(left * float64(time.Second))), true
+}
+
+// Backend names the player binary (mpv, afplay).
+func (st PlaybackState) Backend() string {
+	if len(st.Command) == 0 {
+		return ""
+	}
+	return filepath.Base(st.Command[0])
 }
 
 func Load(path string) (PlaybackState, bool, error) {
@@ -32,16 +87,44 @@ func Load(path string) (PlaybackState, bool, error) {
 	return st, true, nil
 }
 
+// Save atomically replaces the state file.
 func Save(path string, st PlaybackState) error {
-	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
-		return err
-	}
 	b, err := json.MarshalIndent(st, "", "  ")
 	if err != nil {
 		return err
 	}
 	b = append(b, '\n')
-	return os.WriteFile(path, b, 0o600)
+	return fsutil.WriteFileAtomic(path, b, 0o600)
+}
+
+// Lock takes the advisory lock guarding read-modify-write cycles of the state file.
+// Hold it across Load/Save (and any player signalling in between).
+func Lock(path string) (func() error, error) {
+	return fsutil.Lock(path)
+}
+
+// TryLock is Lock without waiting; ok is false while another command holds it.
+func TryLock(path string) (unlock func() error, ok bool, err error) {
+	return fsutil.TryLock(path)
+}
+
+// Update re-reads the state under the lock, applies fn and saves the result.
+// A missing state file starts from the zero value.
+func Update(path string, fn func(st *PlaybackState) error) error {
+	unlock, err := Lock(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	st, _, err := Load(path)
+	if err != nil {
+		return err
+	}
+	if err := fn(&st); err != nil {
+		return err
+	}
+	return Save(path, st)
 }
 
 func Clear(path string) error {
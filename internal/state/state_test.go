@@ -0,0 +1,113 @@
+package state
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+const (
+	stressGoroutines = 8
+	stressProcesses  = 4
+	stressIterations = 50
+)
+
+// TestHelperStateProcess is re-executed by TestConcurrentUpdate as a separate process.
+func TestHelperStateProcess(t *testing.T) {
+	path := os.Getenv("POCKETCASTSCTL_STATE_STRESS")
+	if path == "" {
+		t.Skip("helper process")
+	}
+	if err := hammer(path); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func hammer(path string) error {
+	for i := 0; i < stressIterations; i++ {
+		err := Update(path, func(st *PlaybackState) error {
+			st.PID++
+			st.Title = "stress " + strconv.Itoa(st.PID)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		// Readers don't take the lock; atomic writes mean they never see partial JSON.
+		if _, _, err := Load(path); err != nil {
+			return fmt.Errorf("load: %w", err)
+		}
+	}
+	return nil
+}
+
+func TestConcurrentUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	var cmds []*exec.Cmd
+	for i := 0; i < stressProcesses; i++ {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestHelperStateProcess$")
+		cmd.Env = append(os.Environ(), "POCKETCASTSCTL_STATE_STRESS="+path)
+		cmd.Stderr = os.Stderr
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		cmds = append(cmds, cmd)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, stressGoroutines)
+	for i := 0; i < stressGoroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := hammer(path); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	for _, cmd := range cmds {
+		if err := cmd.Wait(); err != nil {
+			t.Fatalf("helper process: %v", err)
+		}
+	}
+
+	st, ok, err := Load(path)
+	if err != nil || !ok {
+		t.Fatalf("ok=%v err=%v", ok, err)
+	}
+	if want := (stressGoroutines + stressProcesses) * stressIterations; st.PID != want {
+		t.Fatalf("lost updates: counter=%d want %d", st.PID, want)
+	}
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".state.json.tmp-*"))
+	if len(matches) != 0 {
+		t.Fatalf("temp files left behind: %v", matches)
+	}
+}
+
+func TestTryLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	unlock, err := Lock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := TryLock(path); ok || err != nil {
+		t.Fatalf("TryLock while locked: ok=%v err=%v", ok, err)
+	}
+	unlock()
+	unlockTry, ok, err := TryLock(path)
+	if !ok || err != nil {
+		t.Fatalf("TryLock after unlock: ok=%v err=%v", ok, err)
+	}
+	unlockTry()
+}
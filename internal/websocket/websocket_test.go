@@ -0,0 +1,109 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEchoRoundTrip(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Accept(w, r)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		for {
+			msg, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := c.WriteMessage(msg); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http")+"/echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// Cover the 7-bit, 16-bit and 64-bit length encodings.
+	for _, n := range []int{5, 300, 70000} {
+		msg := bytes.Repeat([]byte("x"), n)
+		if err := c.WriteMessage(msg); err != nil {
+			t.Fatal(err)
+		}
+		got, err := c.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, msg) {
+			t.Fatalf("echo mismatch for %d bytes", n)
+		}
+	}
+}
+
+func TestServerPingAndClose(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Accept(w, r)
+		if err != nil {
+			return
+		}
+		_ = c.writeFrame(opPing, []byte("hi"))
+		// fragmented text message
+		_ = c.writeRaw(0x00|opText, []byte("hel"))
+		_ = c.writeRaw(0x80|opContinuation, []byte("lo"))
+		_ = c.writeFrame(opClose, nil)
+		_, _ = c.ReadMessage() // pong, then the client's close
+		c.conn.Close()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	got, err := c.ReadMessage()
+	if err != nil || string(got) != "hello" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+	if _, err := c.ReadMessage(); err != io.EOF {
+		t.Fatalf("expected io.EOF after close, got %v", err)
+	}
+}
+
+func TestAcceptRejectsPlainHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = Accept(w, r)
+	}))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status=%d", resp.StatusCode)
+	}
+}
+
+// writeRaw writes an unmasked server frame with an explicit first header byte.
+func (c *Conn) writeRaw(b0 byte, payload []byte) error {
+	_, err := c.conn.Write(append([]byte{b0, byte(len(payload))}, payload...))
+	return err
+}